@@ -105,7 +105,7 @@ func run() error {
 		if err != nil {
 			return err
 		}
-		for _, v := range psDescr {
+		for _, v := range psDescr.Sorted() {
 			fmt.Printf("%#v\n", *v)
 		}
 		fmt.Printf("\n")
@@ -0,0 +1,88 @@
+// Package enc provides conversions between UTF-8 and ISO8859-1, the character
+// encoding used by the CCU.
+package enc
+
+import (
+	"io"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding/charmap"
+)
+
+// ReplacementChar substitutes characters, which can not be represented in
+// ISO8859-1.
+const ReplacementChar = '?'
+
+// ToISO8859_1 converts UTF-8 encoded text to ISO8859-1. Characters outside of
+// ISO8859-1 and invalid UTF-8 sequences are replaced by ReplacementChar.
+func ToISO8859_1(b []byte) []byte {
+	out := make([]byte, 0, len(b))
+	for len(b) > 0 {
+		r, size := utf8.DecodeRune(b)
+		b = b[size:]
+		c, ok := charmap.ISO8859_1.EncodeRune(r)
+		if !ok {
+			c = ReplacementChar
+		}
+		out = append(out, c)
+	}
+	return out
+}
+
+// ISO8859_1Writer converts UTF-8 encoded text to ISO8859-1 (see ToISO8859_1)
+// while writing to an underlying writer. A character may be split across
+// calls of Write. Flush must be called after the last Write.
+type ISO8859_1Writer struct {
+	w       io.Writer
+	pending []byte // incomplete UTF-8 sequence of the last Write
+}
+
+// NewISO8859_1Writer creates an ISO8859_1Writer.
+func NewISO8859_1Writer(w io.Writer) *ISO8859_1Writer {
+	return &ISO8859_1Writer{w: w}
+}
+
+// Write implements io.Writer.
+func (w *ISO8859_1Writer) Write(p []byte) (int, error) {
+	b := p
+	if len(w.pending) > 0 {
+		b = append(w.pending, p...)
+		w.pending = nil
+	}
+	// hold back an incomplete sequence at the end
+	end := len(b)
+	for i := len(b) - 1; i >= 0 && i >= len(b)-utf8.UTFMax; i-- {
+		if utf8.RuneStart(b[i]) {
+			if !utf8.FullRune(b[i:]) {
+				end = i
+			}
+			break
+		}
+	}
+	if end < len(b) {
+		w.pending = append([]byte(nil), b[end:]...)
+	}
+	if _, err := w.w.Write(ToISO8859_1(b[:end])); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Flush writes a held back incomplete sequence as ReplacementChar.
+func (w *ISO8859_1Writer) Flush() error {
+	if len(w.pending) == 0 {
+		return nil
+	}
+	_, err := w.w.Write(ToISO8859_1(w.pending))
+	w.pending = nil
+	return err
+}
+
+// FromISO8859_1 converts ISO8859-1 encoded text to UTF-8.
+func FromISO8859_1(b []byte) (string, error) {
+	out, err := charmap.ISO8859_1.NewDecoder().Bytes(b)
+	if err != nil {
+		return "", err
+	}
+	return string(out), nil
+}
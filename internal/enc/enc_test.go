@@ -0,0 +1,58 @@
+package enc
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestToISO8859_1(t *testing.T) {
+	cases := []struct {
+		in   string
+		want []byte
+	}{
+		{"", []byte{}},
+		{"abc", []byte("abc")},
+		{"äöüß", []byte{0xE4, 0xF6, 0xFC, 0xDF}},
+		{"1 €", []byte("1 ?")},
+		{"a\xffb", []byte("a?b")},
+	}
+	for _, c := range cases {
+		got := ToISO8859_1([]byte(c.in))
+		if !bytes.Equal(got, c.want) {
+			t.Errorf("%q: got %v, want %v", c.in, got, c.want)
+		}
+	}
+}
+
+func TestFromISO8859_1(t *testing.T) {
+	s, err := FromISO8859_1([]byte{'a', 0xE4, 0xB0, 0xFF})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s != "aä°ÿ" {
+		t.Error(s)
+	}
+}
+
+func TestISO8859_1Writer(t *testing.T) {
+	var out bytes.Buffer
+	w := NewISO8859_1Writer(&out)
+	in := []byte("aä€ß")
+	// write byte by byte, multi-byte sequences are split
+	for i := range in {
+		n, err := w.Write(in[i : i+1])
+		if err != nil || n != 1 {
+			t.Fatal(n, err)
+		}
+	}
+	// incomplete sequence at the end
+	if _, err := w.Write([]byte{0xC3}); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if want := []byte{'a', 0xE4, '?', 0xDF, '?'}; !bytes.Equal(out.Bytes(), want) {
+		t.Errorf("got %v, want %v", out.Bytes(), want)
+	}
+}
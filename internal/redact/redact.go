@@ -0,0 +1,46 @@
+// Package redact masks credentials (passwords in URLs, session IDs,
+// Authorization headers) in log messages and errors.
+package redact
+
+import (
+	"regexp"
+	"sync/atomic"
+)
+
+var (
+	// password of the user info in an URL or address (user:password@host)
+	userInfoRegexp = regexp.MustCompile(`((?:^|//|\s)[^/@\s:]+):[^/@\s]*@`)
+	// session ID as query parameter
+	sidRegexp = regexp.MustCompile(`(?i)(\bsid=)[^&\s"'<]*`)
+	// value of an Authorization header
+	authRegexp = regexp.MustCompile(`(?im)(\bauthorization:[ \t]*)[^\r\n]*`)
+)
+
+// disabled is the single switch for the masking in all packages. It is exposed
+// by xmlrpc.SetRedactSecrets.
+var disabled int32
+
+// SetEnabled enables or disables the masking of credentials.
+func SetEnabled(enabled bool) {
+	var d int32
+	if !enabled {
+		d = 1
+	}
+	atomic.StoreInt32(&disabled, d)
+}
+
+// Enabled returns true, if the masking of credentials is enabled (default).
+func Enabled() bool {
+	return atomic.LoadInt32(&disabled) == 0
+}
+
+// String masks the credentials in a string, if the masking is enabled.
+func String(s string) string {
+	if !Enabled() {
+		return s
+	}
+	s = userInfoRegexp.ReplaceAllString(s, "$1:***@")
+	s = sidRegexp.ReplaceAllString(s, "${1}***")
+	s = authRegexp.ReplaceAllString(s, "${1}***")
+	return s
+}
@@ -0,0 +1,29 @@
+// Package remote provides the checking of remote addresses against an
+// allowlist.
+package remote
+
+import "net"
+
+// Allowed returns true, if the IP of the remote address (host:port or only
+// host) is contained in one of the networks. If no networks are specified,
+// all remote addresses are allowed.
+func Allowed(nets []net.IPNet, remoteAddr string) bool {
+	if len(nets) == 0 {
+		return true
+	}
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		// no port
+		host = remoteAddr
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return false
+	}
+	for _, n := range nets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
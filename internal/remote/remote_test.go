@@ -0,0 +1,34 @@
+package remote
+
+import (
+	"net"
+	"testing"
+)
+
+func TestAllowed(t *testing.T) {
+	_, n1, _ := net.ParseCIDR("192.168.0.20/32")
+	_, n2, _ := net.ParseCIDR("10.0.0.0/8")
+	_, n3, _ := net.ParseCIDR("::1/128")
+	nets := []net.IPNet{*n1, *n2, *n3}
+
+	cases := []struct {
+		addr string
+		want bool
+	}{
+		{"192.168.0.20:2001", true},
+		{"192.168.0.21:2001", false},
+		{"10.1.2.3:80", true},
+		{"10.1.2.3", true},
+		{"[::1]:2001", true},
+		{"[::2]:2001", false},
+		{"invalid", false},
+	}
+	for _, c := range cases {
+		if got := Allowed(nets, c.addr); got != c.want {
+			t.Error(c.addr, got)
+		}
+	}
+	if !Allowed(nil, "1.2.3.4:5") {
+		t.Error("expected allowed")
+	}
+}
@@ -25,6 +25,10 @@ var clnLog = logging.Get("binrpc-client")
 type Client struct {
 	Addr              string
 	ResponseSizeLimit int64
+
+	// MaxDepth limits the nesting depth of arrays and structs in responses
+	// (default: xmlrpc.DefaultMaxDepth).
+	MaxDepth int
 }
 
 // Call executes an remote procedure call. Call implements xmlrpc.Caller.
@@ -66,6 +70,7 @@ func (c *Client) Call(method string, params xmlrpc.Values) (*xmlrpc.Value, error
 
 	// decode response
 	dec := NewDecoder(limitReader)
+	dec.MaxDepth = c.MaxDepth
 	resp, err := dec.DecodeResponse()
 	if err != nil {
 		_, methodError := err.(*xmlrpc.MethodError)
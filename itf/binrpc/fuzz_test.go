@@ -0,0 +1,48 @@
+//go:build go1.18
+// +build go1.18
+
+package binrpc
+
+import (
+	"bytes"
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+// fuzzSeeds are captured BIN-RPC requests (see TestEncodeRequest).
+var fuzzSeeds = []string{
+	"42 69 6e 00 00 00 00 1a 00 00 00 12 73 79 73 74 65 6d 2e 6c 69 73 74 4d 65 74 68 6f 64 73 00 00 00 00",
+	"42 69 6e 00 00 00 00 3f 00 00 00 04 69 6e 69 74 00 00 00 02 00 00 00 03 00 00 00 1f 78 6d 6c 72 " +
+		"70 63 5f 62 69 6e 3a 2f 2f 31 37 32 2e 31 36 2e 32 33 2e 31 38 30 3a 32 30 30 34 00 00 00 03 00 " +
+		"00 00 04 74 65 73 74",
+	"42 69 6E 00 00 00 00 87 " +
+		"00 00 00 10 73 79 73 74 65 6D 2E 6D 75 6C 74 69 63 61 6C 6C 00 00 00 01 00 00 01 00 00 00 00 01 " +
+		"00 00 01 01 00 00 00 02 00 00 00 0A 6D 65 74 68 6F 64 4E 61 6D 65 00 00 00 03 00 00 00 05 65 76 " +
+		"65 6E 74 00 00 00 06 70 61 72 61 6D 73 00 00 01 00 00 00 00 04 00 00 00 03 00 00 00 04 43 55 78 " +
+		"44 00 00 00 03 00 00 00 0C 43 55 58 34 30 30 30 31 30 31 3A 32 00 00 00 03 00 00 00 05 53 54 41 " +
+		"54 45 00 00 00 02 00",
+}
+
+// FuzzDecodeRequest checks that the decoder never panics. A successfully
+// decoded request must be encodable again.
+func FuzzDecodeRequest(f *testing.F) {
+	for _, s := range fuzzSeeds {
+		b, err := hex.DecodeString(strings.ReplaceAll(s, " ", ""))
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add(b)
+	}
+	f.Fuzz(func(t *testing.T, b []byte) {
+		d := NewDecoder(bytes.NewReader(b))
+		method, params, err := d.DecodeRequest()
+		if err != nil {
+			return
+		}
+		var buf bytes.Buffer
+		if err := NewEncoder(&buf).EncodeRequest(method, params); err != nil {
+			t.Errorf("Decoded request can not be encoded: %v", err)
+		}
+	})
+}
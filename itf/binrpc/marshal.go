@@ -8,9 +8,10 @@ import (
 	"io"
 	"math"
 	"strconv"
+	"sync"
 
+	"github.com/mdzio/go-hmccu/internal/enc"
 	"github.com/mdzio/go-hmccu/itf/xmlrpc"
-	"golang.org/x/text/encoding/charmap"
 )
 
 // Encoder encodes XML-RPC requests as BIN-RPC.
@@ -26,14 +27,16 @@ func NewEncoder(w io.Writer) *Encoder {
 // EncodeRequest encodes a XML-RPC request.
 func (e *Encoder) EncodeRequest(method string, params []*xmlrpc.Value) error {
 	// encode parameters
-	pe := valueEncoder{}
+	pe := getValueEncoder()
+	defer putValueEncoder(pe)
 	err := pe.encodeValues(params)
 	if err != nil {
 		return err
 	}
 
 	// encode method name
-	me := valueEncoder{}
+	me := getValueEncoder()
+	defer putValueEncoder(me)
 	err = me.encodeStringWOType(method)
 	if err != nil {
 		return err
@@ -57,7 +60,7 @@ func (e *Encoder) EncodeRequest(method string, params []*xmlrpc.Value) error {
 	}
 
 	// write method name and parameters
-	_, err = e.w.ReadFrom(io.MultiReader(&me, &pe))
+	_, err = e.w.ReadFrom(io.MultiReader(me, pe))
 	if err != nil {
 		return fmt.Errorf("Writing of method name or parameters failed: %w", err)
 	}
@@ -67,7 +70,8 @@ func (e *Encoder) EncodeRequest(method string, params []*xmlrpc.Value) error {
 // EncodeResponse encodes a XML-RPC response.
 func (e *Encoder) EncodeResponse(value *xmlrpc.Value) error {
 	// encode value
-	ve := valueEncoder{}
+	ve := getValueEncoder()
+	defer putValueEncoder(ve)
 	q := xmlrpc.Q(value)
 	if q.IsEmpty() {
 		err := ve.encodeString("")
@@ -96,7 +100,7 @@ func (e *Encoder) EncodeResponse(value *xmlrpc.Value) error {
 	}
 
 	// write value
-	_, err = e.w.ReadFrom(&ve)
+	_, err = e.w.ReadFrom(ve)
 	if err != nil {
 		return fmt.Errorf("Writing of value failed: %w", err)
 	}
@@ -125,7 +129,8 @@ func (e *Encoder) EncodeFault(fault error) error {
 	}
 
 	// encode value
-	ve := valueEncoder{}
+	ve := getValueEncoder()
+	defer putValueEncoder(ve)
 	err := ve.encodeValue(val)
 	if err != nil {
 		return err
@@ -146,7 +151,7 @@ func (e *Encoder) EncodeFault(fault error) error {
 	}
 
 	// write value
-	_, err = e.w.ReadFrom(&ve)
+	_, err = e.w.ReadFrom(ve)
 	if err != nil {
 		return fmt.Errorf("Writing of fault value failed: %w", err)
 	}
@@ -157,9 +162,41 @@ type valueEncoder struct {
 	bytes.Buffer
 }
 
+// max. capacity of a buffer to be put back into the pool, larger buffers are
+// released to avoid holding much memory
+const maxPooledBufferSize = 64 * 1024
+
+// valueEncoderPool holds reusable value encoders to reduce allocations.
+var valueEncoderPool = sync.Pool{
+	New: func() interface{} { return new(valueEncoder) },
+}
+
+// writeUint32 writes a 32 bit integer in big endian byte order. In contrast to
+// binary.Write, no allocations are needed.
+func (e *valueEncoder) writeUint32(v uint32) error {
+	var b [4]byte
+	binary.BigEndian.PutUint32(b[:], v)
+	_, err := e.Write(b[:])
+	return err
+}
+
+// getValueEncoder returns an empty value encoder from the pool.
+func getValueEncoder() *valueEncoder {
+	return valueEncoderPool.Get().(*valueEncoder)
+}
+
+// putValueEncoder resets the value encoder and returns it to the pool.
+func putValueEncoder(e *valueEncoder) {
+	if e.Cap() > maxPooledBufferSize {
+		return
+	}
+	e.Reset()
+	valueEncoderPool.Put(e)
+}
+
 func (e *valueEncoder) encodeValues(values xmlrpc.Values) error {
 	// write number of parameters
-	err := binary.Write(e, binary.BigEndian, uint32(len(values)))
+	err := e.writeUint32(uint32(len(values)))
 	if err != nil {
 		return fmt.Errorf("Writing number of parameters failed: %w", err)
 	}
@@ -176,6 +213,11 @@ func (e *valueEncoder) encodeValues(values xmlrpc.Values) error {
 
 func (e *valueEncoder) encodeValue(v *xmlrpc.Value) error {
 	switch {
+	case v.Stream() != nil:
+		err := e.encodeArrayStream(v.Stream())
+		if err != nil {
+			return err
+		}
 	case v.ElemString != "":
 		err := e.encodeString(v.ElemString)
 		if err != nil {
@@ -222,13 +264,13 @@ func (e *valueEncoder) encodeValue(v *xmlrpc.Value) error {
 
 func (e *valueEncoder) encodeStruct(v *xmlrpc.Struct) error {
 	// write data type
-	err := binary.Write(e, binary.BigEndian, uint32(structType))
+	err := e.writeUint32(uint32(structType))
 	if err != nil {
 		return fmt.Errorf("Writing of struct type failed: %w", err)
 	}
 
 	// write number of elements
-	err = binary.Write(e, binary.BigEndian, uint32(len(v.Members)))
+	err = e.writeUint32(uint32(len(v.Members)))
 	if err != nil {
 		return fmt.Errorf("Writing of struct length failed: %w", err)
 	}
@@ -249,7 +291,7 @@ func (e *valueEncoder) encodeStruct(v *xmlrpc.Struct) error {
 
 func (e *valueEncoder) encodeString(str string) error {
 	// write data type
-	err := binary.Write(e, binary.BigEndian, uint32(stringType))
+	err := e.writeUint32(uint32(stringType))
 	if err != nil {
 		return fmt.Errorf("Writing of string type failed: %w", err)
 	}
@@ -262,19 +304,21 @@ func (e *valueEncoder) encodeString(str string) error {
 	return nil
 }
 
+// encodeStringWOType writes the length and the ISO8859-1 encoded content of a
+// string. Characters outside of ISO8859-1 (e.g. emoji, cyrillic) are replaced
+// by enc.ReplacementChar. The length always matches the encoded content.
 func (e *valueEncoder) encodeStringWOType(str string) error {
 	// encode string with ISO8859-1
-	buf := bytes.Buffer{}
-	charmap.ISO8859_1.NewEncoder().Writer(&buf).Write([]byte(str))
+	b := enc.ToISO8859_1([]byte(str))
 
-	// write length
-	err := binary.Write(e, binary.BigEndian, uint32(len(buf.Bytes())))
+	// write length of the encoded content
+	err := e.writeUint32(uint32(len(b)))
 	if err != nil {
 		return fmt.Errorf("Writing of string length failed: %w", err)
 	}
 
 	// write content
-	_, err = e.Write(buf.Bytes())
+	_, err = e.Write(b)
 	if err != nil {
 		return fmt.Errorf("Writing of string content failed: %w", err)
 	}
@@ -289,13 +333,13 @@ func (e *valueEncoder) encodeInteger(n string) error {
 	}
 
 	// write data type
-	err = binary.Write(e, binary.BigEndian, uint32(integerType))
+	err = e.writeUint32(uint32(integerType))
 	if err != nil {
 		return fmt.Errorf("Writing of integer type failed: %w", err)
 	}
 
 	// write integer
-	err = binary.Write(e, binary.BigEndian, int32(num))
+	err = e.writeUint32(uint32(int32(num)))
 	if err != nil {
 		return fmt.Errorf("Writing of integer failed: %w", err)
 	}
@@ -310,7 +354,7 @@ func (e *valueEncoder) encodeDouble(v string) error {
 	}
 
 	// write data type
-	err = binary.Write(e, binary.BigEndian, uint32(doubleType))
+	err = e.writeUint32(uint32(doubleType))
 	if err != nil {
 		return fmt.Errorf("Writing of double type failed: %w", err)
 	}
@@ -320,11 +364,11 @@ func (e *valueEncoder) encodeDouble(v string) error {
 	man := math.Floor((num * math.Pow(2, -1*exp)) * mantissaMultiplicator)
 
 	// write BIN-RPC representation
-	err = binary.Write(e, binary.BigEndian, int32(man))
+	err = e.writeUint32(uint32(int32(man)))
 	if err != nil {
 		return fmt.Errorf("Writing of double mantissa failed: %w", err)
 	}
-	err = binary.Write(e, binary.BigEndian, int32(exp))
+	err = e.writeUint32(uint32(int32(exp)))
 	if err != nil {
 		return fmt.Errorf("Writing of double exponent failed: %w", err)
 	}
@@ -344,7 +388,7 @@ func (e *valueEncoder) encodeBool(val string) error {
 	}
 
 	// write data type
-	err := binary.Write(e, binary.BigEndian, uint32(booleanType))
+	err := e.writeUint32(uint32(booleanType))
 	if err != nil {
 		return fmt.Errorf("Writing of bool type failed: %w", err)
 	}
@@ -357,9 +401,32 @@ func (e *valueEncoder) encodeBool(val string) error {
 	return nil
 }
 
+// encodeArrayStream encodes an array, whose elements are created one by one.
+func (e *valueEncoder) encodeArrayStream(s *xmlrpc.ArrayStream) error {
+	err := e.writeUint32(uint32(arrayType))
+	if err != nil {
+		return fmt.Errorf("Writing of array type failed: %w", err)
+	}
+	err = e.writeUint32(uint32(s.Len))
+	if err != nil {
+		return fmt.Errorf("Writing number of parameters failed: %w", err)
+	}
+	for i := 0; i < s.Len; i++ {
+		v, err := s.Elem(i)
+		if err != nil {
+			return fmt.Errorf("Creating of array element %d failed: %w", i, err)
+		}
+		err = e.encodeValue(v)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (e *valueEncoder) encodeArray(arr *xmlrpc.Array) error {
 	// write data type
-	err := binary.Write(e, binary.BigEndian, uint32(arrayType))
+	err := e.writeUint32(uint32(arrayType))
 	if err != nil {
 		return fmt.Errorf("Writing of array type failed: %w", err)
 	}
@@ -146,6 +146,22 @@ func TestEncodeParam(t *testing.T) {
 			"00 00 00 03 00 00 00 07 fc f6 e4 dc d6 c4 df",
 			false,
 		},
+		{
+			"String outside ISO8859-1",
+			xmlrpc.Value{FlatString: "a€Ж😀ä"},
+			"00 00 00 03 00 00 00 05 61 3f 3f 3f e4",
+			false,
+		},
+		{
+			"Struct member outside ISO8859-1",
+			xmlrpc.Value{
+				Struct: &xmlrpc.Struct{Members: []*xmlrpc.Member{
+					{Name: "Ж", Value: &xmlrpc.Value{Int: "1"}},
+				}},
+			},
+			"00 00 01 01 00 00 00 01 00 00 00 01 3f 00 00 00 01 00 00 00 01",
+			false,
+		},
 		{
 			"Integer 41",
 			xmlrpc.Value{Int: "41"},
@@ -237,3 +253,45 @@ func TestEncodeParam(t *testing.T) {
 		})
 	}
 }
+
+func BenchmarkEncodeEvents(b *testing.B) {
+	params := []*xmlrpc.Value{
+		xmlrpc.NewString("CUxD"),
+		xmlrpc.NewString("CUX2801001:1"),
+		xmlrpc.NewString("LEVEL"),
+		xmlrpc.NewFloat64(0.75),
+	}
+	var buf bytes.Buffer
+	b.ReportAllocs()
+	for n := 0; n < b.N; n++ {
+		// encode 10k events
+		for i := 0; i < 10000; i++ {
+			buf.Reset()
+			if err := NewEncoder(&buf).EncodeRequest("event", params); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
+
+func TestEncodeArrayStream(t *testing.T) {
+	elem := func(i int) (*xmlrpc.Value, error) {
+		return &xmlrpc.Value{Struct: &xmlrpc.Struct{Members: []*xmlrpc.Member{
+			{Name: "INDEX", Value: xmlrpc.NewInt(i)},
+		}}}, nil
+	}
+	var streamed, materialized bytes.Buffer
+	if err := NewEncoder(&streamed).EncodeResponse(xmlrpc.NewArrayStream(3, elem)); err != nil {
+		t.Fatal(err)
+	}
+	v, err := xmlrpc.NewArrayStream(3, elem).Materialize()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := NewEncoder(&materialized).EncodeResponse(v); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(streamed.Bytes(), materialized.Bytes()) {
+		t.Errorf("responses differ:\n%x\n%x", streamed.Bytes(), materialized.Bytes())
+	}
+}
@@ -4,8 +4,10 @@ import (
 	"bytes"
 	"fmt"
 	"net"
+	"sync"
 	"time"
 
+	"github.com/mdzio/go-hmccu/internal/remote"
 	"github.com/mdzio/go-hmccu/itf/xmlrpc"
 	"github.com/mdzio/go-logging"
 )
@@ -16,6 +18,9 @@ const (
 
 	// max. size of a valid request, if not specified: 2 MB
 	requestSizeLimit = 2 * 1024 * 1024
+
+	// max. time to wait for active requests on shutdown, if not specified
+	shutdownTimeout = 5 * time.Second
 )
 
 var svrLog = logging.Get("binrpc-server")
@@ -27,9 +32,29 @@ type Server struct {
 	ServeErr         chan<- error
 	RequestSizeLimit int64
 
+	// MaxDepth limits the nesting depth of arrays and structs in requests
+	// (default: xmlrpc.DefaultMaxDepth).
+	MaxDepth int
+
+	// ShutdownTimeout is the max. time Stop waits for active requests to
+	// finish. Afterwards the remaining connections are closed forcibly
+	// (default: 5 seconds).
+	ShutdownTimeout time.Duration
+
+	// If AllowedRemotes is set, only connections from these networks are
+	// accepted (e.g. only from the CCU). Other connections are closed
+	// immediately.
+	AllowedRemotes []net.IPNet
+
 	listener net.Listener
 	stop     chan struct{}
 	done     chan struct{}
+	stopOnce sync.Once
+
+	mtx     sync.Mutex
+	conns   map[net.Conn]struct{}
+	active  sync.WaitGroup
+	stopped bool
 }
 
 // Start starts the TCP server for handling BIN-RPC requests.
@@ -40,9 +65,15 @@ func (s *Server) Start() error {
 	// avoid blocking
 	s.stop = make(chan struct{}, 1)
 	s.done = make(chan struct{}, 1)
+	s.stopOnce = sync.Once{}
+	s.mtx.Lock()
+	s.conns = make(map[net.Conn]struct{})
+	s.stopped = false
+	s.mtx.Unlock()
 
 	// start listening
 	svrLog.Infof("Starting BIN-RPC server on address %s", s.Addr)
+	s.listener = nil
 	l, err := net.Listen("tcp", s.Addr)
 	if err != nil {
 		return fmt.Errorf("Listen on address %s failed: %w", s.Addr, err)
@@ -52,9 +83,9 @@ func (s *Server) Start() error {
 	// start serving
 	var delay time.Duration
 	go func() {
-		defer s.listener.Close()
+		defer l.Close()
 		for {
-			conn, err := s.listener.Accept()
+			conn, err := l.Accept()
 			if err != nil {
 				// stop request?
 				select {
@@ -83,22 +114,94 @@ func (s *Server) Start() error {
 				// signal server is down
 				s.done <- struct{}{}
 				// signal error
-				s.ServeErr <- err
+				if s.ServeErr != nil {
+					s.ServeErr <- err
+				}
 				return
 			}
+			// check remote address
+			if !remote.Allowed(s.AllowedRemotes, conn.RemoteAddr().String()) {
+				svrLog.Warningf("Rejecting connection from not allowed remote address %s", conn.RemoteAddr())
+				conn.Close()
+				continue
+			}
 			// handle connection
-			go s.handle(conn)
+			if !s.track(conn) {
+				// server is stopping
+				conn.Close()
+				continue
+			}
+			go func() {
+				defer s.untrack(conn)
+				s.handle(conn)
+			}()
 		}
 	}()
 	return nil
 }
 
-// Stop stops the TCP server.
+// Stop stops the TCP server. The listener is closed and new connections are
+// refused. Then Stop waits for active requests to finish. If they do not
+// finish within ShutdownTimeout, the remaining connections are closed. After
+// Stop returns, the address can be used again. Stop can be called safely more
+// than once, and also if Start failed.
 func (s *Server) Stop() {
-	svrLog.Debug("Shutting down BIN-RPC server")
-	s.stop <- struct{}{}
-	s.listener.Close()
-	<-s.done
+	if s.listener == nil {
+		return
+	}
+	s.stopOnce.Do(func() {
+		svrLog.Debug("Shutting down BIN-RPC server")
+		s.mtx.Lock()
+		s.stopped = true
+		s.mtx.Unlock()
+		s.stop <- struct{}{}
+		s.listener.Close()
+		<-s.done
+
+		// wait for active requests
+		timeout := s.ShutdownTimeout
+		if timeout == 0 {
+			timeout = shutdownTimeout
+		}
+		finished := make(chan struct{})
+		go func() {
+			s.active.Wait()
+			close(finished)
+		}()
+		select {
+		case <-finished:
+		case <-time.After(timeout):
+			s.mtx.Lock()
+			svrLog.Warningf("Closing %d active connection(s) of BIN-RPC server forcibly", len(s.conns))
+			for conn := range s.conns {
+				conn.Close()
+			}
+			s.mtx.Unlock()
+			<-finished
+		}
+		svrLog.Debug("BIN-RPC server is down")
+	})
+}
+
+// track registers an active connection. False is returned, if the server is
+// stopping.
+func (s *Server) track(conn net.Conn) bool {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	if s.stopped {
+		return false
+	}
+	s.conns[conn] = struct{}{}
+	s.active.Add(1)
+	return true
+}
+
+// untrack unregisters an active connection.
+func (s *Server) untrack(conn net.Conn) {
+	s.mtx.Lock()
+	delete(s.conns, conn)
+	s.mtx.Unlock()
+	s.active.Done()
 }
 
 func (s *Server) handle(conn net.Conn) {
@@ -107,6 +210,7 @@ func (s *Server) handle(conn net.Conn) {
 
 	// decode request
 	dec := NewDecoder(conn)
+	dec.MaxDepth = s.MaxDepth
 	method, params, err := dec.DecodeRequest()
 	if err != nil {
 		svrLog.Errorf("Decoding of request from %s failed: %v", conn.RemoteAddr(), err)
@@ -3,7 +3,9 @@ package binrpc
 import (
 	"errors"
 	"log"
+	"net"
 	"testing"
+	"time"
 
 	"github.com/mdzio/go-hmccu/itf/xmlrpc"
 )
@@ -72,3 +74,73 @@ func TestServer(t *testing.T) {
 		t.Error(err)
 	}
 }
+
+func TestServerAllowedRemotes(t *testing.T) {
+	_, other, _ := net.ParseCIDR("192.168.0.20/32")
+	svr := &Server{
+		Addr:           "127.0.0.1:2126",
+		Dispatcher:     &xmlrpc.BasicDispatcher{},
+		AllowedRemotes: []net.IPNet{*other},
+	}
+	svr.AddSystemMethods()
+	err := svr.Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer svr.Stop()
+
+	cln := Client{Addr: svr.Addr}
+	if _, err := cln.Call("system.listMethods", nil); err == nil {
+		t.Error("expected error")
+	}
+}
+
+func TestServerStop(t *testing.T) {
+	svr := &Server{
+		Addr:            "127.0.0.1:2125",
+		Dispatcher:      &xmlrpc.BasicDispatcher{},
+		ShutdownTimeout: 100 * time.Millisecond,
+	}
+	// stop without start
+	svr.Stop()
+
+	err := svr.Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// idle connection, which does not send a request
+	conn, err := net.Dial("tcp", svr.Addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	// wait for accept
+	time.Sleep(50 * time.Millisecond)
+
+	start := time.Now()
+	svr.Stop()
+	if d := time.Since(start); d < 100*time.Millisecond || d > 2*time.Second {
+		t.Error("unexpected shutdown duration:", d)
+	}
+	// second stop is a no-op
+	svr.Stop()
+
+	// idle connection must be closed
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	if _, err := conn.Read(make([]byte, 1)); err == nil {
+		t.Error("expected closed connection")
+	}
+	// new connections are refused
+	if c, err := net.Dial("tcp", svr.Addr); err == nil {
+		c.Close()
+		t.Error("expected refused connection")
+	}
+
+	// address can be used again
+	err = svr.Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+	svr.Stop()
+}
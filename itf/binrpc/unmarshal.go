@@ -6,17 +6,34 @@ import (
 	"encoding/hex"
 	"fmt"
 	"io"
-	"io/ioutil"
 	"math"
 	"strconv"
 
+	"github.com/mdzio/go-hmccu/internal/enc"
 	"github.com/mdzio/go-hmccu/itf/xmlrpc"
-	"golang.org/x/text/encoding/charmap"
 )
 
+// Limits for preallocating memory based on length fields of the received data.
+const (
+	maxPreallocValues = 64
+	maxPreallocString = 4096
+)
+
+func minUint32(a, b uint32) uint32 {
+	if a < b {
+		return a
+	}
+	return b
+}
+
 // Decoder decodes BIN-RPC requests.
 type Decoder struct {
-	r io.Reader
+	r     io.Reader
+	depth int
+
+	// MaxDepth limits the nesting depth of arrays and structs (default:
+	// xmlrpc.DefaultMaxDepth).
+	MaxDepth int
 }
 
 // NewDecoder create a Decoder.
@@ -97,14 +114,15 @@ func (d *Decoder) decodeValues() (xmlrpc.Values, error) {
 		return nil, fmt.Errorf("Reading of length failed: %w", err)
 	}
 
-	// read items
-	vals := make([]*xmlrpc.Value, length)
-	for i := range vals {
+	// read items, the slice grows with the actually decoded items (the length
+	// is not trusted)
+	vals := make([]*xmlrpc.Value, 0, minUint32(length, maxPreallocValues))
+	for i := uint32(0); i < length; i++ {
 		val, err := d.decodeValue()
 		if err != nil {
 			return nil, err
 		}
-		vals[i] = val
+		vals = append(vals, val)
 	}
 	return vals, nil
 }
@@ -141,19 +159,24 @@ func (d *Decoder) decodeString() (*xmlrpc.Value, error) {
 		return nil, fmt.Errorf("Reading of string length failed: %w", err)
 	}
 
-	// read ISO8859-1 string
-	bISO8859_1 := make([]byte, int(length))
-	if err := binary.Read(d.r, binary.BigEndian, &bISO8859_1); err != nil {
+	// read ISO8859-1 string, the buffer grows with the actually received
+	// content (the length is not trusted)
+	var buf bytes.Buffer
+	buf.Grow(int(minUint32(length, maxPreallocString)))
+	if _, err := io.CopyN(&buf, d.r, int64(length)); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
 		return nil, fmt.Errorf("Reading of string content failed: %w", err)
 	}
+	bISO8859_1 := buf.Bytes()
 
 	// decode ISO8859-1 to UTF8
-	rUTF8 := charmap.ISO8859_1.NewDecoder().Reader(bytes.NewBuffer(bISO8859_1))
-	bUTF8, err := ioutil.ReadAll(rUTF8)
+	sUTF8, err := enc.FromISO8859_1(bISO8859_1)
 	if err != nil {
 		return nil, fmt.Errorf("Converting of string content failed: %w", err)
 	}
-	return &xmlrpc.Value{FlatString: string(bUTF8)}, nil
+	return &xmlrpc.Value{FlatString: sUTF8}, nil
 }
 
 func (d *Decoder) decodeInteger() (*xmlrpc.Value, error) {
@@ -190,7 +213,25 @@ func (d *Decoder) decodeDouble() (*xmlrpc.Value, error) {
 	return &xmlrpc.Value{Double: strconv.FormatFloat(val, 'f', -1, 64)}, nil
 }
 
+// enter increments the nesting depth and checks the limit. The caller must
+// decrement depth afterwards.
+func (d *Decoder) enter() error {
+	max := d.MaxDepth
+	if max == 0 {
+		max = xmlrpc.DefaultMaxDepth
+	}
+	d.depth++
+	if d.depth > max {
+		return fmt.Errorf("Max. nesting depth of %d exceeded", max)
+	}
+	return nil
+}
+
 func (d *Decoder) decodeArray() (*xmlrpc.Value, error) {
+	defer func() { d.depth-- }()
+	if err := d.enter(); err != nil {
+		return nil, err
+	}
 	vals, err := d.decodeValues()
 	if err != nil {
 		return nil, err
@@ -199,6 +240,10 @@ func (d *Decoder) decodeArray() (*xmlrpc.Value, error) {
 }
 
 func (d *Decoder) decodeStruct() (*xmlrpc.Value, error) {
+	defer func() { d.depth-- }()
+	if err := d.enter(); err != nil {
+		return nil, err
+	}
 	var length uint32
 	if err := binary.Read(d.r, binary.BigEndian, &length); err != nil {
 		return nil, fmt.Errorf("Failed to decode struct length: %w", err)
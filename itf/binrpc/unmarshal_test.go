@@ -105,3 +105,35 @@ func TestDecodeValue(t *testing.T) {
 		})
 	}
 }
+
+func TestDecodeMaxDepth(t *testing.T) {
+	nested := func(depth int) []byte {
+		v := &xmlrpc.Value{Int: "1"}
+		for i := 0; i < depth; i++ {
+			v = &xmlrpc.Value{Array: &xmlrpc.Array{Data: []*xmlrpc.Value{v}}}
+		}
+		e := valueEncoder{}
+		if err := e.encodeValue(v); err != nil {
+			t.Fatal(err)
+		}
+		return e.Bytes()
+	}
+
+	d := NewDecoder(bytes.NewReader(nested(3)))
+	d.MaxDepth = 3
+	if _, err := d.decodeValue(); err != nil {
+		t.Error(err)
+	}
+
+	d = NewDecoder(bytes.NewReader(nested(4)))
+	d.MaxDepth = 3
+	_, err := d.decodeValue()
+	if err == nil || err.Error() != "Max. nesting depth of 3 exceeded" {
+		t.Error(err)
+	}
+
+	d = NewDecoder(bytes.NewReader(nested(xmlrpc.DefaultMaxDepth + 1)))
+	if _, err := d.decodeValue(); err == nil {
+		t.Error("expected error")
+	}
+}
@@ -1,6 +1,7 @@
 package itf
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"strconv"
@@ -12,6 +13,10 @@ import (
 
 var dclnLog = logging.Get("itf-d-client")
 
+// ErrNoPong is returned by PingAndWait, if the ping call succeeded, but no
+// PONG event was received.
+var ErrNoPong = errors.New("No PONG event received")
+
 // DeviceLayerClient provides access to the HomeMatic XML-RPC API of the device layer.
 type DeviceLayerClient struct {
 	Name string
@@ -283,3 +288,93 @@ func (c *DeviceLayerClient) Ping(callerID string) (bool, error) {
 	}
 	return res, nil
 }
+
+// PingAndWait triggers a pong event and waits for it. The logic layer must
+// forward the values of received PONG events (the caller IDs) to the channel
+// pong. The call of ping and the waiting can be canceled with ctx (e.g. by a
+// deadline). If the ping call succeeded, but no matching PONG event was
+// received, ErrNoPong is returned.
+func (c *DeviceLayerClient) PingAndWait(ctx context.Context, callerID string, pong <-chan string) error {
+	ok, err := c.Ping(callerID)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("Method ping failed on %s", c.Name)
+	}
+	for {
+		select {
+		case id, ok := <-pong:
+			if !ok {
+				return ErrNoPong
+			}
+			if id == callerID {
+				return nil
+			}
+		case <-ctx.Done():
+			return ErrNoPong
+		}
+	}
+}
+
+// GetVersion retrieves the version of the interface process (e.g. 1.20.4).
+// Older interface processes do not support this method.
+func (c *DeviceLayerClient) GetVersion() (string, error) {
+	dclnLog.Debugf("Calling method getVersion on %s", c.Name)
+	// execute call
+	resp, err := c.Call("getVersion", []*xmlrpc.Value{})
+	if err != nil {
+		return "", err
+	}
+	q := xmlrpc.Q(resp)
+	version := q.String()
+	if q.Err() != nil {
+		return "", fmt.Errorf("Invalid response from method getVersion: %v", q.Err())
+	}
+	return version, nil
+}
+
+// InterfaceCapabilities describes the features of an interface process.
+// Optional calls (e.g. getServiceMessages, getLinks) should only be used, if
+// they are supported.
+type InterfaceCapabilities struct {
+	// Version of the interface process, empty if not available
+	Version string
+	// Supported XML-RPC methods
+	Methods map[string]bool
+}
+
+// Supports returns true, if the interface process supports the specified
+// XML-RPC method.
+func (ic *InterfaceCapabilities) Supports(method string) bool {
+	return ic.Methods[method]
+}
+
+// Capabilities retrieves the supported methods (system.listMethods) and the
+// version of the interface process.
+func (c *DeviceLayerClient) Capabilities() (*InterfaceCapabilities, error) {
+	dclnLog.Debugf("Calling method system.listMethods on %s", c.Name)
+	// execute call
+	resp, err := c.Call("system.listMethods", []*xmlrpc.Value{})
+	if err != nil {
+		return nil, err
+	}
+	q := xmlrpc.Q(resp)
+	methods := q.Strings()
+	if q.Err() != nil {
+		return nil, fmt.Errorf("Invalid response from method system.listMethods: %v", q.Err())
+	}
+	ic := &InterfaceCapabilities{Methods: make(map[string]bool, len(methods))}
+	for _, m := range methods {
+		ic.Methods[m] = true
+	}
+
+	// retrieve version, if supported
+	if ic.Supports("getVersion") {
+		ic.Version, err = c.GetVersion()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return ic, nil
+}
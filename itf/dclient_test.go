@@ -1,8 +1,12 @@
 package itf
 
 import (
+	"context"
+	"net/http/httptest"
 	"reflect"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/mdzio/go-hmccu/itf/xmlrpc"
 	"github.com/mdzio/go-lib/any"
@@ -167,3 +171,61 @@ func TestClient_Deinit(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+func TestClient_PingAndWait(t *testing.T) {
+	d := NewDispatcher()
+	d.AddDeviceLayer(&deviceLayer{})
+	srv := httptest.NewServer(&xmlrpc.Handler{Dispatcher: d})
+	defer srv.Close()
+	c := &DeviceLayerClient{
+		Name:   srv.URL,
+		Caller: &xmlrpc.Client{Addr: strings.TrimPrefix(srv.URL, "http://")},
+	}
+
+	// PONG received
+	pong := make(chan string, 2)
+	pong <- "other"
+	pong <- "abc"
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := c.PingAndWait(ctx, "abc", pong); err != nil {
+		t.Error(err)
+	}
+
+	// no PONG
+	ctx2, cancel2 := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel2()
+	if err := c.PingAndWait(ctx2, "abc", make(chan string)); err != ErrNoPong {
+		t.Error(err)
+	}
+
+	// ping call fails
+	if err := c.PingAndWait(ctx, "bad", pong); err == nil || err == ErrNoPong {
+		t.Error(err)
+	}
+}
+
+func TestClient_Capabilities(t *testing.T) {
+	di := NewDispatcher()
+	di.AddDeviceLayer(&deviceLayer{})
+	di.HandleFunc("getVersion", func(args *xmlrpc.Value) (*xmlrpc.Value, error) {
+		return xmlrpc.NewString("1.20.4"), nil
+	})
+	srv := httptest.NewServer(&xmlrpc.Handler{Dispatcher: di})
+	defer srv.Close()
+	cln := &DeviceLayerClient{
+		Name:   srv.URL,
+		Caller: &xmlrpc.Client{Addr: strings.TrimPrefix(srv.URL, "http://")},
+	}
+
+	ic, err := cln.Capabilities()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ic.Version != "1.20.4" {
+		t.Error(ic.Version)
+	}
+	if !ic.Supports("getParamset") || !ic.Supports("getLinks") || ic.Supports("getServiceMessages") {
+		t.Error(ic.Methods)
+	}
+}
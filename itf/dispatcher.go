@@ -111,6 +111,14 @@ type DeviceLayer interface {
 	Ping(callerID string) (bool, error)
 }
 
+// FirmwareUpdater can be implemented additionally by a DeviceLayer to support
+// firmware updates requested by the CCU.
+type FirmwareUpdater interface {
+	// UpdateFirmware starts the firmware update of the specified device. True
+	// is returned, if the update is started.
+	UpdateFirmware(deviceAddress string) (bool, error)
+}
+
 // Dispatcher is an extended xmlrpc.Dispatcher for HM.
 type Dispatcher struct {
 	xmlrpc.BasicDispatcher
@@ -335,12 +343,11 @@ func (d *Dispatcher) AddDeviceLayer(dl DeviceLayer) {
 		if err != nil {
 			return nil, err
 		}
-		// build XML-RPC array
-		arr := make([]*xmlrpc.Value, len(dds))
-		for idx := range dds {
-			arr[idx] = dds[idx].ToValue()
-		}
-		return &xmlrpc.Value{Array: &xmlrpc.Array{Data: arr}}, nil
+		// the XML-RPC array is built while encoding the response (large
+		// device lists)
+		return xmlrpc.NewArrayStream(len(dds), func(idx int) (*xmlrpc.Value, error) {
+			return dds[idx].ToValue(), nil
+		}), nil
 	})
 
 	// XML-RPC: void deleteDevice(String address, Integer flags)
@@ -509,6 +516,41 @@ func (d *Dispatcher) AddDeviceLayer(dl DeviceLayer) {
 		return xmlrpc.NewBool(res), nil
 	})
 
+	// XML-RPC: Boolean updateFirmware(String address) or Array<Boolean>
+	// updateFirmware(Array<String> addresses)
+	if fu, ok := dl.(FirmwareUpdater); ok {
+		d.HandleFunc("updateFirmware", func(args *xmlrpc.Value) (*xmlrpc.Value, error) {
+			q := xmlrpc.Q(args)
+			if len(q.Slice()) != 1 {
+				return nil, fmt.Errorf("Expected 1 argument for updateFirmware method: %d", len(q.Slice()))
+			}
+			arg := q.Idx(0)
+			var addresses []string
+			isArray := arg.Value() != nil && arg.Value().Array != nil
+			if isArray {
+				addresses = arg.Strings()
+			} else {
+				addresses = []string{arg.String()}
+			}
+			if q.Err() != nil {
+				return nil, fmt.Errorf("Invalid argument(s) for updateFirmware method: %v", q.Err())
+			}
+			svrLog.Debugf("Call of method updateFirmware received: %v", addresses)
+			res := make([]*xmlrpc.Value, len(addresses))
+			for i, a := range addresses {
+				ok, err := fu.UpdateFirmware(a)
+				if err != nil {
+					return nil, err
+				}
+				res[i] = xmlrpc.NewBool(ok)
+			}
+			if isArray {
+				return &xmlrpc.Value{Array: &xmlrpc.Array{Data: res}}, nil
+			}
+			return res[0], nil
+		})
+	}
+
 	// XML-RPC: Boolean reportValueUsage(String address, String value_id,
 	// Integer ref_counter)
 	//
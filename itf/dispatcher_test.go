@@ -296,3 +296,51 @@ func TestDeviceLayerServer(t *testing.T) {
 		t.Error(ret)
 	}
 }
+
+type updatableDeviceLayer struct {
+	deviceLayer
+}
+
+func (d *updatableDeviceLayer) UpdateFirmware(deviceAddress string) (bool, error) {
+	if deviceAddress == "ERR000000" {
+		return false, errors.New("update failed")
+	}
+	return deviceAddress == "ABC000000", nil
+}
+
+func TestUpdateFirmware(t *testing.T) {
+	di := NewDispatcher()
+	di.AddDeviceLayer(&updatableDeviceLayer{})
+	srv := httptest.NewServer(&xmlrpc.Handler{Dispatcher: di})
+	defer srv.Close()
+	cln := &xmlrpc.Client{Addr: strings.TrimPrefix(srv.URL, "http://")}
+
+	res, err := cln.Call("updateFirmware", xmlrpc.Values{xmlrpc.NewString("ABC000000")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if q := xmlrpc.Q(res); !q.Bool() || q.Err() != nil {
+		t.Error(res)
+	}
+
+	res, err = cln.Call("updateFirmware", xmlrpc.Values{xmlrpc.NewStrings([]string{"ABC000000", "DEF000000"})})
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := xmlrpc.Q(res)
+	if !q.Idx(0).Bool() || q.Idx(1).Bool() || q.Err() != nil {
+		t.Error(res)
+	}
+
+	_, err = cln.Call("updateFirmware", xmlrpc.Values{xmlrpc.NewString("ERR000000")})
+	if err == nil {
+		t.Error("expected error")
+	}
+
+	// device layer without firmware update
+	di = NewDispatcher()
+	di.AddDeviceLayer(&deviceLayer{})
+	if _, err := di.Dispatch("updateFirmware", &xmlrpc.Value{Array: &xmlrpc.Array{}}); err == nil {
+		t.Error("expected error")
+	}
+}
@@ -2,6 +2,7 @@ package itf
 
 import (
 	"errors"
+	"fmt"
 	"net/http"
 	"strconv"
 	"strings"
@@ -16,6 +17,25 @@ const (
 	rpcPath = "/RPC2"
 )
 
+// Endpoints of the HMServer (HmIP-Server) on the CCU. Both use the same port,
+// but are different endpoints: The interface process VirtualDevices (device
+// layer) is reachable under path /groups. The HMServer as logic layer
+// receives events under path /bidcos. If these are mixed up, events are not
+// delivered.
+const (
+	// HMServerPort is the port of the HMServer, reachable from outside of the
+	// CCU.
+	HMServerPort = 9292
+	// HMServerInternalPort is the port of the HMServer, only reachable inside
+	// the CCU.
+	HMServerInternalPort = 39292
+	// HMServerGroupsPath is the path of the interface process VirtualDevices.
+	HMServerGroupsPath = "/groups"
+	// HMServerBidCosPath is the path of the HMServer for receiving events as
+	// logic layer.
+	HMServerBidCosPath = "/bidcos"
+)
+
 var iLog = logging.Get("itf-intercon")
 
 // Type is the type of a CCU interface (BidCos-RF, HmIP-RF, ...).
@@ -125,7 +145,7 @@ var (
 		BidCosRF:       {"BidCos-RF", "", 2001, false},
 		System:         {"System", "", 2002, false},
 		HmIPRF:         {"HmIP-RF", "", 2010, false},
-		VirtualDevices: {"VirtualDevices", "/groups", 9292, false},
+		VirtualDevices: {"VirtualDevices", HMServerGroupsPath, HMServerPort, false},
 		CUxD:           {"CUxD", "", 8701, true},
 		HausBusDe:      {"HausBusDe", "", 8766, false},
 	}
@@ -150,9 +170,45 @@ type Interconnector struct {
 	binrpcServer *binrpc.Server
 }
 
+// registrationID returns the ID for registering at the specified interface.
+func (i *Interconnector) registrationID(t Type) string {
+	cfg := configs[t]
+	if cfg.cuxd {
+		// ID can not be customized with CUxD
+		return cfg.reGaHssID
+	}
+	return i.IDPrefix + cfg.reGaHssID
+}
+
+// checkRegistrationIDs checks, whether the registration IDs of all configured
+// interfaces are unique.
+func (i *Interconnector) checkRegistrationIDs() error {
+	types := make(map[string]Type)
+	for _, t := range i.Types {
+		id := i.registrationID(t)
+		if other, ok := types[id]; ok {
+			return fmt.Errorf("Duplicate registration ID %s for interfaces %s and %s (check interface types and ID prefix)", id, other, t)
+		}
+		types[id] = t
+		if configs[t].cuxd && i.IDPrefix != "" {
+			iLog.Warningf("ID prefix %s is not applied to interface %s", i.IDPrefix, t)
+		}
+	}
+	return nil
+}
+
 // Start connects to the CCU and starts querying model and values. An additional
-// handler for XMLRPC ist registered at the DefaultServeMux.
+// handler for XMLRPC ist registered at the DefaultServeMux. If the
+// registration IDs of the interfaces are not unique, an error is signaled on
+// ServeErr.
 func (i *Interconnector) Start() {
+	// check configuration
+	if err := i.checkRegistrationIDs(); err != nil {
+		// signal error, do not block
+		go func() { i.ServeErr <- err }()
+		return
+	}
+
 	// HM RPC dispatcher
 	dispatcher := NewDispatcher()
 	dispatcher.AddLogicLayer(i)
@@ -184,17 +240,16 @@ func (i *Interconnector) Start() {
 
 		// CUXD BIN-RPC or standard XML-RPC?
 		var caller xmlrpc.Caller
-		var regAddr, regID string
+		var regAddr string
+		regID := i.registrationID(itfType)
 		if cfg.cuxd {
 			// create BIN-RPC client
 			caller = &binrpc.Client{Addr: addr}
 			regAddr = "xmlrpc_bin://" + i.HostAddr + ":" + strconv.Itoa(i.BINRPCPort)
-			regID = cfg.reGaHssID // ID can not be customized with CUxD
 		} else {
 			// create standard XML-RPC client
 			caller = &xmlrpc.Client{Addr: addr}
 			regAddr = "http://" + i.HostAddr + ":" + strconv.Itoa(i.XMLRPCPort) + rpcPath
-			regID = i.IDPrefix + cfg.reGaHssID
 		}
 
 		// create client
@@ -268,7 +323,7 @@ func (i *Interconnector) Event(interfaceID, address, valueKey string, value inte
 	i.callbackReceived(interfaceID)
 
 	// discard pong event
-	if valueKey == "PONG" && strings.HasPrefix(address, "CENTRAL") {
+	if IsPong(address, valueKey) {
 		iLog.Trace("Discarding PONG event")
 		return nil
 	}
@@ -0,0 +1,22 @@
+package itf
+
+import "testing"
+
+func TestInterconnectorRegistrationIDs(t *testing.T) {
+	i := &Interconnector{Types: Types{BidCosRF, HmIPRF, CUxD}, IDPrefix: "abc-"}
+	if err := i.checkRegistrationIDs(); err != nil {
+		t.Error(err)
+	}
+	if id := i.registrationID(HmIPRF); id != "abc-HmIP-RF" {
+		t.Error(id)
+	}
+	if id := i.registrationID(CUxD); id != "CUxD" {
+		t.Error(id)
+	}
+
+	i = &Interconnector{Types: Types{BidCosRF, HmIPRF, BidCosRF}}
+	err := i.checkRegistrationIDs()
+	if err == nil || err.Error() != "Duplicate registration ID BidCos-RF for interfaces BidCosRF and BidCosRF (check interface types and ID prefix)" {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,231 @@
+// Package jsonrpc exposes a DeviceLayer over JSON-RPC 2.0 (HTTP POST). The
+// method names and parameters are the same as for XML-RPC (e.g. listDevices,
+// getValue, setValue).
+package jsonrpc
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/mdzio/go-hmccu/itf"
+	"github.com/mdzio/go-hmccu/itf/xmlrpc"
+	"github.com/mdzio/go-logging"
+)
+
+// max. size of a valid request, if not specified: 10 MB
+const requestSizeLimit = 10 * 1024 * 1024
+
+// error codes of JSON-RPC 2.0
+const (
+	codeParseError     = -32700
+	codeInvalidRequest = -32600
+	codeMethodNotFound = -32601
+	codeInvalidParams  = -32602
+	// HomeMatic compatible code for errors of the device layer
+	codeGeneric = -1
+)
+
+var log = logging.Get("jsonrpc-server")
+
+// Request is a JSON-RPC request.
+type Request struct {
+	JSONRPC string            `json:"jsonrpc"`
+	Method  string            `json:"method"`
+	Params  []json.RawMessage `json:"params"`
+	ID      json.RawMessage   `json:"id,omitempty"`
+}
+
+// Response is a JSON-RPC response.
+type Response struct {
+	JSONRPC string          `json:"jsonrpc"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *Error          `json:"error,omitempty"`
+	ID      json.RawMessage `json:"id"`
+}
+
+// Error is a JSON-RPC error object.
+type Error struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// Error implements the error interface.
+func (e *Error) Error() string {
+	return fmt.Sprintf("JSON-RPC error (code: %d, message: %s)", e.Code, e.Message)
+}
+
+// Handler implements a http.Handler, which dispatches JSON-RPC requests to a
+// DeviceLayer. The device and parameter descriptions are encoded with the
+// standard JSON marshaling of the itf types.
+type Handler struct {
+	DeviceLayer      itf.DeviceLayer
+	RequestSizeLimit int64
+}
+
+func (h *Handler) ServeHTTP(resp http.ResponseWriter, req *http.Request) {
+	if log.TraceEnabled() {
+		log.Tracef("Request received from %s, URI %s", req.RemoteAddr, xmlrpc.Redact(req.RequestURI))
+	}
+	if req.Method != http.MethodPost {
+		http.Error(resp, "Method not allowed: "+req.Method, http.StatusMethodNotAllowed)
+		return
+	}
+
+	// read request
+	limit := h.RequestSizeLimit
+	if limit == 0 {
+		limit = requestSizeLimit
+	}
+	reqBuf, err := io.ReadAll(io.LimitReader(req.Body, limit+1))
+	if err != nil {
+		log.Errorf("Reading of request failed from %s: %v", req.RemoteAddr, err)
+		http.Error(resp, "Reading of request failed: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if int64(len(reqBuf)) > limit {
+		log.Errorf("Request from %s exceeds size limit of %d bytes", req.RemoteAddr, limit)
+		http.Error(resp, fmt.Sprintf("Request exceeds size limit of %d bytes", limit), http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	// decode and dispatch request
+	var r Request
+	var res interface{}
+	err = json.Unmarshal(reqBuf, &r)
+	if err != nil {
+		err = &Error{Code: codeParseError, Message: "Decoding of request failed: " + err.Error()}
+	} else if r.JSONRPC != "2.0" || r.Method == "" {
+		err = &Error{Code: codeInvalidRequest, Message: "Invalid JSON-RPC 2.0 request"}
+	} else {
+		log.Debugf("Call of method %s received from %s", r.Method, req.RemoteAddr)
+		res, err = h.dispatch(r.Method, r.Params)
+	}
+
+	// build response
+	jresp := &Response{JSONRPC: "2.0", ID: r.ID}
+	if len(jresp.ID) == 0 {
+		jresp.ID = json.RawMessage("null")
+	}
+	if err != nil {
+		log.Warningf("Sending error response to %s: %v", req.RemoteAddr, err)
+		var jerr *Error
+		if !errors.As(err, &jerr) {
+			jerr = &Error{Code: codeGeneric, Message: err.Error()}
+		}
+		jresp.Error = jerr
+	} else {
+		jresp.Result, err = json.Marshal(res)
+		if err != nil {
+			log.Errorf("Encoding of result for %s failed: %v", req.RemoteAddr, err)
+			jresp.Error = &Error{Code: codeGeneric, Message: "Encoding of result failed: " + err.Error()}
+		}
+	}
+	respBuf, err := json.Marshal(jresp)
+	if err != nil {
+		log.Errorf("Encoding of response for %s failed: %v", req.RemoteAddr, err)
+		http.Error(resp, "Encoding of response failed: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// send response
+	resp.Header().Set("Content-Type", "application/json")
+	resp.Header().Set("Content-Length", strconv.Itoa(len(respBuf)))
+	_, err = resp.Write(respBuf)
+	if err != nil {
+		log.Warningf("Sending of response for %s failed: %v", req.RemoteAddr, err)
+	}
+}
+
+func (h *Handler) dispatch(method string, params []json.RawMessage) (interface{}, error) {
+	dl := h.DeviceLayer
+	switch method {
+	case "init":
+		var receiverAddress, interfaceID string
+		if err := decodeParams(params, &receiverAddress, &interfaceID); err != nil {
+			return nil, err
+		}
+		return "", dl.Init(receiverAddress, interfaceID)
+	case "listDevices":
+		if err := decodeParams(params); err != nil {
+			return nil, err
+		}
+		return dl.ListDevices()
+	case "deleteDevice":
+		var address string
+		var flags int
+		if err := decodeParams(params, &address, &flags); err != nil {
+			return nil, err
+		}
+		return "", dl.DeleteDevice(address, flags)
+	case "getDeviceDescription":
+		var address string
+		if err := decodeParams(params, &address); err != nil {
+			return nil, err
+		}
+		return dl.GetDeviceDescription(address)
+	case "getParamsetDescription":
+		var address, paramsetType string
+		if err := decodeParams(params, &address, &paramsetType); err != nil {
+			return nil, err
+		}
+		return dl.GetParamsetDescription(address, paramsetType)
+	case "getParamset":
+		var address, paramsetKey string
+		if err := decodeParams(params, &address, &paramsetKey); err != nil {
+			return nil, err
+		}
+		return dl.GetParamset(address, paramsetKey)
+	case "putParamset":
+		var address, paramsetKey string
+		var values map[string]interface{}
+		if err := decodeParams(params, &address, &paramsetKey, &values); err != nil {
+			return nil, err
+		}
+		return "", dl.PutParamset(address, paramsetKey, values)
+	case "getValue":
+		var address, valueKey string
+		if err := decodeParams(params, &address, &valueKey); err != nil {
+			return nil, err
+		}
+		return dl.GetValue(address, valueKey)
+	case "setValue":
+		var address, valueKey string
+		var value interface{}
+		if err := decodeParams(params, &address, &valueKey, &value); err != nil {
+			return nil, err
+		}
+		return "", dl.SetValue(address, valueKey, value)
+	case "ping":
+		var callerID string
+		if err := decodeParams(params, &callerID); err != nil {
+			return nil, err
+		}
+		return dl.Ping(callerID)
+	default:
+		return nil, &Error{Code: codeMethodNotFound, Message: "Unknown method: " + method}
+	}
+}
+
+// decodeParams decodes the parameters into the specified targets. The number
+// of parameters must match.
+func decodeParams(params []json.RawMessage, targets ...interface{}) error {
+	if len(params) != len(targets) {
+		return &Error{
+			Code:    codeInvalidParams,
+			Message: fmt.Sprintf("Invalid number of parameters: %d, expected: %d", len(params), len(targets)),
+		}
+	}
+	for i, p := range params {
+		if err := json.Unmarshal(p, targets[i]); err != nil {
+			return &Error{
+				Code:    codeInvalidParams,
+				Message: fmt.Sprintf("Invalid parameter %d: %v", i+1, err),
+			}
+		}
+	}
+	return nil
+}
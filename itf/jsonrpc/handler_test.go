@@ -0,0 +1,78 @@
+package jsonrpc
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/mdzio/go-hmccu/itf/vdevices"
+	_ "github.com/mdzio/go-lib/testutil"
+)
+
+func TestHandler(t *testing.T) {
+	// virtual devices
+	c := vdevices.NewContainer()
+	dl := vdevices.NewHandler("", c, nil)
+	defer dl.Close()
+	c.Synchronizer = dl
+	dev := vdevices.NewDevice("JCK000", "HmIP-PSM", dl)
+	vdevices.NewMaintenanceChannel(dev)
+	dimmer := vdevices.NewDimmerChannel(dev)
+	c.AddDevice(dev)
+
+	srv := httptest.NewServer(&Handler{DeviceLayer: dl})
+	defer srv.Close()
+
+	cases := []struct {
+		req, want string
+	}{
+		{
+			`{"jsonrpc":"2.0","method":"getValue","params":["JCK000:1","LEVEL"],"id":1}`,
+			`{"jsonrpc":"2.0","result":0,"id":1}`,
+		},
+		{
+			`{"jsonrpc":"2.0","method":"setValue","params":["JCK000:1","LEVEL",0.5],"id":"a"}`,
+			`{"jsonrpc":"2.0","result":"","id":"a"}`,
+		},
+		{
+			`{"jsonrpc":"2.0","method":"getValue","params":["JCK000:1","LEVEL"],"id":2}`,
+			`{"jsonrpc":"2.0","result":0.5,"id":2}`,
+		},
+		{
+			`{"jsonrpc":"2.0","method":"getDeviceDescription","params":["JCK000:1"],"id":3}`,
+			`{"jsonrpc":"2.0","result":{"Type":"DIMMER","Address":"JCK000:1","RFAddress":0,"Children":null,"Parent":"JCK000","ParentType":"HmIP-PSM","Index":1,"AESActive":0,"Paramsets":["MASTER","VALUES"],"Firmware":"","AvailableFirmware":"","Version":1,"Flags":1,"LinkSourceRoles":"","LinkTargetRoles":"","Direction":0,"Group":"","Team":"","TeamTag":"","TeamChannels":null,"Interface":"","Roaming":0,"RXMode":0,"Updatable":0,"AddressSpec":""},"id":3}`,
+		},
+		{
+			`{"jsonrpc":"2.0","method":"getValue","params":["JCK000:1"],"id":4}`,
+			`{"jsonrpc":"2.0","error":{"code":-32602,"message":"Invalid number of parameters: 1, expected: 2"},"id":4}`,
+		},
+		{
+			`{"jsonrpc":"2.0","method":"getValue","params":["JCK000:1","UNKNOWN"],"id":5}`,
+			`{"jsonrpc":"2.0","error":{"code":-1,"message":"Unknown parameter: UNKNOWN"},"id":5}`,
+		},
+		{
+			`{"jsonrpc":"2.0","method":"unknown","id":6}`,
+			`{"jsonrpc":"2.0","error":{"code":-32601,"message":"Unknown method: unknown"},"id":6}`,
+		},
+		{
+			`invalid`,
+			`{"jsonrpc":"2.0","error":{"code":-32700,"message":"Decoding of request failed: invalid character 'i' looking for beginning of value"},"id":null}`,
+		},
+	}
+	for _, c := range cases {
+		resp, err := http.Post(srv.URL, "application/json", bytes.NewBufferString(c.req))
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if string(body) != c.want {
+			t.Errorf("request: %s, unexpected response: %s", c.req, string(body))
+		}
+	}
+	if dimmer.Level() != 0.5 {
+		t.Error(dimmer.Level())
+	}
+}
@@ -72,6 +72,56 @@ func (c *LogicLayerClient) Event(interfaceID, address, valueKey string, value in
 	return nil
 }
 
+// Event describes a value change of a parameter (see LogicLayerClient.Events).
+type Event struct {
+	Address  string
+	ValueKey string
+	Value    interface{}
+}
+
+// Events signals multiple value changes to the logic layer with a single
+// system.multicall.
+func (c *LogicLayerClient) Events(interfaceID string, events []Event) error {
+	lclnLog.Debugf("Calling method event %d times with system.multicall on %s", len(events), c.Name)
+	// build calls
+	calls := make([]*xmlrpc.Value, 0, len(events))
+	for _, e := range events {
+		v, err := xmlrpc.NewValue(e.Value)
+		if err != nil {
+			return err
+		}
+		calls = append(calls, &xmlrpc.Value{Struct: &xmlrpc.Struct{Members: []*xmlrpc.Member{
+			{Name: "methodName", Value: xmlrpc.NewString("event")},
+			{Name: "params", Value: &xmlrpc.Value{Array: &xmlrpc.Array{Data: []*xmlrpc.Value{
+				xmlrpc.NewString(interfaceID),
+				xmlrpc.NewString(e.Address),
+				xmlrpc.NewString(e.ValueKey),
+				v,
+			}}}},
+		}}})
+	}
+	// execute call
+	resp, err := c.Call("system.multicall", []*xmlrpc.Value{{Array: &xmlrpc.Array{Data: calls}}})
+	if err != nil {
+		return err
+	}
+	// a failed call is reported as fault struct in the result array
+	if resp.Array != nil {
+		for idx, r := range resp.Array.Data {
+			if r.Struct != nil && idx < len(events) {
+				q := xmlrpc.Q(r)
+				faultString := q.Key("faultString").String()
+				if q.Err() != nil {
+					return fmt.Errorf("Invalid response for method event in system.multicall: %v", q.Err())
+				}
+				e := events[idx]
+				return fmt.Errorf("Method event(%s, %s) in system.multicall failed: %s", e.Address, e.ValueKey, faultString)
+			}
+		}
+	}
+	return nil
+}
+
 // NewDevices adds devices to the logic layer.
 func (c *LogicLayerClient) NewDevices(interfaceID string, devDescriptions []*DeviceDescription) error {
 	if lclnLog.DebugEnabled() {
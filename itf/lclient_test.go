@@ -43,6 +43,33 @@ func TestLogicLayerClient(t *testing.T) {
 	}
 }
 
+func TestLogicLayerClientEvents(t *testing.T) {
+	l := &logicLayer{msg: make(chan string, 2)}
+	d := NewDispatcher()
+	d.AddLogicLayer(l)
+	h := &xmlrpc.Handler{Dispatcher: d}
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+	cln := LogicLayerClient{
+		Name:   "LogicLayerClient",
+		Caller: &xmlrpc.Client{Addr: strings.TrimPrefix(srv.URL, "http://")},
+	}
+
+	err := cln.Events("itfID", []Event{
+		{Address: "ABC00000:1", ValueKey: "STATE", Value: true},
+		{Address: "ABC00000:2", ValueKey: "LEVEL", Value: 0.5},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg := <-l.msg; msg != "itfID ABC00000:1 STATE true" {
+		t.Fatal("first event invalid:", msg)
+	}
+	if msg := <-l.msg; msg != "itfID ABC00000:2 LEVEL 0.5" {
+		t.Fatal("second event invalid:", msg)
+	}
+}
+
 func TestAssertEmptyResponse(t *testing.T) {
 	cases := []struct {
 		xml string
@@ -0,0 +1,255 @@
+package itf
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// multiUnknownRetry is the minimum time between two retrievals of the device
+// lists for the same unknown device.
+const multiUnknownRetry = 10 * time.Second
+
+// MultiDeviceLayer merges multiple DeviceLayer's into one. The devices of all
+// layers are listed together. Calls for a specific device are routed to the
+// layer, which lists the device (ownership). If a device address is listed by
+// more than one layer, the first layer wins. Init and Deinit are forwarded to
+// all layers. Ping is only forwarded to the layers needed to reach all
+// registered logic layers, so that PONG events are not duplicated.
+type MultiDeviceLayer struct {
+	Layers []DeviceLayer
+
+	mtx       sync.Mutex
+	owners    map[string]DeviceLayer // key: device address
+	unknown   map[string]time.Time   // key: device address, value: time of lookup
+	receivers map[int]receivers      // registered logic layers, key: index of the layer
+	now       func() time.Time
+}
+
+// receivers is a set of receiver addresses.
+type receivers map[string]bool
+
+// check interface implementation
+var _ DeviceLayer = (*MultiDeviceLayer)(nil)
+
+// NewMultiDeviceLayer creates a MultiDeviceLayer. The order of the layers is
+// significant for resolving duplicate device addresses.
+func NewMultiDeviceLayer(layers ...DeviceLayer) *MultiDeviceLayer {
+	return &MultiDeviceLayer{Layers: layers}
+}
+
+// Init implements DeviceLayer. The first error is returned, but all layers
+// are called.
+func (m *MultiDeviceLayer) Init(receiverAddress, interfaceID string) error {
+	var first error
+	for i, l := range m.Layers {
+		if err := l.Init(receiverAddress, interfaceID); err != nil {
+			if first == nil {
+				first = err
+			}
+			continue
+		}
+		m.mtx.Lock()
+		if m.receivers == nil {
+			m.receivers = make(map[int]receivers)
+		}
+		if m.receivers[i] == nil {
+			m.receivers[i] = make(receivers)
+		}
+		m.receivers[i][receiverAddress] = true
+		m.mtx.Unlock()
+	}
+	return first
+}
+
+// Deinit implements DeviceLayer. The first error is returned, but all layers
+// are called.
+func (m *MultiDeviceLayer) Deinit(receiverAddress string) error {
+	var first error
+	for i, l := range m.Layers {
+		if err := l.Deinit(receiverAddress); err != nil && first == nil {
+			first = err
+		}
+		m.mtx.Lock()
+		delete(m.receivers[i], receiverAddress)
+		m.mtx.Unlock()
+	}
+	return first
+}
+
+// ListDevices implements DeviceLayer. The ownership of the devices is updated.
+func (m *MultiDeviceLayer) ListDevices() ([]*DeviceDescription, error) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	return m.refresh()
+}
+
+// DeleteDevice implements DeviceLayer.
+func (m *MultiDeviceLayer) DeleteDevice(deviceAddress string, flags int) error {
+	l, err := m.owner(deviceAddress)
+	if err != nil {
+		return err
+	}
+	return l.DeleteDevice(deviceAddress, flags)
+}
+
+// GetDeviceDescription implements DeviceLayer.
+func (m *MultiDeviceLayer) GetDeviceDescription(deviceAddress string) (*DeviceDescription, error) {
+	l, err := m.owner(deviceAddress)
+	if err != nil {
+		return nil, err
+	}
+	return l.GetDeviceDescription(deviceAddress)
+}
+
+// GetParamsetDescription implements DeviceLayer.
+func (m *MultiDeviceLayer) GetParamsetDescription(deviceAddress, paramsetType string) (ParamsetDescription, error) {
+	l, err := m.owner(deviceAddress)
+	if err != nil {
+		return nil, err
+	}
+	return l.GetParamsetDescription(deviceAddress, paramsetType)
+}
+
+// GetParamset implements DeviceLayer.
+func (m *MultiDeviceLayer) GetParamset(deviceAddress string, paramsetKey string) (map[string]interface{}, error) {
+	l, err := m.owner(deviceAddress)
+	if err != nil {
+		return nil, err
+	}
+	return l.GetParamset(deviceAddress, paramsetKey)
+}
+
+// PutParamset implements DeviceLayer.
+func (m *MultiDeviceLayer) PutParamset(deviceAddress string, paramsetType string, paramset map[string]interface{}) error {
+	l, err := m.owner(deviceAddress)
+	if err != nil {
+		return err
+	}
+	return l.PutParamset(deviceAddress, paramsetType, paramset)
+}
+
+// SetValue implements DeviceLayer.
+func (m *MultiDeviceLayer) SetValue(deviceAddress string, valueName string, value interface{}) error {
+	l, err := m.owner(deviceAddress)
+	if err != nil {
+		return err
+	}
+	return l.SetValue(deviceAddress, valueName, value)
+}
+
+// GetValue implements DeviceLayer.
+func (m *MultiDeviceLayer) GetValue(deviceAddress string, valueName string) (interface{}, error) {
+	l, err := m.owner(deviceAddress)
+	if err != nil {
+		return nil, err
+	}
+	return l.GetValue(deviceAddress, valueName)
+}
+
+// Ping implements DeviceLayer. Ping is forwarded to the first layer and to
+// further layers only, if they have registered logic layers, which are not
+// reached by the previous ones. True is only returned, if all called layers
+// return true.
+func (m *MultiDeviceLayer) Ping(callerID string) (bool, error) {
+	res := true
+	for _, l := range m.pingLayers() {
+		ok, err := l.Ping(callerID)
+		if err != nil {
+			return false, err
+		}
+		res = res && ok
+	}
+	return res, nil
+}
+
+// pingLayers returns the layers, which must be pinged to reach all registered
+// logic layers. At least the first layer is returned.
+func (m *MultiDeviceLayer) pingLayers() []DeviceLayer {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	var ls []DeviceLayer
+	reached := make(receivers)
+	for i, l := range m.Layers {
+		add := i == 0
+		for r := range m.receivers[i] {
+			if !reached[r] {
+				reached[r] = true
+				add = true
+			}
+		}
+		if add {
+			ls = append(ls, l)
+		}
+	}
+	return ls
+}
+
+// owner returns the layer owning the specified device or channel. If the
+// device is unknown, the device lists are retrieved again. For the same
+// unknown device this is done at most every multiUnknownRetry.
+func (m *MultiDeviceLayer) owner(address string) (DeviceLayer, error) {
+	deviceAddr, _ := SplitAddress(address)
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	if l, ok := m.owners[deviceAddr]; ok {
+		return l, nil
+	}
+	now := time.Now()
+	if m.now != nil {
+		now = m.now()
+	}
+	if t, ok := m.unknown[deviceAddr]; ok && now.Sub(t) < multiUnknownRetry {
+		return nil, fmt.Errorf("Unknown device: %s", address)
+	}
+	if _, err := m.refresh(); err != nil {
+		return nil, err
+	}
+	if l, ok := m.owners[deviceAddr]; ok {
+		return l, nil
+	}
+	// remember unknown device, remove expired entries
+	if m.unknown == nil {
+		m.unknown = make(map[string]time.Time)
+	}
+	for a, t := range m.unknown {
+		if now.Sub(t) >= multiUnknownRetry {
+			delete(m.unknown, a)
+		}
+	}
+	m.unknown[deviceAddr] = now
+	return nil, fmt.Errorf("Unknown device: %s", address)
+}
+
+// refresh retrieves the device lists of all layers and rebuilds the ownership.
+// The mutex must be locked.
+func (m *MultiDeviceLayer) refresh() ([]*DeviceDescription, error) {
+	owners := make(map[string]DeviceLayer)
+	var descrs []*DeviceDescription
+	for _, l := range m.Layers {
+		dds, err := l.ListDevices()
+		if err != nil {
+			return nil, err
+		}
+		// device addresses of this layer
+		own := make(map[string]bool)
+		for _, dd := range dds {
+			deviceAddr, _ := SplitAddress(dd.Address)
+			if _, ok := owners[deviceAddr]; ok && !own[deviceAddr] {
+				svrLog.Warningf("Device is provided by multiple device layers, ignoring: %s", dd.Address)
+				continue
+			}
+			own[deviceAddr] = true
+			owners[deviceAddr] = l
+			descrs = append(descrs, dd)
+		}
+	}
+	m.owners = owners
+	// devices may have become known
+	for a := range m.unknown {
+		if _, ok := owners[a]; ok {
+			delete(m.unknown, a)
+		}
+	}
+	return descrs, nil
+}
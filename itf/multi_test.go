@@ -0,0 +1,151 @@
+package itf
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type otherDeviceLayer struct {
+	deviceLayer
+}
+
+func (d *otherDeviceLayer) ListDevices() ([]*DeviceDescription, error) {
+	return []*DeviceDescription{
+		{Type: "MY-TYPE", Address: "ABC000000", RFAddress: 2},
+		{Type: "OTHER-TYPE", Address: "DEF000000"},
+		{Type: "OTHER-CHANNEL", Address: "DEF000000:1", Parent: "DEF000000"},
+	}, nil
+}
+
+func (d *otherDeviceLayer) GetValue(deviceAddress string, valueName string) (interface{}, error) {
+	if deviceAddress != "DEF000000:1" || valueName != "LEVEL" {
+		return nil, errors.New("bad params")
+	}
+	return 456, nil
+}
+
+func TestMultiDeviceLayer(t *testing.T) {
+	m := NewMultiDeviceLayer(&deviceLayer{}, &otherDeviceLayer{})
+
+	dds, err := m.ListDevices()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []*DeviceDescription{
+		{Type: "MY-TYPE", Address: "ABC000000", RFAddress: 1},
+		{Type: "OTHER-TYPE", Address: "DEF000000"},
+		{Type: "OTHER-CHANNEL", Address: "DEF000000:1", Parent: "DEF000000"},
+	}
+	if !reflect.DeepEqual(dds, want) {
+		t.Error(dds)
+	}
+
+	v, err := m.GetValue("ABC000000:1", "LEVEL")
+	if err != nil {
+		t.Error(err)
+	} else if v != 123 {
+		t.Error(v)
+	}
+	v, err = m.GetValue("DEF000000:1", "LEVEL")
+	if err != nil {
+		t.Error(err)
+	} else if v != 456 {
+		t.Error(v)
+	}
+
+	_, err = m.GetValue("XYZ000000:1", "LEVEL")
+	if err == nil || err.Error() != "Unknown device: XYZ000000:1" {
+		t.Error(err)
+	}
+
+	err = m.Init("http://abc", "logicLayerID")
+	if err != nil {
+		t.Error(err)
+	}
+	ok, err := m.Ping("abc")
+	if err != nil || !ok {
+		t.Error(ok, err)
+	}
+}
+
+type countingDeviceLayer struct {
+	deviceLayer
+	lists, pings int
+	failInit     bool
+}
+
+func (d *countingDeviceLayer) Init(receiverAddress, interfaceID string) error {
+	if d.failInit {
+		return errors.New("init failed")
+	}
+	return d.deviceLayer.Init(receiverAddress, interfaceID)
+}
+
+func (d *countingDeviceLayer) ListDevices() ([]*DeviceDescription, error) {
+	d.lists++
+	return d.deviceLayer.ListDevices()
+}
+
+func (d *countingDeviceLayer) Ping(callerID string) (bool, error) {
+	d.pings++
+	return d.deviceLayer.Ping(callerID)
+}
+
+func TestMultiDeviceLayerUnknown(t *testing.T) {
+	l := &countingDeviceLayer{}
+	m := NewMultiDeviceLayer(l)
+	now := time.Unix(1000, 0)
+	m.now = func() time.Time { return now }
+
+	for i := 0; i < 3; i++ {
+		if _, err := m.GetValue("XYZ000000:1", "LEVEL"); err == nil {
+			t.Error("expected error")
+		}
+	}
+	if l.lists != 1 {
+		t.Error(l.lists)
+	}
+	// other unknown device
+	m.GetValue("UVW000000:1", "LEVEL")
+	if l.lists != 2 {
+		t.Error(l.lists)
+	}
+	// retry after some time
+	now = now.Add(multiUnknownRetry)
+	m.GetValue("XYZ000000:1", "LEVEL")
+	if l.lists != 3 {
+		t.Error(l.lists)
+	}
+}
+
+func TestMultiDeviceLayerPing(t *testing.T) {
+	l1, l2 := &countingDeviceLayer{}, &countingDeviceLayer{}
+	m := NewMultiDeviceLayer(l1, l2)
+	if err := m.Init("http://abc", "logicLayerID"); err != nil {
+		t.Fatal(err)
+	}
+	// logic layer is registered at both layers, only one PONG is needed
+	if ok, err := m.Ping("abc"); err != nil || !ok {
+		t.Error(ok, err)
+	}
+	if l1.pings != 1 || l2.pings != 0 {
+		t.Error(l1.pings, l2.pings)
+	}
+
+	// logic layer is only registered at the second layer
+	if err := m.Deinit("http://abc"); err != nil {
+		t.Fatal(err)
+	}
+	l1.failInit = true
+	if err := m.Init("http://abc", "logicLayerID"); err == nil {
+		t.Error("expected error")
+	}
+	if ok, err := m.Ping("abc"); err != nil || !ok {
+		t.Error(ok, err)
+	}
+	if l1.pings != 2 || l2.pings != 1 {
+		t.Error(l1.pings, l2.pings)
+	}
+}
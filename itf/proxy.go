@@ -0,0 +1,92 @@
+package itf
+
+import (
+	"github.com/mdzio/go-logging"
+)
+
+var prxLog = logging.Get("itf-proxy")
+
+// ProxyDeviceLayer is a DeviceLayer, which forwards all calls to an upstream
+// interface process (e.g. of a real CCU). It can be used as a man-in-the-middle
+// for logging RPC traffic or as base for a virtual interface, which augments a
+// real one.
+//
+// Attention: Init and Deinit are forwarded unchanged. The upstream interface
+// process sends its callbacks directly to the specified receiver address.
+type ProxyDeviceLayer struct {
+	Client *DeviceLayerClient
+}
+
+// check interface implementation
+var _ DeviceLayer = (*ProxyDeviceLayer)(nil)
+
+// NewProxyDeviceLayer creates a ProxyDeviceLayer for the specified client.
+func NewProxyDeviceLayer(client *DeviceLayerClient) *ProxyDeviceLayer {
+	return &ProxyDeviceLayer{Client: client}
+}
+
+// Init implements DeviceLayer.
+func (p *ProxyDeviceLayer) Init(receiverAddress, interfaceID string) error {
+	prxLog.Tracef("Forwarding init(%s, %s) to %s", receiverAddress, interfaceID, p.Client.Name)
+	return p.Client.Init(receiverAddress, interfaceID)
+}
+
+// Deinit implements DeviceLayer.
+func (p *ProxyDeviceLayer) Deinit(receiverAddress string) error {
+	prxLog.Tracef("Forwarding deinit(%s) to %s", receiverAddress, p.Client.Name)
+	return p.Client.Deinit(receiverAddress)
+}
+
+// ListDevices implements DeviceLayer.
+func (p *ProxyDeviceLayer) ListDevices() ([]*DeviceDescription, error) {
+	prxLog.Tracef("Forwarding listDevices to %s", p.Client.Name)
+	return p.Client.ListDevices()
+}
+
+// DeleteDevice implements DeviceLayer.
+func (p *ProxyDeviceLayer) DeleteDevice(deviceAddress string, flags int) error {
+	prxLog.Tracef("Forwarding deleteDevice(%s, %d) to %s", deviceAddress, flags, p.Client.Name)
+	return p.Client.DeleteDevice(deviceAddress, flags)
+}
+
+// GetDeviceDescription implements DeviceLayer.
+func (p *ProxyDeviceLayer) GetDeviceDescription(deviceAddress string) (*DeviceDescription, error) {
+	prxLog.Tracef("Forwarding getDeviceDescription(%s) to %s", deviceAddress, p.Client.Name)
+	return p.Client.GetDeviceDescription(deviceAddress)
+}
+
+// GetParamsetDescription implements DeviceLayer.
+func (p *ProxyDeviceLayer) GetParamsetDescription(deviceAddress, paramsetType string) (ParamsetDescription, error) {
+	prxLog.Tracef("Forwarding getParamsetDescription(%s, %s) to %s", deviceAddress, paramsetType, p.Client.Name)
+	return p.Client.GetParamsetDescription(deviceAddress, paramsetType)
+}
+
+// GetParamset implements DeviceLayer.
+func (p *ProxyDeviceLayer) GetParamset(deviceAddress string, paramsetKey string) (map[string]interface{}, error) {
+	prxLog.Tracef("Forwarding getParamset(%s, %s) to %s", deviceAddress, paramsetKey, p.Client.Name)
+	return p.Client.GetParamset(deviceAddress, paramsetKey)
+}
+
+// PutParamset implements DeviceLayer.
+func (p *ProxyDeviceLayer) PutParamset(deviceAddress string, paramsetType string, paramset map[string]interface{}) error {
+	prxLog.Tracef("Forwarding putParamset(%s, %s) to %s", deviceAddress, paramsetType, p.Client.Name)
+	return p.Client.PutParamset(deviceAddress, paramsetType, paramset)
+}
+
+// SetValue implements DeviceLayer.
+func (p *ProxyDeviceLayer) SetValue(deviceAddress string, valueName string, value interface{}) error {
+	prxLog.Tracef("Forwarding setValue(%s, %s, %v) to %s", deviceAddress, valueName, value, p.Client.Name)
+	return p.Client.SetValue(deviceAddress, valueName, value)
+}
+
+// GetValue implements DeviceLayer.
+func (p *ProxyDeviceLayer) GetValue(deviceAddress string, valueName string) (interface{}, error) {
+	prxLog.Tracef("Forwarding getValue(%s, %s) to %s", deviceAddress, valueName, p.Client.Name)
+	return p.Client.GetValue(deviceAddress, valueName)
+}
+
+// Ping implements DeviceLayer.
+func (p *ProxyDeviceLayer) Ping(callerID string) (bool, error) {
+	prxLog.Tracef("Forwarding ping(%s) to %s", callerID, p.Client.Name)
+	return p.Client.Ping(callerID)
+}
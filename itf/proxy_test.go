@@ -0,0 +1,70 @@
+package itf
+
+import (
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/mdzio/go-hmccu/itf/xmlrpc"
+)
+
+func TestProxyDeviceLayer(t *testing.T) {
+	// upstream interface process
+	ud := NewDispatcher()
+	ud.AddDeviceLayer(&deviceLayer{})
+	usrv := httptest.NewServer(&xmlrpc.Handler{Dispatcher: ud})
+	defer usrv.Close()
+
+	// proxy
+	prx := NewProxyDeviceLayer(&DeviceLayerClient{
+		Name:   usrv.URL,
+		Caller: &xmlrpc.Client{Addr: strings.TrimPrefix(usrv.URL, "http://")},
+	})
+	pd := NewDispatcher()
+	pd.AddDeviceLayer(prx)
+	psrv := httptest.NewServer(&xmlrpc.Handler{Dispatcher: pd})
+	defer psrv.Close()
+
+	cln := DeviceLayerClient{
+		Name:   psrv.URL,
+		Caller: &xmlrpc.Client{Addr: strings.TrimPrefix(psrv.URL, "http://")},
+	}
+
+	err := cln.Init("http://abc", "logicLayerID")
+	if err != nil {
+		t.Error(err)
+	}
+	err = cln.Init("http://force-error", "logicLayerID")
+	if err == nil {
+		t.Error("expected error")
+	} else if !reflect.DeepEqual(err, &xmlrpc.MethodError{Code: 21, Message: "msg"}) {
+		t.Error(err)
+	}
+
+	dds, err := cln.ListDevices()
+	if err != nil {
+		t.Error(err)
+	} else if !reflect.DeepEqual(dds, []*DeviceDescription{{Type: "MY-TYPE", Address: "ABC000000", RFAddress: 1}}) {
+		t.Error(dds)
+	}
+
+	v, err := cln.GetValue("ABC000000:1", "LEVEL")
+	if err != nil {
+		t.Error(err)
+	} else if v != 123 {
+		t.Error(v)
+	}
+
+	err = cln.SetValue("ABC000000:1", "LEVEL", 123)
+	if err != nil {
+		t.Error(err)
+	}
+
+	ret, err := cln.Ping("abc")
+	if err != nil {
+		t.Error(err)
+	} else if ret != true {
+		t.Error(ret)
+	}
+}
@@ -1,6 +1,7 @@
 package itf
 
 import (
+	"sync"
 	"time"
 )
 
@@ -25,6 +26,11 @@ type RegisteredClient struct {
 	stopped     chan struct{}
 	callback    chan struct{}
 	timer       *time.Timer
+
+	// liveness state, accessed by callback goroutines and the monitor
+	mtx          sync.Mutex
+	lastCallback time.Time
+	connected    bool
 }
 
 // Setup initializes the RegisteredClient.
@@ -102,6 +108,7 @@ func (i *RegisteredClient) Start() {
 			case <-i.timer.C:
 				// register again, if ping timed out
 				dclnLog.Errorf("CCU interface %s timed out", i.ReGaHssID)
+				i.setConnected(false)
 				i.register()
 			}
 			i.timer.Reset(callbackTimeout)
@@ -118,6 +125,10 @@ func (i *RegisteredClient) Stop() {
 // CallbackReceived must be called, when a callback from the CCU is received.
 // The call is always non-blocking. Startup must be called first.
 func (i *RegisteredClient) CallbackReceived() {
+	i.mtx.Lock()
+	i.lastCallback = time.Now()
+	i.mtx.Unlock()
+
 	// try to send
 	select {
 	case i.callback <- struct{}{}:
@@ -126,14 +137,39 @@ func (i *RegisteredClient) CallbackReceived() {
 	}
 }
 
+// LastCallback returns the time of the last received callback. The zero time
+// is returned, if no callback was received yet.
+func (i *RegisteredClient) LastCallback() time.Time {
+	i.mtx.Lock()
+	defer i.mtx.Unlock()
+	return i.lastCallback
+}
+
+// Connected returns true, if the registration at the CCU interface process
+// succeeded and the connection has not timed out since.
+func (i *RegisteredClient) Connected() bool {
+	i.mtx.Lock()
+	defer i.mtx.Unlock()
+	return i.connected
+}
+
+func (i *RegisteredClient) setConnected(connected bool) {
+	i.mtx.Lock()
+	i.connected = connected
+	i.mtx.Unlock()
+}
+
 func (i *RegisteredClient) register() {
 	// register for callbacks (events, ...)
-	if err := i.Init(i.RegistrationURL, i.RegistrationID); err != nil {
+	err := i.Init(i.RegistrationURL, i.RegistrationID)
+	if err != nil {
 		dclnLog.Warning(err)
 	}
+	i.setConnected(err == nil)
 }
 
 func (i *RegisteredClient) unregister() {
+	i.setConnected(false)
 	// stop callbacks
 	if err := i.Deinit(i.RegistrationURL); err != nil {
 		dclnLog.Warning(err)
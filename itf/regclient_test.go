@@ -0,0 +1,73 @@
+package itf
+
+import (
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mdzio/go-hmccu/itf/xmlrpc"
+)
+
+func TestRegisteredClientLiveness(t *testing.T) {
+	di := NewDispatcher()
+	di.AddDeviceLayer(&deviceLayer{})
+	srv := httptest.NewServer(&xmlrpc.Handler{Dispatcher: di})
+	defer srv.Close()
+
+	rc := &RegisteredClient{
+		DeviceLayerClient: &DeviceLayerClient{
+			Name:   srv.URL,
+			Caller: &xmlrpc.Client{Addr: strings.TrimPrefix(srv.URL, "http://")},
+		},
+		RegistrationURL: "http://abc",
+		RegistrationID:  "logicLayerID",
+		ReGaHssID:       "Test",
+	}
+	rc.Setup()
+	if !rc.LastCallback().IsZero() || rc.Connected() {
+		t.Fatal("unexpected initial state")
+	}
+	rc.Start()
+
+	// fire callbacks, while the liveness state is read (run with -race)
+	var wg sync.WaitGroup
+	stop := make(chan struct{})
+	for n := 0; n < 4; n++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				rc.CallbackReceived()
+				rc.LastCallback()
+				rc.Connected()
+				time.Sleep(time.Millisecond)
+			}
+		}()
+	}
+
+	// wait for registration
+	deadline := time.Now().Add(startupDelay + 2*time.Second)
+	for !rc.Connected() && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	close(stop)
+	wg.Wait()
+	if !rc.Connected() {
+		t.Error("expected connected")
+	}
+	if time.Since(rc.LastCallback()) > time.Second {
+		t.Error(rc.LastCallback())
+	}
+
+	rc.Stop()
+	if rc.Connected() {
+		t.Error("expected disconnected")
+	}
+}
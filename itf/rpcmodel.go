@@ -2,6 +2,7 @@ package itf
 
 import (
 	"fmt"
+	"sort"
 	"strings"
 
 	"github.com/mdzio/go-hmccu/itf/xmlrpc"
@@ -28,6 +29,15 @@ const (
 	DeviceRXModeLazyConfig
 )
 
+// Flags for the method deleteDevice: DeleteFlagReset resets the device to
+// factory settings, DeleteFlagForce deletes the device even if it is not
+// reachable, and DeleteFlagDefer deletes the device as soon as it is reachable.
+const (
+	DeleteFlagReset = 1 << iota
+	DeleteFlagForce
+	DeleteFlagDefer
+)
+
 // DeviceDescription describes a HomeMatic device.
 type DeviceDescription struct {
 	Type              string
@@ -72,6 +82,30 @@ type DeviceDescription struct {
 	// 0x08: wakeup (after communication with the CCU)
 	// 0x10: lazy config (config mode after normal use, e.g. key press)
 	RXMode int
+
+	// Updatable is 1, if a firmware update of the device is possible (see
+	// AvailableFirmware).
+	Updatable int
+
+	// AddressSpec describes the addressing of the device (newer firmware).
+	AddressSpec string
+
+	// Extra contains struct members, which are not known by this library (e.g.
+	// added by newer firmware). Structs and arrays are stored as
+	// map[string]interface{} and []interface{}.
+	Extra map[string]interface{} `json:",omitempty"`
+}
+
+// deviceDescriptionMembers contains the names of the struct members, which are
+// mapped to fields of DeviceDescription.
+var deviceDescriptionMembers = map[string]bool{
+	"TYPE": true, "ADDRESS": true, "RF_ADDRESS": true, "CHILDREN": true,
+	"PARENT": true, "PARENT_TYPE": true, "INDEX": true, "AES_ACTIVE": true,
+	"PARAMSETS": true, "FIRMWARE": true, "AVAILABLE_FIRMWARE": true,
+	"VERSION": true, "FLAGS": true, "LINK_SOURCE_ROLES": true,
+	"LINK_TARGET_ROLES": true, "DIRECTION": true, "GROUP": true, "TEAM": true,
+	"TEAM_TAG": true, "TEAM_CHANNELS": true, "INTERFACE": true, "ROAMING": true,
+	"RX_MODE": true, "UPDATABLE": true, "ADDRESS_SPEC": true,
 }
 
 // ReadFrom reads the field values from an xmlrpc.Query.
@@ -79,18 +113,12 @@ func (d *DeviceDescription) ReadFrom(e *xmlrpc.Query) {
 	d.Type = e.TryKey("TYPE").String()
 	d.Address = e.TryKey("ADDRESS").String()
 	d.RFAddress = e.TryKey("RF_ADDRESS").Int()
-	// The interface VirtualDevices of the CCU returns an empty XML-RPC value
-	// instead of an empty XML-RPC array, if the device has no children.
-	c := e.TryKey("CHILDREN")
-	if c.IsNotEmpty() {
-		// If not empty, it must be an array of strings.
-		d.Children = c.Strings()
-	}
+	d.Children = tryStrings(e.TryKey("CHILDREN"))
 	d.Parent = e.TryKey("PARENT").String()
 	d.ParentType = e.TryKey("PARENT_TYPE").String()
 	d.Index = e.TryKey("INDEX").Int()
 	d.AESActive = e.TryKey("AES_ACTIVE").Int()
-	d.Paramsets = e.TryKey("PARAMSETS").Strings()
+	d.Paramsets = tryStrings(e.TryKey("PARAMSETS"))
 	d.Firmware = e.TryKey("FIRMWARE").String()
 	d.AvailableFirmware = e.TryKey("AVAILABLE_FIRMWARE").String()
 	d.Version = e.TryKey("VERSION").Int()
@@ -101,15 +129,47 @@ func (d *DeviceDescription) ReadFrom(e *xmlrpc.Query) {
 	d.Group = e.TryKey("GROUP").String()
 	d.Team = e.TryKey("TEAM").String()
 	d.TeamTag = e.TryKey("TEAM_TAG").String()
-	d.TeamChannels = e.TryKey("TEAM_CHANNELS").Strings()
+	d.TeamChannels = tryStrings(e.TryKey("TEAM_CHANNELS"))
 	d.Interface = e.TryKey("INTERFACE").String()
 	d.Roaming = e.TryKey("ROAMING").Int()
 	d.RXMode = e.TryKey("RX_MODE").Int()
+	// some interface processes send a boolean
+	u := e.TryKey("UPDATABLE")
+	if u.Value() != nil && u.Value().Boolean != "" {
+		if u.Bool() {
+			d.Updatable = 1
+		}
+	} else {
+		d.Updatable = u.Int()
+	}
+	d.AddressSpec = e.TryKey("ADDRESS_SPEC").String()
+
+	// collect unknown members
+	for n, m := range e.Map() {
+		if !deviceDescriptionMembers[n] {
+			if d.Extra == nil {
+				d.Extra = make(map[string]interface{})
+			}
+			d.Extra[n] = anyValue(m)
+		}
+	}
 }
 
-// ToValue returns an xmlrpc.Value for this device description.
+// tryStrings reads an array of strings. The interface VirtualDevices of the CCU
+// returns an empty XML-RPC value instead of an empty XML-RPC array (e.g. if the
+// device has no children). In this case nil is returned.
+func tryStrings(q *xmlrpc.Query) []string {
+	if !q.IsNotEmpty() {
+		return nil
+	}
+	// If not empty, it must be an array of strings.
+	return q.Strings()
+}
+
+// ToValue returns an xmlrpc.Value for this device description. Extra members,
+// which can not be converted, are skipped.
 func (d *DeviceDescription) ToValue() *xmlrpc.Value {
-	return &xmlrpc.Value{
+	v := &xmlrpc.Value{
 		Struct: &xmlrpc.Struct{Members: []*xmlrpc.Member{
 			{Name: "TYPE", Value: xmlrpc.NewString(d.Type)},
 			{Name: "ADDRESS", Value: xmlrpc.NewString(d.Address)},
@@ -136,6 +196,142 @@ func (d *DeviceDescription) ToValue() *xmlrpc.Value {
 			{Name: "RX_MODE", Value: xmlrpc.NewInt(d.RXMode)},
 		}},
 	}
+	// optional members, which are not provided by all interfaces
+	if d.Updatable != 0 {
+		v.Struct.Members = append(v.Struct.Members, &xmlrpc.Member{Name: "UPDATABLE", Value: xmlrpc.NewInt(d.Updatable)})
+	}
+	if d.AddressSpec != "" {
+		v.Struct.Members = append(v.Struct.Members, &xmlrpc.Member{Name: "ADDRESS_SPEC", Value: xmlrpc.NewString(d.AddressSpec)})
+	}
+
+	// append extra members in a stable order
+	names := make([]string, 0, len(d.Extra))
+	for n := range d.Extra {
+		if !deviceDescriptionMembers[n] {
+			names = append(names, n)
+		}
+	}
+	sort.Strings(names)
+	for _, n := range names {
+		ev, err := xmlrpc.NewValue(d.Extra[n])
+		if err != nil {
+			svrLog.Warningf("Skipping member %s of device description %s: %v", n, d.Address, err)
+			continue
+		}
+		v.Struct.Members = append(v.Struct.Members, &xmlrpc.Member{Name: n, Value: ev})
+	}
+	return v
+}
+
+// ChannelTypes returns the types of the channels of this device in the order
+// of Children. The channel descriptions are looked up in all (e.g. the result
+// of ListDevices). Missing channels are skipped.
+func (d *DeviceDescription) ChannelTypes(all []*DeviceDescription) []string {
+	byAddr := make(map[string]*DeviceDescription, len(all))
+	for _, dd := range all {
+		byAddr[dd.Address] = dd
+	}
+	var types []string
+	for _, c := range d.Children {
+		if cd, ok := byAddr[c]; ok {
+			types = append(types, cd.Type)
+		}
+	}
+	return types
+}
+
+// IsTeamMember returns true, if the channel is member of a team (e.g.
+// interconnected smoke detectors). Team contains the address of the team
+// channel.
+func (d *DeviceDescription) IsTeamMember() bool {
+	return d.Team != ""
+}
+
+// IsTeam returns true, if the channel represents a team. TeamChannels contains
+// the addresses of the member channels.
+func (d *DeviceDescription) IsTeam() bool {
+	return len(d.TeamChannels) != 0
+}
+
+// TeamMembers returns the descriptions of the members of a team from the
+// specified descriptions (e.g. the result of ListDevices). The order of
+// TeamChannels is retained, unknown members are skipped.
+func TeamMembers(team *DeviceDescription, descrs []*DeviceDescription) []*DeviceDescription {
+	byAddr := make(map[string]*DeviceDescription, len(descrs))
+	for _, dd := range descrs {
+		byAddr[dd.Address] = dd
+	}
+	var members []*DeviceDescription
+	for _, a := range team.TeamChannels {
+		if dd, ok := byAddr[a]; ok {
+			members = append(members, dd)
+		}
+	}
+	return members
+}
+
+// The answer to a ping (see DeviceLayer.Ping) is delivered to all registered
+// logic layers as pseudo-event with the address CentralAddress, the value key
+// PongKey and the caller ID as value. A Receiver should use IsPong to filter
+// these events, because they do not belong to a device.
+const (
+	CentralAddress = "CENTRAL"
+	PongKey        = "PONG"
+)
+
+// IsPong returns true, if the event is the answer to a ping. Some interface
+// processes append a channel number to CentralAddress (e.g. CENTRAL:0).
+func IsPong(address, valueKey string) bool {
+	return valueKey == PongKey && strings.HasPrefix(address, CentralAddress)
+}
+
+// GroupAddressPrefix is the address prefix of the group (meta) devices (e.g.
+// HmIP heating groups) of the interface process VirtualDevices.
+const GroupAddressPrefix = "INT"
+
+// IsGroup returns true, if the device or channel is a group (meta) device
+// (e.g. a HmIP heating group). Group devices are provided by the interface
+// process VirtualDevices, which is reachable under HMServerGroupsPath on
+// HMServerPort (interface type VirtualDevices). Their values are read and
+// written like those of physical devices, but over this interface.
+func (d *DeviceDescription) IsGroup() bool {
+	if d.Interface == configs[VirtualDevices].reGaHssID {
+		return true
+	}
+	// the device address consists of the prefix and digits (e.g. INT0000001)
+	deviceAddr, _ := SplitAddress(d.Address)
+	if !strings.HasPrefix(deviceAddr, GroupAddressPrefix) || len(deviceAddr) == len(GroupAddressPrefix) {
+		return false
+	}
+	for _, r := range deviceAddr[len(GroupAddressPrefix):] {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// anyValue converts an XML-RPC value into a native data type. Structs and
+// arrays are converted recursively.
+func anyValue(q *xmlrpc.Query) interface{} {
+	v := q.Value()
+	switch {
+	case v != nil && v.Struct != nil:
+		m := make(map[string]interface{})
+		for n, mq := range q.Map() {
+			m[n] = anyValue(mq)
+		}
+		return m
+	case v != nil && v.Array != nil:
+		qs := q.Slice()
+		a := make([]interface{}, len(qs))
+		for i, eq := range qs {
+			a[i] = anyValue(eq)
+		}
+		return a
+	default:
+		return q.Any()
+	}
 }
 
 const (
@@ -145,6 +341,7 @@ const (
 	ParameterTypeEnum    = "ENUM"
 	ParameterTypeString  = "STRING"
 	ParameterTypeAction  = "ACTION"
+	ParameterTypeAlarm   = "ALARM"
 )
 
 const (
@@ -161,6 +358,34 @@ const (
 	ParameterFlagSticky
 )
 
+// DiffDevices compares two lists of device descriptions by address. added
+// contains the descriptions only in newDescrs, removed the descriptions only in
+// oldDescrs. changed contains the descriptions from newDescrs, whose version,
+// flags or firmware differ from the old ones.
+func DiffDevices(oldDescrs, newDescrs []*DeviceDescription) (added, removed, changed []*DeviceDescription) {
+	olds := make(map[string]*DeviceDescription, len(oldDescrs))
+	for _, o := range oldDescrs {
+		olds[o.Address] = o
+	}
+	news := make(map[string]bool, len(newDescrs))
+	for _, n := range newDescrs {
+		news[n.Address] = true
+		o, ok := olds[n.Address]
+		if !ok {
+			added = append(added, n)
+		} else if o.Version != n.Version || o.Flags != n.Flags ||
+			o.Firmware != n.Firmware || o.AvailableFirmware != n.AvailableFirmware {
+			changed = append(changed, n)
+		}
+	}
+	for _, o := range oldDescrs {
+		if !news[o.Address] {
+			removed = append(removed, o)
+		}
+	}
+	return
+}
+
 // SpecialValue defines a special value für an INTEGER or FLOAT. Value must be
 // of type int or float64.
 type SpecialValue struct {
@@ -168,6 +393,49 @@ type SpecialValue struct {
 	Value interface{}
 }
 
+// ReadSpecialValues reads an array of special values ({ID, VALUE} structs).
+// valueType must be FLOAT or INTEGER and determines the type of the VALUE
+// members (float64 or int). Errors are reported by the query.
+func ReadSpecialValues(q *xmlrpc.Query, valueType string) []SpecialValue {
+	var svs []SpecialValue
+	for _, s := range q.Slice() {
+		id := s.Key("ID").String()
+		var val interface{}
+		switch valueType {
+		case "FLOAT":
+			val = s.Key("VALUE").Float64()
+		case "INTEGER":
+			val = s.Key("VALUE").Int()
+		default:
+			val = s.Key("VALUE").Any()
+		}
+		svs = append(svs, SpecialValue{id, val})
+	}
+	return svs
+}
+
+// NewSpecialValues returns an xmlrpc.Value (array of {ID, VALUE} structs) for
+// the special values.
+func NewSpecialValues(svs []SpecialValue) (*xmlrpc.Value, error) {
+	es := make([]*xmlrpc.Value, len(svs))
+	for i := range svs {
+		var sv *xmlrpc.Value
+		switch ev := svs[i].Value.(type) {
+		case float64:
+			sv = xmlrpc.NewFloat64(ev)
+		case int:
+			sv = xmlrpc.NewInt(ev)
+		default:
+			return nil, fmt.Errorf("Expected type int or float64 for SPECIAL property of parameter description: %T", ev)
+		}
+		es[i] = &xmlrpc.Value{Struct: &xmlrpc.Struct{Members: []*xmlrpc.Member{
+			{Name: "ID", Value: xmlrpc.NewString(svs[i].ID)},
+			{Name: "VALUE", Value: sv},
+		}}}
+	}
+	return &xmlrpc.Value{Array: &xmlrpc.Array{Data: es}}, nil
+}
+
 // ParameterDescription describes a single parameter.
 type ParameterDescription struct {
 	// FLOAT, INTEGER, BOOL, ENUM, STRING, ACTION
@@ -209,20 +477,10 @@ func (p *ParameterDescription) ReadFrom(e *xmlrpc.Query) {
 
 	// read special properties
 	switch p.Type {
-	case "FLOAT":
-		for _, s := range e.TryKey("SPECIAL").Slice() {
-			id := s.Key("ID").String()
-			val := s.Key("VALUE").Float64()
-			p.Special = append(p.Special, SpecialValue{id, val})
-		}
-	case "INTEGER":
-		for _, s := range e.TryKey("SPECIAL").Slice() {
-			id := s.Key("ID").String()
-			val := s.Key("VALUE").Int()
-			p.Special = append(p.Special, SpecialValue{id, val})
-		}
+	case "FLOAT", "INTEGER":
+		p.Special = ReadSpecialValues(e.TryKey("SPECIAL"), p.Type)
 	case "ENUM":
-		p.ValueList = e.TryKey("VALUE_LIST").Strings()
+		p.ValueList = tryStrings(e.TryKey("VALUE_LIST"))
 	}
 }
 
@@ -261,24 +519,12 @@ func (p *ParameterDescription) ToValue() (*xmlrpc.Value, error) {
 	case "FLOAT":
 		fallthrough
 	case "INTEGER":
-		es := make([]*xmlrpc.Value, len(p.Special))
-		for i := range p.Special {
-			var sv *xmlrpc.Value
-			switch ev := p.Special[i].Value.(type) {
-			case float64:
-				sv = xmlrpc.NewFloat64(ev)
-			case int:
-				sv = xmlrpc.NewInt(ev)
-			default:
-				return nil, fmt.Errorf("Expected type int or float64 for SPECIAL property of parameter description: %T", ev)
-			}
-			es[i] = &xmlrpc.Value{Struct: &xmlrpc.Struct{Members: []*xmlrpc.Member{
-				{Name: "ID", Value: xmlrpc.NewString(p.Special[i].ID)},
-				{Name: "VALUE", Value: sv},
-			}}}
+		sv, err := NewSpecialValues(p.Special)
+		if err != nil {
+			return nil, err
 		}
 		v.Struct.Members = append(v.Struct.Members, &xmlrpc.Member{
-			Name: "SPECIAL", Value: &xmlrpc.Value{Array: &xmlrpc.Array{Data: es}},
+			Name: "SPECIAL", Value: sv,
 		})
 	case "ENUM":
 		v.Struct.Members = append(v.Struct.Members, &xmlrpc.Member{
@@ -288,6 +534,26 @@ func (p *ParameterDescription) ToValue() (*xmlrpc.Value, error) {
 	return v, nil
 }
 
+// EnumName returns the symbolic name of an ENUM value (index into
+// ValueList). False is returned, if the index is out of range.
+func (p *ParameterDescription) EnumName(index int) (string, bool) {
+	if index < 0 || index >= len(p.ValueList) {
+		return "", false
+	}
+	return p.ValueList[index], true
+}
+
+// EnumIndex returns the ENUM value (index into ValueList) for a symbolic name.
+// False is returned, if the name is not found.
+func (p *ParameterDescription) EnumIndex(name string) (int, bool) {
+	for i, n := range p.ValueList {
+		if n == name {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
 // ParamsetDescription describes a parameter set (e.g. VALUES) of a device.
 type ParamsetDescription map[string]*ParameterDescription
 
@@ -318,6 +584,21 @@ func (ps ParamsetDescription) ToValue() (*xmlrpc.Value, error) {
 	return &xmlrpc.Value{Struct: &xmlrpc.Struct{Members: ms}}, nil
 }
 
+// Sorted returns the parameter descriptions ordered by TabOrder and then by ID.
+func (ps ParamsetDescription) Sorted() []*ParameterDescription {
+	ds := make([]*ParameterDescription, 0, len(ps))
+	for _, p := range ps {
+		ds = append(ds, p)
+	}
+	sort.Slice(ds, func(i, j int) bool {
+		if ds[i].TabOrder != ds[j].TabOrder {
+			return ds[i].TabOrder < ds[j].TabOrder
+		}
+		return ds[i].ID < ds[j].ID
+	})
+	return ds
+}
+
 // SplitAddress splits a full address into device and channel part.
 func SplitAddress(address string) (deviceAddress string, channelAddress string) {
 	if p := strings.IndexRune(address, ':'); p == -1 {
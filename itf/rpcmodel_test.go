@@ -32,6 +32,8 @@ func TestDeviceDescription(t *testing.T) {
 		Interface:         "r",
 		Roaming:           7,
 		RXMode:            8,
+		Updatable:         1,
+		AddressSpec:       "s",
 	}
 	q := xmlrpc.Q(want.ToValue())
 	got := &DeviceDescription{}
@@ -44,6 +46,132 @@ func TestDeviceDescription(t *testing.T) {
 	}
 }
 
+func TestDeviceDescriptionEmptyArrays(t *testing.T) {
+	// interface VirtualDevices sends empty values instead of empty arrays
+	v := &xmlrpc.Value{Struct: &xmlrpc.Struct{Members: []*xmlrpc.Member{
+		{Name: "ADDRESS", Value: xmlrpc.NewString("INT0000001")},
+		{Name: "CHILDREN", Value: &xmlrpc.Value{}},
+		{Name: "PARAMSETS", Value: &xmlrpc.Value{}},
+		{Name: "TEAM_CHANNELS", Value: &xmlrpc.Value{FlatString: ""}},
+	}}}
+	q := xmlrpc.Q(v)
+	d := &DeviceDescription{}
+	d.ReadFrom(q)
+	if q.Err() != nil {
+		t.Fatal(q.Err())
+	}
+	if d.Address != "INT0000001" || d.Children != nil || d.Paramsets != nil || d.TeamChannels != nil {
+		t.Error(d)
+	}
+
+	v = &xmlrpc.Value{Struct: &xmlrpc.Struct{Members: []*xmlrpc.Member{
+		{Name: "TYPE", Value: xmlrpc.NewString(ParameterTypeEnum)},
+		{Name: "VALUE_LIST", Value: &xmlrpc.Value{}},
+	}}}
+	q = xmlrpc.Q(v)
+	p := &ParameterDescription{}
+	p.ReadFrom(q)
+	if q.Err() != nil {
+		t.Fatal(q.Err())
+	}
+	if p.ValueList != nil {
+		t.Error(p.ValueList)
+	}
+}
+
+func TestDeviceDescriptionIsGroup(t *testing.T) {
+	cases := []struct {
+		d    DeviceDescription
+		want bool
+	}{
+		{DeviceDescription{Type: "HmIP-HEATING", Address: "INT0000001"}, true},
+		{DeviceDescription{Type: "HEATING_CLIMATECONTROL_TRANSCEIVER", Address: "INT0000001:1", Parent: "INT0000001"}, true},
+		{DeviceDescription{Type: "HmIP-HEATING", Address: "ABC", Interface: "VirtualDevices"}, true},
+		{DeviceDescription{Type: "HmIP-eTRV-2", Address: "000A18A9A64DAC"}, false},
+		{DeviceDescription{Type: "HM-CC-RT-DN", Address: "INTERN01"}, false},
+		{DeviceDescription{Type: "X", Address: "INT"}, false},
+	}
+	for _, c := range cases {
+		if got := c.d.IsGroup(); got != c.want {
+			t.Error(c.d.Address, got)
+		}
+	}
+}
+
+func TestDeviceDescriptionUpdatable(t *testing.T) {
+	v := &xmlrpc.Value{Struct: &xmlrpc.Struct{Members: []*xmlrpc.Member{
+		{Name: "ADDRESS", Value: xmlrpc.NewString("a")},
+		{Name: "UPDATABLE", Value: xmlrpc.NewBool(true)},
+	}}}
+	q := xmlrpc.Q(v)
+	d := &DeviceDescription{}
+	d.ReadFrom(q)
+	if q.Err() != nil {
+		t.Fatal(q.Err())
+	}
+	if d.Updatable != 1 || d.Extra != nil {
+		t.Error(d)
+	}
+}
+
+func TestDeviceDescriptionOptionalMembers(t *testing.T) {
+	has := func(d *DeviceDescription, name string) bool {
+		for _, m := range d.ToValue().Struct.Members {
+			if m.Name == name {
+				return true
+			}
+		}
+		return false
+	}
+	d := &DeviceDescription{Address: "a"}
+	if has(d, "UPDATABLE") || has(d, "ADDRESS_SPEC") {
+		t.Error("unexpected optional members")
+	}
+	d.Updatable = 1
+	d.AddressSpec = "spec"
+	if !has(d, "UPDATABLE") || !has(d, "ADDRESS_SPEC") {
+		t.Error("missing optional members")
+	}
+}
+
+func TestDeviceDescriptionExtra(t *testing.T) {
+	want := &DeviceDescription{
+		Type:    "a",
+		Address: "b",
+		Extra: map[string]interface{}{
+			"SUBTYPE":  "c",
+			"NUMBER":   1,
+			"LIST":     []interface{}{"d", 2},
+			"NESTED":   map[string]interface{}{"E": true},
+			"FRACTION": 0.5,
+		},
+	}
+	v := want.ToValue()
+	q := xmlrpc.Q(v)
+	got := &DeviceDescription{}
+	got.ReadFrom(q)
+	if q.Err() != nil {
+		t.Fatal(q.Err())
+	}
+	if !reflect.DeepEqual(want, got) {
+		t.Fatal(got)
+	}
+	// extra members are appended in sorted order
+	ms := v.Struct.Members
+	if ms[len(ms)-5].Name != "FRACTION" || ms[len(ms)-1].Name != "SUBTYPE" {
+		t.Error(ms[len(ms)-5].Name, ms[len(ms)-1].Name)
+	}
+
+	// known members can not be overwritten
+	d := &DeviceDescription{Address: "b", Extra: map[string]interface{}{"ADDRESS": "x", "BAD": struct{}{}}}
+	q = xmlrpc.Q(d.ToValue())
+	got = &DeviceDescription{}
+	got.ReadFrom(q)
+	if got.Address != "b" || got.Extra != nil {
+		t.Error(got)
+	}
+}
+
 func TestParameterDescription(t *testing.T) {
 	cases := []*ParameterDescription{
 		{
@@ -150,3 +278,144 @@ func TestParamsetDescription(t *testing.T) {
 		t.Fatal(got)
 	}
 }
+
+func TestDiffDevices(t *testing.T) {
+	oldDescrs := []*DeviceDescription{
+		{Address: "A", Version: 1},
+		{Address: "B", Version: 1},
+		{Address: "C", Version: 1, Firmware: "1.0"},
+	}
+	newDescrs := []*DeviceDescription{
+		{Address: "B", Version: 2},
+		{Address: "C", Version: 1, Firmware: "1.0"},
+		{Address: "D", Version: 1},
+	}
+	added, removed, changed := DiffDevices(oldDescrs, newDescrs)
+	if !reflect.DeepEqual(added, newDescrs[2:3]) {
+		t.Error(added)
+	}
+	if !reflect.DeepEqual(removed, oldDescrs[0:1]) {
+		t.Error(removed)
+	}
+	if !reflect.DeepEqual(changed, newDescrs[0:1]) {
+		t.Error(changed)
+	}
+}
+
+func TestParamsetDescriptionSorted(t *testing.T) {
+	ps := ParamsetDescription{
+		"C": &ParameterDescription{ID: "C", TabOrder: 1},
+		"B": &ParameterDescription{ID: "B", TabOrder: 2},
+		"A": &ParameterDescription{ID: "A", TabOrder: 2},
+	}
+	var ids []string
+	for _, p := range ps.Sorted() {
+		ids = append(ids, p.ID)
+	}
+	if !reflect.DeepEqual(ids, []string{"C", "A", "B"}) {
+		t.Error(ids)
+	}
+}
+
+func TestParameterDescriptionEnum(t *testing.T) {
+	p := &ParameterDescription{Type: ParameterTypeEnum, ValueList: []string{"NORMAL", "UNKNOWN", "OVERFLOW"}}
+	if n, ok := p.EnumName(2); !ok || n != "OVERFLOW" {
+		t.Error(n, ok)
+	}
+	if _, ok := p.EnumName(3); ok {
+		t.Error("expected out of range")
+	}
+	if _, ok := p.EnumName(-1); ok {
+		t.Error("expected out of range")
+	}
+	if i, ok := p.EnumIndex("UNKNOWN"); !ok || i != 1 {
+		t.Error(i, ok)
+	}
+	if _, ok := p.EnumIndex("unknown"); ok {
+		t.Error("expected not found")
+	}
+}
+
+func TestSpecialValues(t *testing.T) {
+	svs := []SpecialValue{
+		{ID: "Zero", Value: 0},
+		{ID: "One", Value: 1},
+	}
+	v, err := NewSpecialValues(svs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := xmlrpc.Q(v)
+	if got := ReadSpecialValues(q, "INTEGER"); !reflect.DeepEqual(got, svs) {
+		t.Error(got)
+	}
+	if q.Err() != nil {
+		t.Error(q.Err())
+	}
+	svs = []SpecialValue{
+		{ID: "Zero", Value: 0.0},
+		{ID: "One", Value: 1.0},
+	}
+	v, err = NewSpecialValues(svs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	q = xmlrpc.Q(v)
+	if got := ReadSpecialValues(q, "FLOAT"); !reflect.DeepEqual(got, svs) {
+		t.Error(got)
+	}
+	if q.Err() != nil {
+		t.Error(q.Err())
+	}
+
+	_, err = NewSpecialValues([]SpecialValue{{ID: "X", Value: "abc"}})
+	if err == nil {
+		t.Error("expected error")
+	}
+}
+
+func TestChannelTypes(t *testing.T) {
+	all := []*DeviceDescription{
+		{Type: "HmIP-PSM", Address: "A", Children: []string{"A:0", "A:1", "A:2"}},
+		{Type: "MAINTENANCE", Address: "A:0", Parent: "A"},
+		{Type: "SWITCH_VIRTUAL_RECEIVER", Address: "A:2", Parent: "A"},
+	}
+	types := all[0].ChannelTypes(all)
+	if !reflect.DeepEqual(types, []string{"MAINTENANCE", "SWITCH_VIRTUAL_RECEIVER"}) {
+		t.Error(types)
+	}
+	if types := all[1].ChannelTypes(all); types != nil {
+		t.Error(types)
+	}
+}
+
+func TestIsPong(t *testing.T) {
+	cases := []struct {
+		address, valueKey string
+		want              bool
+	}{
+		{"CENTRAL", "PONG", true},
+		{"CENTRAL:0", "PONG", true},
+		{"CENTRAL", "STATE", false},
+		{"ABC000000:1", "PONG", false},
+	}
+	for _, c := range cases {
+		if got := IsPong(c.address, c.valueKey); got != c.want {
+			t.Errorf("%s.%s: %t", c.address, c.valueKey, got)
+		}
+	}
+}
+
+func TestTeamMembers(t *testing.T) {
+	team := &DeviceDescription{Address: "*JEQ0000001:1", TeamChannels: []string{"JEQ0000002:1", "JEQ0000009:1", "JEQ0000003:1"}}
+	m1 := &DeviceDescription{Address: "JEQ0000002:1", Team: "*JEQ0000001:1"}
+	m2 := &DeviceDescription{Address: "JEQ0000003:1", Team: "*JEQ0000001:1"}
+	other := &DeviceDescription{Address: "JEQ0000004:1"}
+	if !team.IsTeam() || team.IsTeamMember() || !m1.IsTeamMember() || m1.IsTeam() || other.IsTeamMember() {
+		t.Error("invalid team classification")
+	}
+	ms := TeamMembers(team, []*DeviceDescription{other, m2, team, m1})
+	if !reflect.DeepEqual(ms, []*DeviceDescription{m1, m2}) {
+		t.Error(ms)
+	}
+}
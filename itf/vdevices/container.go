@@ -55,7 +55,8 @@ type GenericParamset interface {
 	HandlePutParamset(func())
 }
 
-// GenericParameter that can be used by Handler.
+// GenericParameter that can be used by Handler. The HomeMatic type of a
+// parameter (e.g. FLOAT, ACTION) is available with Description().Type.
 type GenericParameter interface {
 	Description() *itf.ParameterDescription
 
@@ -96,9 +97,33 @@ func (c *Container) Dispose() {
 	c.devices = nil
 }
 
+// sealer is implemented by devices, which can enforce that their structure is
+// not changed after adding to the container (e.g. Device).
+type sealer interface {
+	Seal()
+}
+
+// synchronizedDevice is implemented by devices, which synchronize the logic
+// layers on changes of their description (e.g. Device).
+type synchronizedDevice interface {
+	setSynchronizer(synchronizer Synchronizer)
+}
+
+// firmwareUpdater is implemented by devices, which support firmware updates
+// requested by the CCU (e.g. Device).
+type firmwareUpdater interface {
+	UpdateFirmware() (bool, error)
+}
+
+// putParamsetValidator is implemented by paramsets, which validate all values
+// of a putParamset before any value is applied (e.g. Paramset).
+type putParamsetValidator interface {
+	ValidatePutParamset(values map[string]interface{}) error
+}
+
 // AddDevice adds the specified device to the container. The structure of a
 // device, e.g. the channels and paramsets, must not change after adding the
-// device.
+// device. If the device implements Seal (e.g. Device), it gets sealed.
 func (c *Container) AddDevice(device GenericDevice) error {
 	c.mtx.Lock()
 	defer c.mtx.Unlock()
@@ -107,11 +132,57 @@ func (c *Container) AddDevice(device GenericDevice) error {
 	if found {
 		return fmt.Errorf("Device already exists: %s", addr)
 	}
+	if s, ok := device.(sealer); ok {
+		s.Seal()
+	}
+	if s, ok := device.(synchronizedDevice); ok {
+		s.setSynchronizer(c.Synchronizer)
+	}
 	c.devices[addr] = device
 	c.Synchronizer.Synchronize()
 	return nil
 }
 
+// AddOrReplaceDevice adds the specified device to the container. An existing
+// device with the same address is replaced and disposed. The versions of the
+// device and channel descriptions are increased above the versions of the
+// replaced device, so the logic layers update their device descriptions. If
+// the device implements Seal (e.g. Device), it gets sealed.
+func (c *Container) AddOrReplaceDevice(device GenericDevice) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	if s, ok := device.(sealer); ok {
+		s.Seal()
+	}
+	addr := device.Description().Address
+	if old, found := c.devices[addr]; found {
+		bumpVersion(old.Description(), device.Description())
+		oldChs := make(map[string]*itf.DeviceDescription)
+		for _, ch := range old.Channels() {
+			oldChs[ch.Description().Address] = ch.Description()
+		}
+		for _, ch := range device.Channels() {
+			if od, ok := oldChs[ch.Description().Address]; ok {
+				bumpVersion(od, ch.Description())
+			}
+		}
+		old.Dispose()
+	}
+	if s, ok := device.(synchronizedDevice); ok {
+		s.setSynchronizer(c.Synchronizer)
+	}
+	c.devices[addr] = device
+	c.Synchronizer.Synchronize()
+}
+
+// bumpVersion sets the version of the new description above the version of
+// the old one.
+func bumpVersion(oldDescr, newDescr *itf.DeviceDescription) {
+	if newDescr.Version <= oldDescr.Version {
+		newDescr.Version = oldDescr.Version + 1
+	}
+}
+
 // RemoveDevice removes the specified device from the container. If the device
 // implements Disposer, Dispose gets called.
 func (c *Container) RemoveDevice(address string) error {
@@ -148,3 +219,16 @@ func (c *Container) Devices() []GenericDevice {
 	}
 	return ds
 }
+
+// DevicesMatching returns all devices, for which the predicate returns true.
+func (c *Container) DevicesMatching(pred func(GenericDevice) bool) []GenericDevice {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	var ds []GenericDevice
+	for _, d := range c.devices {
+		if pred(d) {
+			ds = append(ds, d)
+		}
+	}
+	return ds
+}
@@ -0,0 +1,68 @@
+package vdevices
+
+import (
+	"strings"
+	"testing"
+)
+
+type testSynchronizer struct {
+	count int
+}
+
+func (s *testSynchronizer) Synchronize() {
+	s.count++
+}
+
+func TestAddOrReplaceDevice(t *testing.T) {
+	syn := &testSynchronizer{}
+	c := NewContainer()
+	c.Synchronizer = syn
+
+	dev1 := NewDevice("JCK000", "HmIP-PSM", nil)
+	NewMaintenanceChannel(dev1)
+	disposed := false
+	dev1.OnDispose = func() { disposed = true }
+	if err := c.AddDevice(dev1); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.AddDevice(NewDevice("JCK000", "HmIP-PSM", nil)); err == nil {
+		t.Error("expected error for duplicate address")
+	}
+
+	dev2 := NewDevice("JCK000", "HmIP-PSM", nil)
+	NewMaintenanceChannel(dev2)
+	c.AddOrReplaceDevice(dev2)
+	if !disposed {
+		t.Error("old device not disposed")
+	}
+	d, err := c.Device("JCK000")
+	if err != nil || d != dev2 {
+		t.Fatal(d, err)
+	}
+	if dev2.Description().Version != 2 || dev2.Channels()[0].Description().Version != 2 {
+		t.Error(dev2.Description().Version, dev2.Channels()[0].Description().Version)
+	}
+	if syn.count != 2 {
+		t.Error(syn.count)
+	}
+}
+
+func TestDevicesMatching(t *testing.T) {
+	c := NewContainer()
+	c.Synchronizer = &testSynchronizer{}
+	c.AddDevice(NewDevice("JCK000", "HmIP-PSM", nil))
+	c.AddDevice(NewDevice("ABC000", "HmIP-PSM", nil))
+
+	h := NewHandler("", c, nil)
+	defer h.Close()
+	h.DeviceFilter = func(interfaceID string, device GenericDevice) bool {
+		return strings.HasPrefix(device.Description().Address, "JCK")
+	}
+	dds, err := h.ListDevices()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(dds) != 1 || dds[0].Address != "JCK000" {
+		t.Error(dds)
+	}
+}
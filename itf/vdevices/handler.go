@@ -1,12 +1,18 @@
 package vdevices
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"net"
+	"net/url"
 	"os"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/mdzio/go-hmccu/itf"
 	"github.com/mdzio/go-lib/conc"
@@ -15,9 +21,14 @@ import (
 
 var log = logging.Get("v-devices")
 
+var errInvalidParamsetKey = errors.New("Invalid paramset key")
+
 const (
 	// template for a new interface entry
 	itfTmpl = "\t<ipc>\n\t \t<name>%s</name>\n\t \t<url>%s</url>\n\t \t<info>%s</info>\n\t</ipc>\n"
+
+	// timeout for resolving the host name of a receiver address
+	resolveTimeout = 2 * time.Second
 )
 
 // EventPublisher publishes value change events.
@@ -25,20 +36,107 @@ type EventPublisher interface {
 	PublishEvent(address, valueKey string, value interface{})
 }
 
+// Event is a value change event.
+type Event struct {
+	Address  string
+	ValueKey string
+	Value    interface{}
+}
+
+// BatchEventPublisher publishes multiple value change events together. Events
+// of other publications are not interleaved.
+type BatchEventPublisher interface {
+	EventPublisher
+	PublishEvents(events []Event)
+}
+
+// actionPublisher can be implemented additionally by an EventPublisher, which
+// handles events of ACTION parameters (e.g. PRESS_SHORT) differently (e.g.
+// RateLimiter).
+type actionPublisher interface {
+	PublishActionEvent(address, valueKey string, value interface{})
+}
+
 // Synchronizer updates the device lists in the logic layers.
 type Synchronizer interface {
 	Synchronize()
 }
 
+// DefaultReceiverAddressMap contains the standard replacements for receiver
+// addresses of the CCU logic layers.
+var DefaultReceiverAddressMap = map[string]string{
+	// non-binary XML-RPC works for ReGaHss also
+	"xmlrpc_bin://127.0.0.1:31999": ":1999",
+	"http://127.0.0.1:" + strconv.Itoa(itf.HMServerInternalPort) + itf.HMServerBidCosPath: ":" + strconv.Itoa(itf.HMServerPort) + itf.HMServerBidCosPath,
+}
+
 // Handler handles requests from logic layers.
 type Handler struct {
+	// OnDeleteDevice is called (optional), when the CCU requests the deletion
+	// of a device. If an error is returned, the device is not deleted and the
+	// error is returned to the CCU.
+	OnDeleteDevice func(address string, flags int) error
+
+	// If PushValuesOnInit is set, the current values of all readable VALUES
+	// parameters with events are sent to a logic layer after registration.
+	PushValuesOnInit bool
+
+	// DeviceFilter selects the devices, which are advertised to the logic
+	// layer with the specified interface ID (optional). Events of other devices
+	// are not sent to the logic layer. ListDevices calls the filter with an
+	// empty interface ID.
+	DeviceFilter func(interfaceID string, device GenericDevice) bool
+
+	// ReceiverAddressMap replaces receiver addresses of logic layers, which are
+	// only reachable inside the CCU (e.g. 127.0.0.1). The replacement is
+	// appended to the CCU address. If ReceiverAddressMap is nil,
+	// DefaultReceiverAddressMap is used.
+	ReceiverAddressMap map[string]string
+
+	// If EmptyParamsetDescriptions is set, GetParamsetDescription returns an
+	// empty description for a paramset key, which is not supported by an
+	// existing device or channel (e.g. VALUES of a device). Otherwise an error
+	// is returned. Some logic layers probe all paramset keys.
+	EmptyParamsetDescriptions bool
+
+	// If TypedValues is set, GetValue, GetParamset and the events convert the
+	// values to the Go type, which corresponds to the declared type of the
+	// parameter (e.g. int for ENUM, bool for ACTION and ALARM). Otherwise the
+	// wire type is inferred from the Go type of the stored value. For events,
+	// TypedValues must be set before the logic layers register.
+	TypedValues bool
+
 	ccuAddr          string
 	devices          *Container
 	deletionNotifier func(address string)
 
-	servants   map[string]*servant // key: receiverAddress
+	servants   map[string]*servant // key: see servantKey
 	mtx        sync.Mutex          // for servants map
 	daemonPool conc.DaemonPool     // for background tasks
+
+	// resolves host names of receiver addresses (resolveHost, replaceable for
+	// tests)
+	resolve func(host string) net.IP
+}
+
+// ServantStat contains diagnostic information about the connection to a logic
+// layer.
+type ServantStat struct {
+	// ReceiverAddress as registered by the logic layer
+	ReceiverAddress string
+	// Address used for sending requests to the logic layer
+	Address     string
+	InterfaceID string
+	// Number of queued commands (e.g. events)
+	QueueLength int
+	// Time of the last successful synchronization of the device lists (zero,
+	// if not yet synchronized)
+	LastSync time.Time
+	// Kind of the last command taken from the queue (sync, push values, event,
+	// events) and the time, when its processing was started (empty/zero, if
+	// none). A long running command indicates an unresponsive logic layer.
+	LastCommand     string
+	LastCommandTime time.Time
 }
 
 // NewHandler creates a Handler. deletionNotifier is called, when the CCU
@@ -49,17 +147,39 @@ func NewHandler(ccuAddr string, devices *Container, deletionNotifier func(addres
 		devices:          devices,
 		deletionNotifier: deletionNotifier,
 		servants:         make(map[string]*servant),
+		resolve:          resolveHost,
 	}
 }
 
-// Close frees resources.
-func (h *Handler) Close() {
+// ServantStats returns diagnostic information about the connections to the
+// registered logic layers. The result is sorted by receiver address.
+func (h *Handler) ServantStats() []ServantStat {
 	h.mtx.Lock()
 	defer h.mtx.Unlock()
+	stats := make([]ServantStat, 0, len(h.servants))
 	for _, s := range h.servants {
+		stats = append(stats, s.stat())
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		return stats[i].ReceiverAddress < stats[j].ReceiverAddress
+	})
+	return stats
+}
+
+// Close frees resources. The HomeMatic RPC protocol provides no method for a
+// device layer to deregister itself from a logic layer (init is only
+// implemented by device layers). Pending deliveries to the logic layers are
+// cancelled, the logic layers detect the shutdown by failing requests or
+// missing PONG events.
+func (h *Handler) Close() {
+	h.mtx.Lock()
+	for ra, s := range h.servants {
+		log.Debugf("Closing connection to logic layer %s, interface ID %s", ra, s.itfID)
 		h.daemonPool.Run(func(conc.Context) { s.close() })
 	}
 	h.servants = make(map[string]*servant)
+	h.mtx.Unlock()
+	// background tasks may lock the mutex (e.g. resolveServant)
 	h.daemonPool.Close()
 }
 
@@ -73,6 +193,39 @@ func (h *Handler) Synchronize() {
 	}
 }
 
+// SynchronizeContext updates the device lists in the logic layers like
+// Synchronize, but waits until all logic layers are synchronized or ctx is
+// done. The errors of all logic layers are combined.
+func (h *Handler) SynchronizeContext(ctx context.Context) error {
+	h.mtx.Lock()
+	dones := make(map[string]chan error, len(h.servants))
+	for ra, s := range h.servants {
+		done := make(chan error, 1)
+		dones[ra] = done
+		if err := s.command(servantSync{done: done}); err != nil {
+			done <- err
+		}
+	}
+	h.mtx.Unlock()
+
+	var errs []string
+	for ra, done := range dones {
+		select {
+		case err := <-done:
+			if err != nil {
+				errs = append(errs, err.Error())
+			}
+		case <-ctx.Done():
+			return fmt.Errorf("Synchronization of logic layer %s not completed: %v", ra, ctx.Err())
+		}
+	}
+	if len(errs) > 0 {
+		sort.Strings(errs)
+		return fmt.Errorf("Synchronization failed: %s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
 // PublishEvent distributes an value event to all registered logic layers.
 // Implements EventPublisher.
 func (h *Handler) PublishEvent(address, valueKey string, value interface{}) {
@@ -88,55 +241,89 @@ func (h *Handler) PublishEvent(address, valueKey string, value interface{}) {
 	}
 }
 
-// Init implements DeviceLayer.
+// PublishEvents implements BatchEventPublisher.
+func (h *Handler) PublishEvents(events []Event) {
+	if len(events) == 0 {
+		return
+	}
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+	log.Tracef("Publishing %d events", len(events))
+	cmd := make(servantEvents, len(events))
+	for i, e := range events {
+		cmd[i] = servantEvent{
+			address:  e.Address,
+			valueKey: e.ValueKey,
+			value:    e.Value,
+		}
+	}
+	for _, s := range h.servants {
+		s.command(cmd)
+	}
+}
+
+// Init implements DeviceLayer. The receiver address is normalized (e.g.
+// trailing slash, host name), so that a logic layer registering again with a
+// different representation of the same address is not registered twice. Host
+// names are resolved in the background (see resolveServant).
 func (h *Handler) Init(receiverAddress, interfaceID string) error {
 	log.Debugf("Registering logic layer: %s", receiverAddress)
+	key := servantKey(receiverAddress)
 	h.mtx.Lock()
 	defer h.mtx.Unlock()
 
 	// already registered?
-	s, ok := h.servants[receiverAddress]
-	if ok {
-		log.Debugf("Logic layer is already registered: %s", receiverAddress)
-		// synchronize again with logic layer
-		s.command(servantSync{})
-		return nil
+	oldKey, old := h.lookupServant(key)
+	if old != nil {
+		if old.itfID == interfaceID {
+			log.Debugf("Logic layer is already registered: %s", receiverAddress)
+			// synchronize again with logic layer
+			old.command(servantSync{})
+			if h.PushValuesOnInit {
+				old.command(servantPushValues{})
+			}
+			// retry a failed resolution
+			if old.resolvedKey == "" {
+				h.resolveServant(oldKey, old)
+			}
+			return nil
+		}
+		// the logic layer expects the new interface ID in all callbacks
+		log.Debugf("Logic layer %s is registered again with new interface ID: %s", receiverAddress, interfaceID)
+		delete(h.servants, oldKey)
+		h.daemonPool.Run(func(conc.Context) { old.close() })
 	}
 
 	// replace receiver addresses
-	var addr string
-	switch receiverAddress {
-	case "xmlrpc_bin://127.0.0.1:31999":
-		// non-binary XML-RPC works for ReGaHss also
-		addr = h.ccuAddr + ":1999"
-		log.Debugf("Patched receiver address: %s", addr)
-	case "http://127.0.0.1:39292/bidcos":
-		addr = h.ccuAddr + ":9292/bidcos"
-		log.Debugf("Patched receiver address: %s", addr)
-	default:
-		// remove protocol prefix
-		addr = strings.TrimPrefix(strings.TrimPrefix(receiverAddress, "http://"), "xmlrpc://")
+	addr := h.receiverAddress(receiverAddress)
+	if err := checkReceiverAddress(addr); err != nil {
+		log.Warning(err)
 	}
 
 	// create new servant
-	s = newServant(addr, interfaceID, h.devices)
-	h.servants[receiverAddress] = s
+	s := newServant(receiverAddress, addr, interfaceID, h.devices, h.DeviceFilter, h.TypedValues)
+	h.servants[key] = s
+	h.resolveServant(key, s)
 
 	// synchronize with logic layer
 	s.command(servantSync{})
+	if h.PushValuesOnInit {
+		s.command(servantPushValues{})
+	}
 	return nil
 }
 
 // Deinit implements DeviceLayer.
 func (h *Handler) Deinit(receiverAddress string) error {
 	log.Debugf("Unregistering logic layer: %s", receiverAddress)
+	key := servantKey(receiverAddress)
 	h.mtx.Lock()
 	defer h.mtx.Unlock()
 
 	// registered?
-	s, ok := h.servants[receiverAddress]
-	if ok {
-		delete(h.servants, receiverAddress)
+	key, s := h.lookupServant(key)
+	if s != nil {
+		delete(h.servants, key)
 		h.daemonPool.Run(func(conc.Context) { s.close() })
 	} else {
 		log.Debugf("Logic layer is NOT registered: %s", receiverAddress)
@@ -146,20 +333,36 @@ func (h *Handler) Deinit(receiverAddress string) error {
 
 // ListDevices implements DeviceLayer.
 func (h *Handler) ListDevices() ([]*itf.DeviceDescription, error) {
-	devices := h.devices.Devices()
+	var devices []GenericDevice
+	if h.DeviceFilter == nil {
+		devices = h.devices.Devices()
+	} else {
+		devices = h.devices.DevicesMatching(func(d GenericDevice) bool {
+			return h.DeviceFilter("", d)
+		})
+	}
+	// deterministic order: devices by address, channels by index
+	sort.Slice(devices, func(i, j int) bool {
+		return devices[i].Description().Address < devices[j].Description().Address
+	})
 	descr := make([]*itf.DeviceDescription, 0, 50)
 	for _, device := range devices {
 		descr = append(descr, device.Description())
-		channels := device.Channels()
-		for _, channel := range channels {
-			descr = append(descr, channel.Description())
+		chDescrs := make([]*itf.DeviceDescription, 0, len(device.Channels()))
+		for _, channel := range device.Channels() {
+			chDescrs = append(chDescrs, channel.Description())
 		}
+		sort.SliceStable(chDescrs, func(i, j int) bool {
+			return chDescrs[i].Index < chDescrs[j].Index
+		})
+		descr = append(descr, chDescrs...)
 	}
 	return descr, nil
 }
 
-// DeleteDevice implements DeviceLayer. Before removing the device from the
-// container, deletionNotifier is called.
+// DeleteDevice implements DeviceLayer. OnDeleteDevice can veto the deletion.
+// Before removing the device from the container, deletionNotifier is called.
+// If flag itf.DeleteFlagDefer is set, the device is removed in the background.
 func (h *Handler) DeleteDevice(address string, flags int) error {
 	deviceAddr, channelAddr := itf.SplitAddress(address)
 	if channelAddr != "" {
@@ -167,6 +370,25 @@ func (h *Handler) DeleteDevice(address string, flags int) error {
 		log.Debugf("Deletion of channel ignored: %s", address)
 		return nil
 	}
+	if _, err := h.devices.Device(deviceAddr); err != nil {
+		return err
+	}
+	if h.OnDeleteDevice != nil {
+		if err := h.OnDeleteDevice(address, flags); err != nil {
+			log.Debugf("Deletion of device %s rejected: %v", address, err)
+			return err
+		}
+	}
+	if flags&itf.DeleteFlagDefer != 0 {
+		log.Debugf("Deferring deletion of device: %s", address)
+		h.daemonPool.Run(func(conc.Context) {
+			h.deletionNotifier(address)
+			if err := h.devices.RemoveDevice(deviceAddr); err != nil {
+				log.Warningf("Deferred deletion of device %s failed: %v", address, err)
+			}
+		})
+		return nil
+	}
 	h.deletionNotifier(address)
 	return h.devices.RemoveDevice(deviceAddr)
 }
@@ -192,6 +414,9 @@ func (h *Handler) GetDeviceDescription(address string) (*itf.DeviceDescription,
 func (h *Handler) GetParamsetDescription(address, paramsetKey string) (itf.ParamsetDescription, error) {
 	_, paramset, err := h.getParamset(address, paramsetKey)
 	if err != nil {
+		if h.EmptyParamsetDescriptions && errors.Is(err, errInvalidParamsetKey) {
+			return itf.ParamsetDescription{}, nil
+		}
 		return nil, err
 	}
 	psDescr := make(itf.ParamsetDescription)
@@ -211,7 +436,11 @@ func (h *Handler) GetParamset(address string, paramsetKey string) (map[string]in
 	locker.Lock()
 	defer locker.Unlock()
 	for _, param := range paramset.Parameters() {
-		values[param.Description().ID] = param.Value()
+		v, err := h.ccuValue(param)
+		if err != nil {
+			return nil, err
+		}
+		values[param.Description().ID] = v
 	}
 	return values, nil
 }
@@ -224,17 +453,28 @@ func (h *Handler) PutParamset(address string, paramsetKey string, values map[str
 	}
 	locker.Lock()
 	defer locker.Unlock()
+	// check all values before applying them
+	params := make(map[string]GenericParameter, len(values))
+	fixed := make(map[string]interface{}, len(values))
 	for name, value := range values {
 		param, err := paramset.Parameter(name)
 		if err != nil {
 			return err
 		}
+		params[name] = param
 		// workaround for bug in CCU/RM
-		value, err = fixStringParamValue(value)
+		fixed[name], err = fixStringParamValue(value)
 		if err != nil {
 			return fmt.Errorf("Setting of paramset %s of device/channel %s failed: %v", paramsetKey, address, err)
 		}
-		err = param.SetValue(value)
+	}
+	if v, ok := paramset.(putParamsetValidator); ok {
+		if err := v.ValidatePutParamset(fixed); err != nil {
+			return fmt.Errorf("Validation of paramset %s of device/channel %s failed: %v", paramsetKey, address, err)
+		}
+	}
+	for name, value := range fixed {
+		err = params[name].SetValue(value)
 		if err != nil {
 			return err
 		}
@@ -253,9 +493,23 @@ func (h *Handler) GetValue(address string, valueName string) (interface{}, error
 	if err != nil {
 		return nil, err
 	}
+	// write-only parameters (e.g. PRESS_SHORT) are not readable like on real
+	// devices
+	if err := checkOperation(param, itf.ParameterOperationRead, address); err != nil {
+		return nil, err
+	}
 	locker.Lock()
 	defer locker.Unlock()
-	return param.Value(), nil
+	return h.ccuValue(param)
+}
+
+// ccuValue returns the value of the parameter for the CCU according to
+// TypedValues. The associated channel must be locked.
+func (h *Handler) ccuValue(param GenericParameter) (interface{}, error) {
+	if h.TypedValues {
+		return typedCCUValue(param)
+	}
+	return ccuValue(param)
 }
 
 // SetValue implements DeviceLayer.
@@ -268,6 +522,9 @@ func (h *Handler) SetValue(address string, valueName string, value interface{})
 	if err != nil {
 		return err
 	}
+	if err := checkOperation(param, itf.ParameterOperationWrite, address); err != nil {
+		return err
+	}
 	// workaround for bug in CCU/RM
 	value, err = fixStringParamValue(value)
 	if err != nil {
@@ -278,12 +535,186 @@ func (h *Handler) SetValue(address string, valueName string, value interface{})
 	return param.SetValue(value)
 }
 
+// checkOperation returns an error, if the parameter does not support the
+// operation (itf.ParameterOperationRead or itf.ParameterOperationWrite).
+func checkOperation(param GenericParameter, operation int, address string) error {
+	if param.Description().Operations&operation != 0 {
+		return nil
+	}
+	id := param.Description().ID
+	if operation == itf.ParameterOperationWrite {
+		return fmt.Errorf("Parameter not writeable: %s.%s", address, id)
+	}
+	return fmt.Errorf("Parameter not readable: %s.%s", address, id)
+}
+
 // Ping implements DeviceLayer.
 func (h *Handler) Ping(callerID string) (bool, error) {
-	h.PublishEvent("CENTRAL", "PONG", callerID)
+	h.PublishEvent(itf.CentralAddress, itf.PongKey, callerID)
 	return true, nil
 }
 
+// UpdateFirmware implements itf.FirmwareUpdater. False is returned, if the
+// device does not support firmware updates.
+func (h *Handler) UpdateFirmware(address string) (bool, error) {
+	device, err := h.devices.Device(address)
+	if err != nil {
+		return false, err
+	}
+	fu, ok := device.(firmwareUpdater)
+	if !ok {
+		log.Warningf("Firmware update not supported by device %s", address)
+		return false, nil
+	}
+	return fu.UpdateFirmware()
+}
+
+// receiverAddress returns the address for sending requests to a logic layer.
+func (h *Handler) receiverAddress(receiverAddress string) string {
+	m := h.ReceiverAddressMap
+	if m == nil {
+		m = DefaultReceiverAddressMap
+	}
+	repl, ok := m[receiverAddress]
+	if !ok {
+		// compare representations (e.g. trailing slash), host names are not
+		// resolved
+		canon := canonicalReceiverAddress(receiverAddress, nil)
+		for k, r := range m {
+			if canonicalReceiverAddress(k, nil) == canon {
+				repl, ok = r, true
+				break
+			}
+		}
+	}
+	if ok {
+		addr := h.ccuAddr + repl
+		log.Debugf("Patched receiver address: %s", addr)
+		return addr
+	}
+	// remove protocol prefix
+	return strings.TrimPrefix(strings.TrimPrefix(receiverAddress, "http://"), "xmlrpc://")
+}
+
+// servantKey returns the key of the servants map for a receiver address (see
+// canonicalReceiverAddress). Host names are not resolved.
+func servantKey(receiverAddress string) string {
+	return canonicalReceiverAddress(receiverAddress, nil)
+}
+
+// lookupServant returns the servant and its key for a servant key. A servant,
+// whose host name resolves to the host of the key, matches too. h.mtx must be
+// locked.
+func (h *Handler) lookupServant(key string) (string, *servant) {
+	if s, ok := h.servants[key]; ok {
+		return key, s
+	}
+	for k, s := range h.servants {
+		if s.resolvedKey == key {
+			return k, s
+		}
+	}
+	return "", nil
+}
+
+// resolveServant resolves the host name of the servant key in the background.
+// Other servants registered for the same address (e.g. with the IP address
+// instead of the host name) are removed afterwards, because the logic layer
+// registered again. A failed resolution is not remembered, it is retried on
+// the next registration. h.mtx must be locked.
+func (h *Handler) resolveServant(key string, s *servant) {
+	u, err := url.Parse(key)
+	if err != nil || u.Host == "" || net.ParseIP(u.Hostname()) != nil {
+		return
+	}
+	resolve := h.resolve
+	h.daemonPool.Run(func(conc.Context) {
+		resolved := canonicalReceiverAddress(key, resolve)
+		if resolved == key {
+			return
+		}
+		h.mtx.Lock()
+		if h.servants[key] != s {
+			// servant removed in the meantime
+			h.mtx.Unlock()
+			return
+		}
+		s.resolvedKey = resolved
+		var olds []*servant
+		for k, o := range h.servants {
+			if o != s && (k == resolved || o.resolvedKey == resolved) {
+				log.Debugf("Logic layer %s is registered again as %s", o.rcvAddr, s.rcvAddr)
+				delete(h.servants, k)
+				olds = append(olds, o)
+			}
+		}
+		h.mtx.Unlock()
+		for _, o := range olds {
+			o.close()
+		}
+	})
+}
+
+// canonicalReceiverAddress returns a canonical representation of a receiver
+// address: The scheme xmlrpc is replaced by http, a trailing slash is removed
+// and, if resolve is not nil, a host name is replaced by its address. If the
+// address can not be parsed, it is returned unmodified.
+func canonicalReceiverAddress(receiverAddress string, resolve func(host string) net.IP) string {
+	u, err := url.Parse(receiverAddress)
+	if err != nil || u.Host == "" {
+		return receiverAddress
+	}
+	u.Scheme = strings.ToLower(u.Scheme)
+	if u.Scheme == "xmlrpc" {
+		u.Scheme = "http"
+	}
+	host := strings.ToLower(u.Hostname())
+	if ip := net.ParseIP(host); ip != nil {
+		host = ip.String()
+	} else if resolve != nil {
+		if ip := resolve(host); ip != nil {
+			host = ip.String()
+		}
+	}
+	if port := u.Port(); port != "" {
+		u.Host = net.JoinHostPort(host, port)
+	} else {
+		u.Host = host
+	}
+	u.Path = strings.TrimSuffix(u.Path, "/")
+	u.RawPath = ""
+	return u.String()
+}
+
+// resolveHost looks up the address of a host. An IPv4 address is preferred.
+// Nil is returned, if the host can not be resolved.
+func resolveHost(host string) net.IP {
+	ctx, cancel := context.WithTimeout(context.Background(), resolveTimeout)
+	defer cancel()
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil || len(addrs) == 0 {
+		log.Debugf("Resolving of host %s failed: %v", host, err)
+		return nil
+	}
+	for _, a := range addrs {
+		if ip4 := a.IP.To4(); ip4 != nil {
+			return ip4
+		}
+	}
+	return addrs[0].IP
+}
+
+// checkReceiverAddress detects receiver addresses, which refer to the
+// interface process VirtualDevices instead of the HMServer logic layer.
+func checkReceiverAddress(addr string) error {
+	for _, port := range []int{itf.HMServerPort, itf.HMServerInternalPort} {
+		if strings.HasSuffix(addr, ":"+strconv.Itoa(port)+itf.HMServerGroupsPath) {
+			return fmt.Errorf("Receiver address %s refers to the interface process VirtualDevices, events are probably not delivered (expected path: %s)", addr, itf.HMServerBidCosPath)
+		}
+	}
+	return nil
+}
+
 func (h *Handler) getParamset(address string, paramsetKey string) (sync.Locker, GenericParamset, error) {
 	deviceAddr, channelAddr := itf.SplitAddress(address)
 	device, err := h.devices.Device(deviceAddr)
@@ -295,7 +726,7 @@ func (h *Handler) getParamset(address string, paramsetKey string) (sync.Locker,
 		case "MASTER":
 			return device, device.MasterParamset(), nil
 		default:
-			return nil, nil, fmt.Errorf("Invalid paramset key for %s: %s", address, paramsetKey)
+			return nil, nil, fmt.Errorf("%w for %s: %s", errInvalidParamsetKey, address, paramsetKey)
 		}
 	}
 	channel, err := device.Channel(channelAddr)
@@ -308,7 +739,7 @@ func (h *Handler) getParamset(address string, paramsetKey string) (sync.Locker,
 	case "VALUES":
 		return channel, channel.ValueParamset(), nil
 	default:
-		return nil, nil, fmt.Errorf("Invalid paramset key for %s: %s", address, paramsetKey)
+		return nil, nil, fmt.Errorf("%w for %s: %s", errInvalidParamsetKey, address, paramsetKey)
 	}
 }
 
@@ -318,12 +749,51 @@ type TeeEventPublisher struct {
 	Second EventPublisher
 }
 
+// check interface implementation
+var _ BatchEventPublisher = (*TeeEventPublisher)(nil)
+
 // PublishEvent implements vdevices.EventPublisher.
 func (t *TeeEventPublisher) PublishEvent(address, valueKey string, value interface{}) {
 	t.First.PublishEvent(address, valueKey, value)
 	t.Second.PublishEvent(address, valueKey, value)
 }
 
+// PublishEvents implements vdevices.BatchEventPublisher. A receiver, which is
+// not a BatchEventPublisher, gets the events one by one.
+func (t *TeeEventPublisher) PublishEvents(events []Event) {
+	publishEvents(t.First, events)
+	publishEvents(t.Second, events)
+}
+
+// PublishActionEvent forwards an event of an ACTION parameter to both
+// receivers (see RateLimiter).
+func (t *TeeEventPublisher) PublishActionEvent(address, valueKey string, value interface{}) {
+	publishActionEvent(t.First, address, valueKey, value)
+	publishActionEvent(t.Second, address, valueKey, value)
+}
+
+// publishEvents publishes the events as batch, if the publisher supports it.
+// Otherwise the events are published one by one.
+func publishEvents(publisher EventPublisher, events []Event) {
+	if bp, ok := publisher.(BatchEventPublisher); ok {
+		bp.PublishEvents(events)
+		return
+	}
+	for _, e := range events {
+		publisher.PublishEvent(e.Address, e.ValueKey, e.Value)
+	}
+}
+
+// publishActionEvent publishes an event of an ACTION parameter with
+// PublishActionEvent, if the publisher supports it.
+func publishActionEvent(publisher EventPublisher, address, valueKey string, value interface{}) {
+	if ap, ok := publisher.(actionPublisher); ok {
+		ap.PublishActionEvent(address, valueKey, value)
+		return
+	}
+	publisher.PublishEvent(address, valueKey, value)
+}
+
 func AddToInterfaceList(inFilePath, outFilePath, name, url, info string) error {
 	// read file
 	bs, err := os.ReadFile(inFilePath)
@@ -2,10 +2,21 @@ package vdevices
 
 import (
 	"bytes"
+	"context"
+	"errors"
+	"fmt"
 	"io/ioutil"
+	"net"
+	"net/http/httptest"
 	"os"
+	"reflect"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/mdzio/go-hmccu/itf"
+	"github.com/mdzio/go-hmccu/itf/xmlrpc"
 	_ "github.com/mdzio/go-lib/testutil"
 )
 
@@ -78,3 +89,682 @@ func TestFixStringParam(t *testing.T) {
 		}
 	}
 }
+
+func TestDeleteDevicePolicy(t *testing.T) {
+	deleted := make(chan string, 1)
+	vdevs := NewContainer()
+	h := NewHandler("", vdevs, func(address string) { deleted <- address })
+	defer h.Close()
+	vdevs.Synchronizer = h
+	h.OnDeleteDevice = func(address string, flags int) error {
+		if flags&itf.DeleteFlagForce == 0 {
+			return errors.New("device is protected")
+		}
+		return nil
+	}
+	if err := vdevs.AddDevice(NewDevice("JCK000", "HmIP-MIO16-PCB", h)); err != nil {
+		t.Fatal(err)
+	}
+
+	// veto
+	err := h.DeleteDevice("JCK000", 0)
+	if err == nil || err.Error() != "device is protected" {
+		t.Fatal(err)
+	}
+	if _, err := vdevs.Device("JCK000"); err != nil {
+		t.Fatal(err)
+	}
+
+	// deferred deletion
+	err = h.DeleteDevice("JCK000", itf.DeleteFlagForce|itf.DeleteFlagDefer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if addr := <-deleted; addr != "JCK000" {
+		t.Fatal(addr)
+	}
+
+	// unknown device
+	if err := h.DeleteDevice("JCK999", itf.DeleteFlagForce); err == nil {
+		t.Fatal("expected error")
+	}
+}
+
+func TestReceiverAddress(t *testing.T) {
+	h := &Handler{ccuAddr: "ccu"}
+	cases := []struct {
+		in, want string
+	}{
+		{"xmlrpc_bin://127.0.0.1:31999", "ccu:1999"},
+		{"http://127.0.0.1:39292/bidcos", "ccu:9292/bidcos"},
+		{"xmlrpc://127.0.0.1:39292/bidcos/", "ccu:9292/bidcos"},
+		{"http://192.168.0.1:1234", "192.168.0.1:1234"},
+		{"xmlrpc://192.168.0.1:1234", "192.168.0.1:1234"},
+	}
+	for _, c := range cases {
+		if got := h.receiverAddress(c.in); got != c.want {
+			t.Errorf("%s: %s", c.in, got)
+		}
+	}
+
+	h.ReceiverAddressMap = map[string]string{"http://127.0.0.1:39293/bidcos": ":9293/bidcos"}
+	if got := h.receiverAddress("http://127.0.0.1:39293/bidcos"); got != "ccu:9293/bidcos" {
+		t.Error(got)
+	}
+	if got := h.receiverAddress("http://127.0.0.1:39292/bidcos"); got != "127.0.0.1:39292/bidcos" {
+		t.Error(got)
+	}
+}
+
+func TestHMServerEndpoints(t *testing.T) {
+	// the HMServer registers with its internal address and path /bidcos, events
+	// must be sent to the external port with path /bidcos
+	h := &Handler{ccuAddr: "ccu"}
+	addr := h.receiverAddress("http://127.0.0.1:39292/bidcos")
+	if addr != "ccu:9292/bidcos" {
+		t.Error(addr)
+	}
+	if err := checkReceiverAddress(addr); err != nil {
+		t.Error(err)
+	}
+	// path /groups is the interface process VirtualDevices (device layer)
+	if err := checkReceiverAddress("ccu:9292/groups"); err == nil {
+		t.Error("expected error")
+	}
+	if err := checkReceiverAddress("127.0.0.1:39292/groups"); err == nil {
+		t.Error("expected error")
+	}
+}
+
+type testLogicLayer struct {
+	msg chan string
+}
+
+func (l *testLogicLayer) Event(interfaceID, address, valueKey string, value interface{}) error {
+	l.msg <- fmt.Sprintf("%s %s %s %v", interfaceID, address, valueKey, value)
+	return nil
+}
+
+func (l *testLogicLayer) NewDevices(interfaceID string, devDescriptions []*itf.DeviceDescription) error {
+	var addrs []string
+	for _, descr := range devDescriptions {
+		addrs = append(addrs, descr.Address)
+	}
+	l.msg <- fmt.Sprintf("%s %v", interfaceID, addrs)
+	return nil
+}
+
+func (l *testLogicLayer) DeleteDevices(interfaceID string, addresses []string) error {
+	l.msg <- fmt.Sprintf("%s %v", interfaceID, addresses)
+	return nil
+}
+
+func (l *testLogicLayer) UpdateDevice(interfaceID, address string, hint int) error {
+	return nil
+}
+
+func (l *testLogicLayer) ReplaceDevice(interfaceID, oldDeviceAddress, newDeviceAddress string) error {
+	return nil
+}
+
+func (l *testLogicLayer) ReaddedDevice(interfaceID string, deletedAddresses []string) error {
+	return nil
+}
+
+func (l *testLogicLayer) expect(t *testing.T, msg string) {
+	t.Helper()
+	select {
+	case m := <-l.msg:
+		if m != msg {
+			t.Errorf("unexpected message: %s, expected: %s", m, msg)
+		}
+	case <-time.After(3 * time.Second):
+		t.Errorf("message not received: %s", msg)
+	}
+}
+
+// newTestLogicLayer starts an XML-RPC server for a testLogicLayer. The
+// returned function stops the server.
+func newTestLogicLayer() (*testLogicLayer, string, func()) {
+	ll := &testLogicLayer{msg: make(chan string, 10)}
+	d := itf.NewDispatcher()
+	d.AddLogicLayer(ll)
+	srv := httptest.NewServer(&xmlrpc.Handler{Dispatcher: d})
+	return ll, srv.URL, srv.Close
+}
+
+func TestServantStats(t *testing.T) {
+	ll, url, closeLL := newTestLogicLayer()
+	defer closeLL()
+
+	c := NewContainer()
+	h := NewHandler("", c, nil)
+	defer h.Close()
+	c.Synchronizer = h
+	c.AddDevice(NewDevice("JCK000", "HmIP-PSM", h))
+
+	if len(h.ServantStats()) != 0 {
+		t.Error("unexpected servant")
+	}
+	if err := h.Init(url, "itfID"); err != nil {
+		t.Fatal(err)
+	}
+	ll.expect(t, "itfID [JCK000]")
+
+	var stats []ServantStat
+	for i := 0; i < 100; i++ {
+		stats = h.ServantStats()
+		if len(stats) == 1 && !stats[0].LastSync.IsZero() {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if len(stats) != 1 {
+		t.Fatal(stats)
+	}
+	s := stats[0]
+	if s.ReceiverAddress != url || s.InterfaceID != "itfID" || s.QueueLength != 0 || s.LastSync.IsZero() ||
+		s.LastCommand != "sync" || s.LastCommandTime.IsZero() {
+		t.Error(s)
+	}
+}
+
+func TestEventInterfaceID(t *testing.T) {
+	ll, url, closeLL := newTestLogicLayer()
+	defer closeLL()
+
+	c := NewContainer()
+	h := NewHandler("", c, nil)
+	defer h.Close()
+	c.Synchronizer = h
+	dev := NewDevice("JCK000", "HmIP-PSM", h)
+	pm := NewPowerMeterChannel(dev)
+	c.AddDevice(dev)
+
+	// events must carry the interface ID of the registration
+	if err := h.Init(url, "itfID-1"); err != nil {
+		t.Fatal(err)
+	}
+	ll.expect(t, "itfID-1 [JCK000 JCK000:0]")
+	pm.SetPower(1.5)
+	ll.expect(t, "itfID-1 JCK000:0 POWER 1.5")
+
+	// registration with a new interface ID
+	if err := h.Init(url, "itfID-2"); err != nil {
+		t.Fatal(err)
+	}
+	ll.expect(t, "itfID-2 [JCK000 JCK000:0]")
+	pm.SetPower(2.5)
+	ll.expect(t, "itfID-2 JCK000:0 POWER 2.5")
+}
+
+func TestEventDeviceFilter(t *testing.T) {
+	ll, url, closeLL := newTestLogicLayer()
+	defer closeLL()
+
+	c := NewContainer()
+	h := NewHandler("", c, nil)
+	defer h.Close()
+	h.DeviceFilter = func(interfaceID string, device GenericDevice) bool {
+		return device.Description().Address != "JCK001"
+	}
+	c.Synchronizer = h
+	dev0 := NewDevice("JCK000", "HmIP-PSM", h)
+	pm0 := NewPowerMeterChannel(dev0)
+	c.AddDevice(dev0)
+	dev1 := NewDevice("JCK001", "HmIP-PSM", h)
+	pm1 := NewPowerMeterChannel(dev1)
+	c.AddDevice(dev1)
+
+	if err := h.Init(url, "itfID"); err != nil {
+		t.Fatal(err)
+	}
+	ll.expect(t, "itfID [JCK000 JCK000:0]")
+	// events of filtered devices are not sent
+	pm1.SetPower(1.5)
+	h.PublishEvents([]Event{{"JCK001:0", "POWER", 2.5}, {"JCK000:0", "POWER", 3.5}})
+	pm0.SetPower(4.5)
+	ll.expect(t, "itfID JCK000:0 POWER 3.5")
+	ll.expect(t, "itfID JCK000:0 POWER 4.5")
+}
+
+func TestSynchronizeContext(t *testing.T) {
+	ll, url, closeLL := newTestLogicLayer()
+	defer closeLL()
+
+	c := NewContainer()
+	h := NewHandler("", c, nil)
+	defer h.Close()
+	c.Synchronizer = h
+	c.AddDevice(NewDevice("JCK000", "HmIP-PSM", h))
+
+	// no logic layers
+	if err := h.SynchronizeContext(context.Background()); err != nil {
+		t.Error(err)
+	}
+
+	if err := h.Init(url, "itfID"); err != nil {
+		t.Fatal(err)
+	}
+	ll.expect(t, "itfID [JCK000]")
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	if err := h.SynchronizeContext(ctx); err != nil {
+		t.Error(err)
+	}
+	// logic layer returns always an empty device list
+	ll.expect(t, "itfID [JCK000]")
+
+	// logic layer is not reachable
+	closeLL()
+	ctx2, cancel2 := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel2()
+	if err := h.SynchronizeContext(ctx2); err == nil {
+		t.Error("expected error")
+	}
+}
+
+func TestServantCommand(t *testing.T) {
+	// servant without running goroutine
+	s := &servant{addr: "http://127.0.0.1:1", itfID: "itfID", cmds: make(chan interface{}, 1)}
+	done := make(chan error, 1)
+	if err := s.command(servantSync{done: done}); err != nil {
+		t.Fatal(err)
+	}
+	// queue overflow
+	if err := s.command(servantSync{}); err == nil {
+		t.Error("expected error")
+	}
+	// pending synchronization is answered on close
+	s.drain()
+	select {
+	case err := <-done:
+		if err != errServantClosed {
+			t.Error(err)
+		}
+	default:
+		t.Error("done not answered")
+	}
+	if err := s.command(servantSync{}); err != errServantClosed {
+		t.Error(err)
+	}
+}
+
+func TestEmptyParamsetDescriptions(t *testing.T) {
+	c := NewContainer()
+	h := NewHandler("", c, nil)
+	defer h.Close()
+	c.Synchronizer = h
+	dev := NewDevice("JCK000", "HmIP-PSM", h)
+	NewPowerMeterChannel(dev)
+	c.AddDevice(dev)
+
+	_, err := h.GetParamsetDescription("JCK000", "VALUES")
+	if err == nil || err.Error() != "Invalid paramset key for JCK000: VALUES" {
+		t.Error(err)
+	}
+
+	h.EmptyParamsetDescriptions = true
+	for _, addr := range []string{"JCK000", "JCK000:0"} {
+		psd, err := h.GetParamsetDescription(addr, "LINK")
+		if err != nil {
+			t.Error(err)
+		} else if len(psd) != 0 {
+			t.Error(psd)
+		}
+	}
+	// unknown devices are still reported
+	if _, err := h.GetParamsetDescription("JCK001", "VALUES"); err == nil {
+		t.Error("expected error")
+	}
+	if _, err := h.GetParamsetDescription("JCK000:9", "VALUES"); err == nil {
+		t.Error("expected error")
+	}
+}
+
+func TestValueOperations(t *testing.T) {
+	c := NewContainer()
+	h := NewHandler("", c, nil)
+	defer h.Close()
+	c.Synchronizer = h
+	dev := NewDevice("JCK000", "HmIP-BRC2", h)
+	NewMaintenanceChannel(dev)
+	NewKeyChannel(dev)
+	c.AddDevice(dev)
+
+	_, err := h.GetValue("JCK000:1", "PRESS_SHORT")
+	if err == nil || err.Error() != "Parameter not readable: JCK000:1.PRESS_SHORT" {
+		t.Error(err)
+	}
+	if err := h.SetValue("JCK000:1", "PRESS_SHORT", true); err != nil {
+		t.Error(err)
+	}
+
+	err = h.SetValue("JCK000:0", "UNREACH", true)
+	if err == nil || err.Error() != "Parameter not writeable: JCK000:0.UNREACH" {
+		t.Error(err)
+	}
+	if v, err := h.GetValue("JCK000:0", "UNREACH"); err != nil || v != false {
+		t.Error(v, err)
+	}
+}
+
+func TestValidatePutParamset(t *testing.T) {
+	c := NewContainer()
+	h := NewHandler("", c, nil)
+	defer h.Close()
+	c.Synchronizer = h
+	dev := NewDevice("JCK000", "HmIP-MIO16-PCB", h)
+	ch := new(Channel)
+	ch.Init("ANALOG_INPUT")
+	dev.AddChannel(ch)
+	min := NewIntParameter("MIN")
+	ch.AddMasterParam(min)
+	max := NewIntParameter("MAX")
+	ch.AddMasterParam(max)
+	ch.MasterParamset().(*Paramset).OnValidatePutParamset(func(values map[string]interface{}) error {
+		lo, hi := min.Value().(int), max.Value().(int)
+		if v, ok := values["MIN"]; ok {
+			lo = v.(int)
+		}
+		if v, ok := values["MAX"]; ok {
+			hi = v.(int)
+		}
+		if lo >= hi {
+			return errors.New("MIN must be less than MAX")
+		}
+		return nil
+	})
+	c.AddDevice(dev)
+
+	err := h.PutParamset("JCK000:0", "MASTER", map[string]interface{}{"MIN": 5, "MAX": 3})
+	if err == nil || err.Error() != "Validation of paramset MASTER of device/channel JCK000:0 failed: MIN must be less than MAX" {
+		t.Error(err)
+	}
+	if min.Value() != 0 || max.Value() != 0 {
+		t.Error(min.Value(), max.Value())
+	}
+	err = h.PutParamset("JCK000:0", "MASTER", map[string]interface{}{"MIN": 1, "MAX": 10})
+	if err != nil {
+		t.Error(err)
+	}
+	if min.Value() != 1 || max.Value() != 10 {
+		t.Error(min.Value(), max.Value())
+	}
+}
+
+func TestTypedValues(t *testing.T) {
+	c := NewContainer()
+	h := NewHandler("", c, nil)
+	defer h.Close()
+	c.Synchronizer = h
+	dev := NewDevice("JCK000", "HmIP-MIO16-PCB", h)
+	ch := new(Channel)
+	ch.Init("GENERIC")
+	dev.AddChannel(ch)
+	mode := NewIntParameter("MODE")
+	mode.description.Type = itf.ParameterTypeEnum
+	mode.description.ValueList = []string{"OFF", "AUTO", "MANUAL"}
+	ch.AddValueParam(mode)
+	level := NewFloatParameter("LEVEL")
+	ch.AddValueParam(level)
+	alarm := NewIntParameter("ALARM_STATE")
+	alarm.description.Type = itf.ParameterTypeAlarm
+	ch.AddValueParam(alarm)
+	c.AddDevice(dev)
+
+	ch.Lock()
+	mode.InternalSetValue(2)
+	level.InternalSetValue(3.0)
+	alarm.InternalSetValue(1)
+	ch.Unlock()
+
+	h.TypedValues = true
+	ps, err := h.GetParamset("JCK000:0", "VALUES")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]interface{}{"MODE": 2, "LEVEL": 3.0, "ALARM_STATE": true}
+	if !reflect.DeepEqual(ps, want) {
+		t.Error(ps)
+	}
+	v, err := h.GetValue("JCK000:0", "ALARM_STATE")
+	if err != nil || v != true {
+		t.Error(v, err)
+	}
+
+	h.TypedValues = false
+	v, err = h.GetValue("JCK000:0", "ALARM_STATE")
+	if err != nil || v != 1 {
+		t.Error(v, err)
+	}
+}
+
+func TestTypedEvents(t *testing.T) {
+	ll, url, closeLL := newTestLogicLayer()
+	defer closeLL()
+
+	c := NewContainer()
+	h := NewHandler("", c, nil)
+	defer h.Close()
+	h.TypedValues = true
+	h.PushValuesOnInit = true
+	c.Synchronizer = h
+	dev := NewDevice("JCK000", "HmIP-MIO16-PCB", h)
+	ch := new(Channel)
+	ch.Init("GENERIC")
+	dev.AddChannel(ch)
+	alarm := NewIntParameter("ALARM_STATE")
+	alarm.description.Type = itf.ParameterTypeAlarm
+	ch.AddValueParam(alarm)
+	c.AddDevice(dev)
+	ch.Lock()
+	alarm.InternalSetValue(1)
+	ch.Unlock()
+
+	if err := h.Init(url, "itfID"); err != nil {
+		t.Fatal(err)
+	}
+	ll.expect(t, "itfID [JCK000 JCK000:0]")
+	ll.expect(t, "itfID JCK000:0 ALARM_STATE true")
+	ch.Lock()
+	alarm.InternalSetValue(0)
+	ch.Unlock()
+	ll.expect(t, "itfID JCK000:0 ALARM_STATE false")
+}
+
+func TestNormalizeReceiverAddress(t *testing.T) {
+	cases := []struct {
+		in, want string
+	}{
+		{"http://127.0.0.1:1999", "http://127.0.0.1:1999"},
+		{"http://127.0.0.1:1999/", "http://127.0.0.1:1999"},
+		{"xmlrpc://127.0.0.1:1999/bidcos/", "http://127.0.0.1:1999/bidcos"},
+		{"HTTP://localhost:1999", "http://127.0.0.1:1999"},
+		{"http://unknown:1999", "http://unknown:1999"},
+		{"xmlrpc_bin://127.0.0.1:1999", "xmlrpc_bin://127.0.0.1:1999"},
+		{"127.0.0.1:1999", "127.0.0.1:1999"},
+	}
+	resolve := func(host string) net.IP {
+		if host == "localhost" {
+			return net.IPv4(127, 0, 0, 1)
+		}
+		return nil
+	}
+	for _, c := range cases {
+		if got := canonicalReceiverAddress(c.in, resolve); got != c.want {
+			t.Errorf("%s: got %s, want %s", c.in, got, c.want)
+		}
+	}
+}
+
+func TestInitNormalizedAddress(t *testing.T) {
+	ll, url, closeLL := newTestLogicLayer()
+	defer closeLL()
+
+	c := NewContainer()
+	h := NewHandler("", c, nil)
+	defer h.Close()
+	c.Synchronizer = h
+	c.AddDevice(NewDevice("JCK000", "HmIP-PSM", h))
+
+	if err := h.Init(url, "itfID"); err != nil {
+		t.Fatal(err)
+	}
+	ll.expect(t, "itfID [JCK000]")
+	if err := h.Init(url+"/", "itfID"); err != nil {
+		t.Fatal(err)
+	}
+	ll.expect(t, "itfID [JCK000]")
+	// the address of the first registration is kept
+	if stats := h.ServantStats(); len(stats) != 1 || stats[0].ReceiverAddress != url {
+		t.Error(stats)
+	}
+	if err := h.Deinit(url + "/"); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(h.ServantStats()); n != 0 {
+		t.Error(n)
+	}
+}
+
+func TestInitResolvedAddress(t *testing.T) {
+	ll, url, closeLL := newTestLogicLayer()
+	defer closeLL()
+
+	c := NewContainer()
+	h := NewHandler("", c, nil)
+	defer h.Close()
+	c.Synchronizer = h
+	c.AddDevice(NewDevice("JCK000", "HmIP-PSM", h))
+	// the first resolution fails
+	var mtx sync.Mutex
+	resolves := 0
+	h.resolve = func(host string) net.IP {
+		mtx.Lock()
+		defer mtx.Unlock()
+		resolves++
+		if host != "localhost" || resolves == 1 {
+			return nil
+		}
+		return net.IPv4(127, 0, 0, 1)
+	}
+	waitServants := func(n int) []ServantStat {
+		t.Helper()
+		deadline := time.Now().Add(3 * time.Second)
+		for {
+			stats := h.ServantStats()
+			if len(stats) == n {
+				return stats
+			}
+			if time.Now().After(deadline) {
+				t.Fatal(stats)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+
+	if err := h.Init(url, "itfID"); err != nil {
+		t.Fatal(err)
+	}
+	ll.expect(t, "itfID [JCK000]")
+	hostURL := strings.Replace(url, "127.0.0.1", "localhost", 1)
+	if err := h.Init(hostURL, "itfID"); err != nil {
+		t.Fatal(err)
+	}
+	ll.expect(t, "itfID [JCK000]")
+	waitServants(2)
+
+	// resolution is retried, the registration with the IP address is replaced
+	if err := h.Init(hostURL, "itfID"); err != nil {
+		t.Fatal(err)
+	}
+	ll.expect(t, "itfID [JCK000]")
+	if stats := waitServants(1); stats[0].ReceiverAddress != hostURL {
+		t.Error(stats)
+	}
+
+	// both representations refer to the same servant
+	if err := h.Init(url, "itfID"); err != nil {
+		t.Fatal(err)
+	}
+	ll.expect(t, "itfID [JCK000]")
+	waitServants(1)
+	if err := h.Deinit(url); err != nil {
+		t.Fatal(err)
+	}
+	waitServants(0)
+}
+
+func TestListDevicesOrder(t *testing.T) {
+	c := NewContainer()
+	h := NewHandler("", c, nil)
+	defer h.Close()
+	c.Synchronizer = h
+	for _, addr := range []string{"JCK005", "JCK001", "JCK010", "JCK003"} {
+		dev := NewDevice(addr, "HmIP-PS", h)
+		NewMaintenanceChannel(dev)
+		NewSwitchChannel(dev)
+		c.AddDevice(dev)
+	}
+
+	want := []string{
+		"JCK001", "JCK001:0", "JCK001:1",
+		"JCK003", "JCK003:0", "JCK003:1",
+		"JCK005", "JCK005:0", "JCK005:1",
+		"JCK010", "JCK010:0", "JCK010:1",
+	}
+	for i := 0; i < 5; i++ {
+		dds, err := h.ListDevices()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var addrs []string
+		for _, dd := range dds {
+			addrs = append(addrs, dd.Address)
+		}
+		if !reflect.DeepEqual(addrs, want) {
+			t.Fatal(addrs)
+		}
+	}
+}
+
+func TestTeeEventPublisher(t *testing.T) {
+	batch := &testBatchPublisher{}
+	single := &testPublisher{}
+	dev := NewDevice("JCK000", "HmIP-PSM", &TeeEventPublisher{First: batch, Second: single})
+	pm := NewPowerMeterChannel(dev)
+
+	// coalesced events
+	pm.CoalesceEvents = true
+	err := pm.SetValues(map[string]interface{}{"POWER": 2.0, "VOLTAGE": 231.0})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := [][]Event{{
+		{Address: "JCK000:0", ValueKey: "POWER", Value: 2.0},
+		{Address: "JCK000:0", ValueKey: "VOLTAGE", Value: 231.0},
+	}}
+	if len(batch.events) != 0 || !reflect.DeepEqual(batch.batches, want) {
+		t.Error(batch.events, batch.batches)
+	}
+	if !reflect.DeepEqual(single.events, []interface{}{2.0, 231.0}) {
+		t.Error(single.events)
+	}
+
+	// ACTION events are not rate limited
+	pub := &testPublisher{}
+	r, _, _ := newTestRateLimiter(pub, 1, 1)
+	dev = NewDevice("ABC0000001", "HmIP-BRC2", &TeeEventPublisher{First: r, Second: &testPublisher{}})
+	NewMaintenanceChannel(dev)
+	kch := NewKeyChannel(dev)
+	pub.events = nil
+	kch.Lock()
+	defer kch.Unlock()
+	for i := 0; i < 3; i++ {
+		kch.PressShort()
+	}
+	if !reflect.DeepEqual(pub.events, []interface{}{true, true, true}) || r.Dropped() != 0 {
+		t.Error(pub.events, r.Dropped())
+	}
+}
@@ -2,6 +2,7 @@ package vdevices
 
 import (
 	"fmt"
+	"sort"
 	"strconv"
 	"sync"
 
@@ -18,9 +19,17 @@ type Device struct {
 	masterParamset Paramset
 	channels       []GenericChannel
 	publisher      EventPublisher
+	synchronizer   Synchronizer
+	sealed         bool
 
 	// Handler for dispose of device (optional)
 	OnDispose func()
+
+	// Handler for a firmware update requested by the CCU (optional, see
+	// SetAvailableFirmware). The handler should start the update and report
+	// the progress with MaintenanceChannel.SetUpdateProgress. The device is
+	// not locked while executed.
+	OnFirmwareUpdate func() error
 }
 
 // check interface implementation
@@ -41,8 +50,33 @@ func NewDevice(address, deviceType string, publisher EventPublisher) *Device {
 	}
 }
 
-// Description implements interface GenericDevice.
+// NewSensorDevice creates a device, which mainly reports values (e.g. a
+// temperature sensor or a door contact). The device is always reachable and
+// accepts configuration changes after communication (RX mode always and
+// wakeup). A MaintenanceChannel is added as channel 0. The further channels
+// must be added by the caller.
+func NewSensorDevice(address, deviceType string, publisher EventPublisher) (*Device, *MaintenanceChannel) {
+	d := NewDevice(address, deviceType, publisher)
+	d.description.RXMode = itf.DeviceRXModeAlways | itf.DeviceRXModeWakeUp
+	return d, NewMaintenanceChannel(d)
+}
+
+// NewActuatorDevice creates a device, which mainly receives commands (e.g. a
+// switch or a dimmer). The device is always reachable (RX mode always). A
+// MaintenanceChannel is added as channel 0. The further channels must be added
+// by the caller.
+func NewActuatorDevice(address, deviceType string, publisher EventPublisher) (*Device, *MaintenanceChannel) {
+	d := NewDevice(address, deviceType, publisher)
+	d.description.RXMode = itf.DeviceRXModeAlways
+	return d, NewMaintenanceChannel(d)
+}
+
+// Description implements interface GenericDevice. On changes (e.g.
+// SetFirmware) the description is replaced, so the returned description can
+// be read without locking the device.
 func (d *Device) Description() *itf.DeviceDescription {
+	d.Lock()
+	defer d.Unlock()
 	return d.description
 }
 
@@ -57,7 +91,7 @@ func (d *Device) Channels() []GenericChannel {
 func (d *Device) Channel(channelAddress string) (GenericChannel, error) {
 	ch, err := strconv.Atoi(channelAddress)
 	if err != nil || ch < 0 || ch >= len(d.channels) {
-		return nil, fmt.Errorf("Channel in device %s not found: %s", d.description.Address, channelAddress)
+		return nil, fmt.Errorf("Channel in device %s not found: %s", d.Description().Address, channelAddress)
 	}
 	return d.channels[ch], nil
 }
@@ -67,10 +101,24 @@ func (d *Device) MasterParamset() GenericParamset {
 	return &d.masterParamset
 }
 
-// AddChannel binds a channel to the device. Following fields in the channels
-// description are initialized: Parent, ParentType, Address, Index. Publisher of
-// the channel is set to the publisher of the device.
+// AddChannel binds a channel to the device (see TryAddChannel). If the device
+// is sealed, an error is logged and the channel is not added.
 func (d *Device) AddChannel(channel GenericChannel) {
+	if err := d.TryAddChannel(channel); err != nil {
+		log.Error(err)
+	}
+}
+
+// TryAddChannel binds a channel to the device. Following fields in the
+// channels description are initialized: Parent, ParentType, Address, Index.
+// Publisher of the channel is set to the publisher of the device. If the
+// device is sealed, an error is returned.
+func (d *Device) TryAddChannel(channel GenericChannel) error {
+	d.Lock()
+	defer d.Unlock()
+	if d.sealed {
+		return d.sealedErr()
+	}
 	// complement channel description
 	idx := len(d.channels)
 	descr := channel.Description()
@@ -82,12 +130,161 @@ func (d *Device) AddChannel(channel GenericChannel) {
 	channel.SetPublisher(d.publisher)
 	d.channels = append(d.channels, channel)
 	d.description.Children = append(d.description.Children, descr.Address)
+	return nil
 }
 
-// AddMasterParam adds a parameter to the master paramset.
+// AddMasterParam adds a parameter to the master paramset (see
+// TryAddMasterParam). If the device is sealed, an error is logged and the
+// parameter is not added.
 func (d *Device) AddMasterParam(parameter GenericParameter) {
+	if err := d.TryAddMasterParam(parameter); err != nil {
+		log.Error(err)
+	}
+}
+
+// TryAddMasterParam adds a parameter to the master paramset. If the device is
+// sealed, an error is returned.
+func (d *Device) TryAddMasterParam(parameter GenericParameter) error {
+	d.Lock()
+	defer d.Unlock()
+	if d.sealed {
+		return d.sealedErr()
+	}
 	parameter.SetParentDescr(d.description)
 	d.masterParamset.Add(parameter)
+	return nil
+}
+
+// Seal marks the structure of the device (channels and master parameters) as
+// finalized. Afterwards TryAddChannel and TryAddMasterParam return an error.
+// Container.AddDevice seals the device automatically.
+func (d *Device) Seal() {
+	d.Lock()
+	defer d.Unlock()
+	d.sealed = true
+}
+
+// Sealed returns true, if the device is sealed.
+func (d *Device) Sealed() bool {
+	d.Lock()
+	defer d.Unlock()
+	return d.sealed
+}
+
+func (d *Device) sealedErr() error {
+	return fmt.Errorf("Structure of device %s can not be changed, device is already added", d.description.Address)
+}
+
+// SetRXMode sets the receive modes of the device. mode is a bit mask of the
+// constants itf.DeviceRXMode... (e.g. itf.DeviceRXModeBurst for battery
+// powered devices). If the RX mode changes, the version of the device
+// description is incremented (see updateDescription).
+func (d *Device) SetRXMode(mode int) {
+	d.updateDescription(func(descr *itf.DeviceDescription) bool {
+		if descr.RXMode == mode {
+			return false
+		}
+		descr.RXMode = mode
+		return true
+	})
+}
+
+// SetRoaming enables or disables roaming for the device. If the roaming
+// setting changes, the version of the device description is incremented (see
+// updateDescription).
+func (d *Device) SetRoaming(roaming bool) {
+	var r int
+	if roaming {
+		r = 1
+	}
+	d.updateDescription(func(descr *itf.DeviceDescription) bool {
+		if descr.Roaming == r {
+			return false
+		}
+		descr.Roaming = r
+		return true
+	})
+}
+
+// SetFirmware sets the version of the installed firmware. If the firmware
+// changes, the version of the device description is incremented (see
+// updateDescription).
+func (d *Device) SetFirmware(version string) {
+	d.updateDescription(func(descr *itf.DeviceDescription) bool {
+		if descr.Firmware == version {
+			return false
+		}
+		descr.Firmware = version
+		d.updateUpdatable(descr)
+		return true
+	})
+}
+
+// SetAvailableFirmware sets the version of the firmware, which can be
+// installed. The device is flagged as updatable, if the version differs from
+// the installed firmware and OnFirmwareUpdate is set. If the available
+// firmware changes, the version of the device description is incremented (see
+// updateDescription).
+func (d *Device) SetAvailableFirmware(version string) {
+	d.updateDescription(func(descr *itf.DeviceDescription) bool {
+		if descr.AvailableFirmware == version {
+			return false
+		}
+		descr.AvailableFirmware = version
+		d.updateUpdatable(descr)
+		return true
+	})
+}
+
+// updateDescription applies a change to a copy of the device description.
+// Previously returned descriptions are not modified, so they can be read
+// without locking the device. If change returns true, the version of the
+// description is incremented and the logic layers are synchronized.
+func (d *Device) updateDescription(change func(descr *itf.DeviceDescription) bool) {
+	d.Lock()
+	descr := *d.description
+	if !change(&descr) {
+		d.Unlock()
+		return
+	}
+	descr.Version++
+	d.description = &descr
+	synchronizer := d.synchronizer
+	d.Unlock()
+	if synchronizer != nil {
+		synchronizer.Synchronize()
+	}
+}
+
+// setSynchronizer is called by the Container, when the device is added.
+func (d *Device) setSynchronizer(synchronizer Synchronizer) {
+	d.Lock()
+	defer d.Unlock()
+	d.synchronizer = synchronizer
+}
+
+// updateUpdatable sets the UPDATABLE flag. The device must be locked.
+func (d *Device) updateUpdatable(descr *itf.DeviceDescription) {
+	if d.OnFirmwareUpdate != nil && descr.AvailableFirmware != "" &&
+		descr.AvailableFirmware != descr.Firmware {
+		descr.Updatable = 1
+	} else {
+		descr.Updatable = 0
+	}
+}
+
+// UpdateFirmware is called by the Handler, when the CCU requests a firmware
+// update. OnFirmwareUpdate gets called. If OnFirmwareUpdate is not set, false
+// is returned.
+func (d *Device) UpdateFirmware() (bool, error) {
+	if d.OnFirmwareUpdate == nil {
+		log.Warningf("Firmware update not supported by device %s", d.Description().Address)
+		return false, nil
+	}
+	if err := d.OnFirmwareUpdate(); err != nil {
+		return false, err
+	}
+	return true, nil
 }
 
 // Dispose must be called, when the device should free resources. Function
@@ -111,9 +308,18 @@ type Channel struct {
 	masterParamset Paramset
 	valueParamset  Paramset
 	publisher      EventPublisher
+	configPending  *BoolParameter
 
 	// Handler for dispose of channel (optional)
 	OnDispose func()
+
+	// Handler for the device test of the CCU (optional). If the handler returns
+	// false, the test is not confirmed. The channel is locked while executed.
+	OnInstallTest func() bool
+
+	// If CoalesceEvents is set and the publisher supports it, SetValues
+	// publishes the value changes as one batch.
+	CoalesceEvents bool
 }
 
 // check interface implementation
@@ -145,6 +351,64 @@ func (c *Channel) ValueParamset() GenericParamset {
 	return &c.valueParamset
 }
 
+// Snapshot returns the values of all parameters in the VALUES paramset. The
+// channel is locked while reading, so the values are consistent with each
+// other. The channel must not be locked by the caller.
+func (c *Channel) Snapshot() map[string]interface{} {
+	c.Lock()
+	defer c.Unlock()
+	vs := make(map[string]interface{}, c.valueParamset.Len())
+	for _, p := range c.valueParamset.Parameters() {
+		vs[p.Description().ID] = p.Value()
+	}
+	return vs
+}
+
+// SetValues sets multiple values of the VALUES paramset (see
+// GenericParameter.InternalSetValue). The channel is locked while setting the
+// values, so the channel must not be locked by the caller. If a parameter is
+// unknown, no value is set.
+func (c *Channel) SetValues(values map[string]interface{}) error {
+	c.Lock()
+	defer c.Unlock()
+	// lookup parameters in a stable order
+	ids := make([]string, 0, len(values))
+	for id := range values {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	params := make([]GenericParameter, len(ids))
+	for i, id := range ids {
+		p, err := c.valueParamset.Parameter(id)
+		if err != nil {
+			return fmt.Errorf("Setting of values of channel %s failed: %v", c.description.Address, err)
+		}
+		params[i] = p
+	}
+	// collect events, if requested
+	bp, ok := c.publisher.(BatchEventPublisher)
+	if c.CoalesceEvents && ok {
+		col := &eventCollector{}
+		col.actions, _ = c.publisher.(actionPublisher)
+		for _, p := range params {
+			p.SetPublisher(col)
+		}
+		defer func() {
+			for _, p := range params {
+				p.SetPublisher(c.publisher)
+			}
+			bp.PublishEvents(col.events)
+		}()
+	}
+	// set values
+	for i, p := range params {
+		if err := p.InternalSetValue(values[ids[i]]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // SetPublisher implements interface GenericChannel.
 func (c *Channel) SetPublisher(pub EventPublisher) {
 	c.publisher = pub
@@ -168,6 +432,24 @@ func (c *Channel) AddValueParam(parameter GenericParameter) {
 	c.valueParamset.Add(parameter)
 }
 
+// EnableConfigPending adds the CONFIG_PENDING parameter to the VALUES paramset.
+// It signals the CCU that changes of the configuration are not yet applied.
+// This function must be called before the device is added to the Container.
+func (c *Channel) EnableConfigPending() {
+	c.configPending = NewBoolParameter("CONFIG_PENDING")
+	c.configPending.description.Operations = itf.ParameterOperationRead | itf.ParameterOperationEvent
+	c.configPending.description.Flags = itf.ParameterFlagVisible | itf.ParameterFlagService
+	c.AddValueParam(c.configPending)
+}
+
+// SetConfigPending sets CONFIG_PENDING. EnableConfigPending must be called
+// before, otherwise the value is ignored.
+func (c *Channel) SetConfigPending(value bool) {
+	if c.configPending != nil {
+		c.configPending.InternalSetValue(value)
+	}
+}
+
 // Dispose must be called, when the channel should free resources. Function
 // OnDispose gets called, if specified.
 func (c *Channel) Dispose() {
@@ -176,6 +458,46 @@ func (c *Channel) Dispose() {
 	}
 }
 
+// JoinTeam declares a channel as member of a team (e.g. interconnected smoke
+// detectors). TEAM and TEAM_TAG of the member, and TEAM_TAG and TEAM_CHANNELS
+// of the team channel are updated. The device descriptions must not change
+// after adding the devices to the container, therefore JoinTeam must be called
+// beforehand.
+func JoinTeam(team, member GenericChannel, teamTag string) {
+	td, md := team.Description(), member.Description()
+	md.Team = td.Address
+	md.TeamTag = teamTag
+	td.TeamTag = teamTag
+	for _, a := range td.TeamChannels {
+		if a == md.Address {
+			return
+		}
+	}
+	td.TeamChannels = append(td.TeamChannels, md.Address)
+}
+
+// LeaveTeam removes a channel from its team. The team channel must be
+// specified, because only the address is stored in the member. The same
+// restrictions as for JoinTeam apply.
+func LeaveTeam(team, member GenericChannel) {
+	td, md := team.Description(), member.Description()
+	if md.Team != td.Address {
+		return
+	}
+	md.Team = ""
+	md.TeamTag = ""
+	cs := td.TeamChannels[:0]
+	for _, a := range td.TeamChannels {
+		if a != md.Address {
+			cs = append(cs, a)
+		}
+	}
+	if len(cs) == 0 {
+		cs = nil
+	}
+	td.TeamChannels = cs
+}
+
 // Paramset implements GenericParamset.
 type Paramset struct {
 	params map[string]GenericParameter
@@ -184,6 +506,10 @@ type Paramset struct {
 	// putParamset. The corresponding device or channel is locked while
 	// executed.
 	putParamsetHandler func()
+
+	// The optional validatePutParamset is called before applying the values of
+	// the RPC method putParamset.
+	validatePutParamset func(values map[string]interface{}) error
 }
 
 // check interface implementation
@@ -207,6 +533,22 @@ func (s *Paramset) Parameter(id string) (GenericParameter, error) {
 	return p, nil
 }
 
+// Descriptions returns the descriptions of all parameters ordered by TabOrder
+// and ID.
+func (s *Paramset) Descriptions() []*itf.ParameterDescription {
+	return ParamsetDescriptions(s)
+}
+
+// ParamsetDescriptions returns the descriptions of all parameters of a
+// paramset ordered by TabOrder and ID.
+func ParamsetDescriptions(ps GenericParamset) []*itf.ParameterDescription {
+	descrs := make(itf.ParamsetDescription, ps.Len())
+	for _, p := range ps.Parameters() {
+		descrs[p.Description().ID] = p.Description()
+	}
+	return descrs.Sorted()
+}
+
 // Len implements interface GenericParamset.
 func (s *Paramset) Len() int {
 	return len(s.params)
@@ -224,6 +566,24 @@ func (s *Paramset) HandlePutParamset(f func()) {
 	s.putParamsetHandler = f
 }
 
+// OnValidatePutParamset registers a validator for the values of the RPC method
+// putParamset. The validator is called with all values before any value is
+// applied, so that combinations of parameters (e.g. min < max) can be checked.
+// If an error is returned, the whole putParamset is rejected. The
+// corresponding device or channel is locked while executed.
+func (s *Paramset) OnValidatePutParamset(f func(values map[string]interface{}) error) {
+	s.validatePutParamset = f
+}
+
+// ValidatePutParamset calls the validator registered with
+// OnValidatePutParamset.
+func (s *Paramset) ValidatePutParamset(values map[string]interface{}) error {
+	if s.validatePutParamset != nil {
+		return s.validatePutParamset(values)
+	}
+	return nil
+}
+
 // Add adds a parameter to this parameter set.
 func (s *Paramset) Add(param GenericParameter) {
 	if s.params == nil {
@@ -231,3 +591,22 @@ func (s *Paramset) Add(param GenericParameter) {
 	}
 	s.params[param.Description().ID] = param
 }
+
+// eventCollector is an EventPublisher, which collects the events. Events of
+// ACTION parameters are forwarded immediately to actions, if set.
+type eventCollector struct {
+	events  []Event
+	actions actionPublisher
+}
+
+func (c *eventCollector) PublishEvent(address, valueKey string, value interface{}) {
+	c.events = append(c.events, Event{Address: address, ValueKey: valueKey, Value: value})
+}
+
+func (c *eventCollector) PublishActionEvent(address, valueKey string, value interface{}) {
+	if c.actions == nil {
+		c.PublishEvent(address, valueKey, value)
+		return
+	}
+	c.actions.PublishActionEvent(address, valueKey, value)
+}
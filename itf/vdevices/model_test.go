@@ -1,11 +1,15 @@
 package vdevices
 
 import (
+	"errors"
+	"fmt"
 	"net/http/httptest"
 	"reflect"
 	"strings"
+	"sync"
 	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/mdzio/go-hmccu/itf"
 	"github.com/mdzio/go-hmccu/itf/xmlrpc"
@@ -169,3 +173,1022 @@ func TestModel(t *testing.T) {
 		}
 	}
 }
+
+func TestDeviceRXModeRoaming(t *testing.T) {
+	dev := NewDevice("JCK000", "HmIP-MIO16-PCB", nil)
+	if dev.Description().Version != 1 {
+		t.Fatal("unexpected version")
+	}
+	dev.SetRXMode(itf.DeviceRXModeBurst | itf.DeviceRXModeWakeUp)
+	dev.SetRoaming(true)
+	// no changes
+	dev.SetRXMode(itf.DeviceRXModeBurst | itf.DeviceRXModeWakeUp)
+	dev.SetRoaming(true)
+	d := dev.Description()
+	if d.RXMode != 0x0A || d.Roaming != 1 || d.Version != 3 {
+		t.Fatal(d)
+	}
+
+	// changes after adding are synchronized
+	syn := &testSynchronizer{}
+	c := NewContainer()
+	c.Synchronizer = syn
+	if err := c.AddDevice(dev); err != nil {
+		t.Fatal(err)
+	}
+	dev.SetRXMode(itf.DeviceRXModeBurst)
+	dev.SetRoaming(false)
+	if dev.Description().Version != 5 || syn.count != 3 {
+		t.Error(dev.Description(), syn.count)
+	}
+	if d.RXMode != 0x0A || d.Roaming != 1 {
+		t.Error("returned description modified")
+	}
+}
+
+func TestDeviceDescriptionUpdate(t *testing.T) {
+	syn := &testSynchronizer{}
+	c := NewContainer()
+	c.Synchronizer = syn
+	dev := NewDevice("JCK000", "HmIP-PSM", nil)
+	if err := c.AddDevice(dev); err != nil {
+		t.Fatal(err)
+	}
+	old := dev.Description()
+
+	// concurrent readers, e.g. Handler.ListDevices
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			_ = dev.Description().Firmware
+		}
+	}()
+	dev.SetFirmware("1.1")
+	<-done
+
+	d := dev.Description()
+	if d.Firmware != "1.1" || d.Version != 2 || syn.count != 2 {
+		t.Error(d, syn.count)
+	}
+	if old.Firmware != "" || old.Version != 1 {
+		t.Error("returned description modified")
+	}
+	// no changes
+	dev.SetFirmware("1.1")
+	if syn.count != 2 {
+		t.Error(syn.count)
+	}
+}
+
+func TestDeviceArchetypes(t *testing.T) {
+	dev, mch := NewSensorDevice("JCK000", "HM-WDS10-TH-O", nil)
+	NewTemperatureChannel(dev)
+	d := dev.Description()
+	if d.RXMode != itf.DeviceRXModeAlways|itf.DeviceRXModeWakeUp || d.Version != 1 {
+		t.Error(d)
+	}
+	if !reflect.DeepEqual(d.Children, []string{"JCK000:0", "JCK000:1"}) {
+		t.Error(d.Children)
+	}
+	if mch.Description().Type != "MAINTENANCE" || mch.Description().Address != "JCK000:0" {
+		t.Error(mch.Description())
+	}
+
+	dev, mch = NewActuatorDevice("JCK001", "HM-LC-Sw1-Pl", nil)
+	NewSwitchChannel(dev)
+	if dev.Description().RXMode != itf.DeviceRXModeAlways || len(dev.Channels()) != 2 || dev.Channels()[0] != mch {
+		t.Error(dev.Description())
+	}
+}
+
+func TestChannelSnapshot(t *testing.T) {
+	dev := NewDevice("JCK000", "HmIP-PSM", nil)
+	pm := NewPowerMeterChannel(dev)
+	pm.SetPower(12.5)
+	pm.SetVoltage(230.0)
+	s := pm.Snapshot()
+	if s["POWER"] != 12.5 || s["VOLTAGE"] != 230.0 || len(s) != 7 {
+		t.Error(s)
+	}
+}
+
+type testPublisher struct {
+	events []interface{}
+	keys   []string // "address valueKey" of the events
+}
+
+func (p *testPublisher) PublishEvent(address, valueKey string, value interface{}) {
+	p.events = append(p.events, value)
+	p.keys = append(p.keys, address+" "+valueKey)
+}
+
+func TestParameterTransform(t *testing.T) {
+	pub := &testPublisher{}
+	dev := NewDevice("JCK000", "HM-LC-Dim1T-Pl", pub)
+	ch := new(Channel)
+	ch.Init("DIMMER")
+	dev.AddChannel(ch)
+	level := NewFloatParameter("LEVEL")
+	ch.AddValueParam(level)
+	// application uses 0..100, CCU uses 0..1
+	level.SetTransform(
+		func(in interface{}) (interface{}, error) {
+			f, ok := in.(float64)
+			if !ok {
+				return nil, errors.New("not a float")
+			}
+			return f * 100, nil
+		},
+		func(in interface{}) (interface{}, error) {
+			return in.(float64) / 100, nil
+		},
+	)
+	if level.Description().Flags&itf.ParameterFlagTransform == 0 {
+		t.Error("missing transform flag")
+	}
+
+	if err := level.SetValue(0.5); err != nil {
+		t.Fatal(err)
+	}
+	if level.Value() != 50.0 {
+		t.Error(level.Value())
+	}
+	if err := level.InternalSetValue(25.0); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(pub.events, []interface{}{0.5, 0.25}) {
+		t.Error(pub.events)
+	}
+	if v, err := ccuValue(level); err != nil || v != 0.25 {
+		t.Error(v, err)
+	}
+	if err := level.SetValue("x"); err == nil {
+		t.Error("expected error")
+	}
+}
+
+type testBatchPublisher struct {
+	testPublisher
+	batches [][]Event
+}
+
+func (p *testBatchPublisher) PublishEvents(events []Event) {
+	p.batches = append(p.batches, events)
+}
+
+func TestChannelSetValues(t *testing.T) {
+	pub := &testBatchPublisher{}
+	dev := NewDevice("JCK000", "HmIP-PSM", pub)
+	pm := NewPowerMeterChannel(dev)
+
+	err := pm.SetValues(map[string]interface{}{"POWER": 1.0, "VOLTAGE": 230.0})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(pub.events, []interface{}{1.0, 230.0}) || len(pub.batches) != 0 {
+		t.Error(pub.events, pub.batches)
+	}
+
+	pub.events = nil
+	pm.CoalesceEvents = true
+	err = pm.SetValues(map[string]interface{}{"POWER": 2.0, "VOLTAGE": 231.0})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := [][]Event{{
+		{Address: "JCK000:0", ValueKey: "POWER", Value: 2.0},
+		{Address: "JCK000:0", ValueKey: "VOLTAGE", Value: 231.0},
+	}}
+	if len(pub.events) != 0 || !reflect.DeepEqual(pub.batches, want) {
+		t.Error(pub.events, pub.batches)
+	}
+
+	// publisher is restored
+	pm.SetPower(3.0)
+	if !reflect.DeepEqual(pub.events, []interface{}{3.0}) {
+		t.Error(pub.events)
+	}
+
+	err = pm.SetValues(map[string]interface{}{"UNKNOWN": 1.0})
+	if err == nil {
+		t.Error("expected error")
+	}
+}
+
+func TestPublishOnChangeOnly(t *testing.T) {
+	pub := &testPublisher{}
+	dev := NewDevice("JCK000", "HmIP-STH", pub)
+	ch := new(Channel)
+	ch.Init("CLIMATE")
+	dev.AddChannel(ch)
+	temp := NewFloatParameter("ACTUAL_TEMPERATURE")
+	temp.PublishOnChangeOnly = true
+	temp.Epsilon = 0.01
+	ch.AddValueParam(temp)
+	press := NewBoolParameter("PRESS_SHORT")
+	press.Description().Type = itf.ParameterTypeAction
+	press.PublishOnChangeOnly = true
+	ch.AddValueParam(press)
+
+	temp.InternalSetValue(20.0)
+	temp.InternalSetValue(20.005)
+	temp.InternalSetValue(20.1)
+	press.InternalSetValue(true)
+	press.InternalSetValue(true)
+	if !reflect.DeepEqual(pub.events, []interface{}{20.0, 20.1, true, true}) {
+		t.Error(pub.events)
+	}
+
+	// slow drift
+	pub.events = nil
+	temp.InternalSetValue(20.104)
+	temp.InternalSetValue(20.108)
+	temp.InternalSetValue(20.112)
+	if !reflect.DeepEqual(pub.events, []interface{}{20.112}) {
+		t.Error(pub.events)
+	}
+}
+
+func TestParamsetDescriptions(t *testing.T) {
+	dev := NewDevice("JCK000", "HmIP-PSM", nil)
+	pm := NewPowerMeterChannel(dev)
+	var ids []string
+	for _, d := range ParamsetDescriptions(pm.ValueParamset()) {
+		ids = append(ids, d.ID)
+	}
+	want := []string{"ENERGY_COUNTER", "INSTALL_TEST", "POWER", "CURRENT", "VOLTAGE", "FREQUENCY", "BOOT"}
+	if !reflect.DeepEqual(ids, want) {
+		t.Error(ids)
+	}
+	if len(pm.valueParamset.Descriptions()) != len(ids) {
+		t.Error(pm.valueParamset.Descriptions())
+	}
+	// types of the enumerated parameters
+	types := make(map[string]string)
+	for _, p := range pm.ValueParamset().Parameters() {
+		types[p.Description().ID] = p.Description().Type
+	}
+	wantTypes := map[string]string{
+		"ENERGY_COUNTER": itf.ParameterTypeFloat,
+		"INSTALL_TEST":   itf.ParameterTypeAction,
+		"POWER":          itf.ParameterTypeFloat,
+		"CURRENT":        itf.ParameterTypeFloat,
+		"VOLTAGE":        itf.ParameterTypeFloat,
+		"FREQUENCY":      itf.ParameterTypeFloat,
+		"BOOT":           itf.ParameterTypeBool,
+	}
+	if !reflect.DeepEqual(types, wantTypes) {
+		t.Error(types)
+	}
+}
+
+func TestInstallTest(t *testing.T) {
+	pub := &testPublisher{}
+	dev := NewDevice("JCK000", "HmIP-PSM", pub)
+	ch := NewPowerMeterChannel(dev)
+	it, err := ch.ValueParamset().Parameter("INSTALL_TEST")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// without OnInstallTest the test is confirmed by sending INSTALL_TEST back
+	if err := it.SetValue(true); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(pub.events, []interface{}{true}) ||
+		!reflect.DeepEqual(pub.keys, []string{"JCK000:0 INSTALL_TEST"}) {
+		t.Error(pub.events, pub.keys)
+	}
+
+	// test succeeds
+	pub.events, pub.keys = nil, nil
+	ch.OnInstallTest = func() bool { return true }
+	if err := it.SetValue(true); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(pub.keys, []string{"JCK000:0 INSTALL_TEST"}) {
+		t.Error(pub.keys)
+	}
+
+	// test fails
+	pub.events, pub.keys = nil, nil
+	called := false
+	ch.OnInstallTest = func() bool {
+		called = true
+		return false
+	}
+	if err := it.SetValue(true); err != nil {
+		t.Fatal(err)
+	}
+	if !called || len(pub.events) != 0 {
+		t.Error(called, pub.events)
+	}
+}
+
+func TestStickyUnreach(t *testing.T) {
+	pub := &testPublisher{}
+	dev := NewDevice("JCK000", "HmIP-PSM", pub)
+	mc := NewMaintenanceChannel(dev)
+	cleared := 0
+	mc.OnClearSticky = func() { cleared++ }
+
+	mc.SetUnreach(true)
+	mc.SetUnreach(false)
+	if !mc.StickyUnreach() {
+		t.Error("expected sticky unreach")
+	}
+	mc.ClearStickyUnreach()
+	if mc.StickyUnreach() || cleared != 0 {
+		t.Error(mc.StickyUnreach(), cleared)
+	}
+
+	// acknowledged by the CCU
+	mc.SetUnreach(true)
+	p, err := mc.ValueParamset().Parameter("STICKY_UNREACH")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.SetValue(false); err != nil {
+		t.Fatal(err)
+	}
+	if mc.StickyUnreach() || cleared != 1 {
+		t.Error(mc.StickyUnreach(), cleared)
+	}
+}
+
+func TestMaintenanceBattery(t *testing.T) {
+	pub := &testPublisher{}
+	dev := NewDevice("JCK000", "HmIP-SWDO", pub)
+	mc := NewMaintenanceChannel(dev)
+
+	// not enabled
+	mc.SetLowBat(true)
+	mc.SetOperatingVoltage(3.0)
+	if len(pub.events) != 0 {
+		t.Error(pub.events)
+	}
+
+	mc.EnableLowBat()
+	mc.EnableOperatingVoltage(0, 3.2)
+	p, err := mc.ValueParamset().Parameter("LOW_BAT")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Description().Flags&itf.ParameterFlagService == 0 {
+		t.Error(p.Description().Flags)
+	}
+	p, err = mc.ValueParamset().Parameter("OPERATING_VOLTAGE")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Description().Max != 3.2 || p.Description().Unit != "V" {
+		t.Error(p.Description())
+	}
+
+	mc.SetLowBat(true)
+	mc.SetOperatingVoltage(2.1)
+	if !reflect.DeepEqual(pub.events, []interface{}{true, 2.1}) {
+		t.Error(pub.events)
+	}
+}
+
+func TestConfigPending(t *testing.T) {
+	pub := &testPublisher{}
+	dev := NewDevice("JCK000", "HmIP-PSM", pub)
+	mc := NewMaintenanceChannel(dev)
+	mc.SetConfigPending(true)
+	if len(pub.events) != 0 {
+		t.Error(pub.events)
+	}
+	mc.EnableConfigPending()
+	mc.SetConfigPending(true)
+	mc.SetConfigPending(false)
+	if !reflect.DeepEqual(pub.events, []interface{}{true, false}) {
+		t.Error(pub.events)
+	}
+	if _, err := mc.ValueParamset().Parameter("CONFIG_PENDING"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestDutyCycle(t *testing.T) {
+	pub := &testPublisher{}
+	dev := NewDevice("JCK000", "HmIP-PSM", pub)
+	mc := NewMaintenanceChannel(dev)
+	mc.SetDutyCycle(true)
+	if len(pub.events) != 0 {
+		t.Error(pub.events)
+	}
+	mc.EnableDutyCycle()
+	mc.SetDutyCycle(true)
+	if !reflect.DeepEqual(pub.events, []interface{}{true}) {
+		t.Error(pub.events)
+	}
+	p, err := mc.ValueParamset().Parameter("DUTY_CYCLE")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Description().Operations&itf.ParameterOperationWrite != 0 {
+		t.Error(p.Description().Operations)
+	}
+}
+
+func TestDeviceSeal(t *testing.T) {
+	dev := NewDevice("ABC0000001", "TYPE", nil)
+	NewMaintenanceChannel(dev)
+	dev.AddMasterParam(NewBoolParameter("PARAM"))
+	if dev.Sealed() {
+		t.Fatal("sealed")
+	}
+
+	c := NewContainer()
+	c.Synchronizer = &testSynchronizer{}
+	if err := c.AddDevice(dev); err != nil {
+		t.Fatal(err)
+	}
+	if !dev.Sealed() {
+		t.Fatal("not sealed")
+	}
+
+	const sealedErr = "Structure of device ABC0000001 can not be changed, device is already added"
+	ch := new(Channel)
+	ch.Init("SWITCH")
+	if err := dev.TryAddChannel(ch); err == nil || err.Error() != sealedErr {
+		t.Error(err)
+	}
+	if err := dev.TryAddMasterParam(NewBoolParameter("OTHER")); err == nil || err.Error() != sealedErr {
+		t.Error(err)
+	}
+	// errors are logged
+	dev.AddChannel(ch)
+	dev.AddMasterParam(NewBoolParameter("OTHER"))
+	NewSwitchChannel(dev)
+	if len(dev.Channels()) != 1 || len(dev.Description().Children) != 1 || dev.MasterParamset().Len() != 1 {
+		t.Error("structure changed")
+	}
+}
+
+// fakeRamp replaces the clock and the ticker of a dimmer ramp.
+type fakeRamp struct {
+	begin   time.Time
+	ticks   chan time.Time
+	stopped chan struct{}
+}
+
+func newFakeRamp(ch *DimmerChannel) *fakeRamp {
+	r := &fakeRamp{begin: time.Unix(1000, 0)}
+	ch.rampNow = func() time.Time { return r.begin }
+	ch.rampTicker = func() (<-chan time.Time, func()) {
+		r.ticks = make(chan time.Time, 1)
+		r.stopped = make(chan struct{})
+		return r.ticks, func() { close(r.stopped) }
+	}
+	return r
+}
+
+// tick sends a tick d after the beginning of the ramp.
+func (r *fakeRamp) tick(d time.Duration) {
+	r.ticks <- r.begin.Add(d)
+}
+
+// wait waits until the ramp goroutine is terminated.
+func (r *fakeRamp) wait(t *testing.T) {
+	select {
+	case <-r.stopped:
+	case <-time.After(3 * time.Second):
+		t.Fatal("ramp not terminated")
+	}
+}
+
+func TestDimmerRampTo(t *testing.T) {
+	pub := &testPublisher{}
+	dev := NewDevice("ABC0000001", "TYPE", pub)
+	ch := NewDimmerChannel(dev)
+	r := newFakeRamp(ch)
+
+	ch.RampTo(1.0, 400*time.Millisecond)
+	ch.Lock()
+	working := ch.Working()
+	ch.Unlock()
+	if !working {
+		t.Error("not working")
+	}
+	for i := 1; i <= 4; i++ {
+		r.tick(time.Duration(i) * 100 * time.Millisecond)
+	}
+	r.wait(t)
+
+	ch.Lock()
+	defer ch.Unlock()
+	if ch.Level() != 1.0 || ch.Working() {
+		t.Error(ch.Level(), ch.Working())
+	}
+	// WORKING true, intermediate levels, final level, WORKING false
+	if !reflect.DeepEqual(pub.events, []interface{}{true, 0.25, 0.5, 0.75, 1.0, false}) {
+		t.Error(pub.events)
+	}
+}
+
+func TestDimmerRampToCancel(t *testing.T) {
+	dev := NewDevice("ABC0000001", "TYPE", &testPublisher{})
+	ch := NewDimmerChannel(dev)
+	r := newFakeRamp(ch)
+
+	ch.RampTo(1.0, time.Hour)
+	ch.RampTo(0.5, 0)
+	r.wait(t)
+	ch.Lock()
+	if ch.Level() != 0.5 || ch.Working() {
+		t.Error(ch.Level(), ch.Working())
+	}
+	ch.Unlock()
+
+	// LEVEL set by the CCU
+	ch.RampTo(1.0, time.Hour)
+	ch.Lock()
+	if err := ch.level.SetValue(0.3); err != nil {
+		t.Fatal(err)
+	}
+	ch.Unlock()
+	r.tick(time.Minute)
+	r.wait(t)
+	ch.Lock()
+	if ch.Level() != 0.3 {
+		t.Error(ch.Level())
+	}
+	ch.Unlock()
+
+	ch.RampTo(1.0, time.Hour)
+	dev.Dispose()
+	ch.Lock()
+	if ch.rampStop != nil {
+		t.Error("ramp not stopped")
+	}
+	ch.Unlock()
+}
+
+// fakeOnTimer replaces the timer of an ON_TIME.
+type fakeOnTimer struct {
+	durations []time.Duration
+	actions   []func()
+	stopped   int
+}
+
+func newFakeOnTimer(t *onTimer) *fakeOnTimer {
+	f := &fakeOnTimer{}
+	t.afterFunc = func(d time.Duration, action func()) func() {
+		f.durations = append(f.durations, d)
+		f.actions = append(f.actions, action)
+		return func() { f.stopped++ }
+	}
+	return f
+}
+
+// fire executes the action of the last started timer. The channel must not be
+// locked.
+func (f *fakeOnTimer) fire() {
+	f.actions[len(f.actions)-1]()
+}
+
+func TestDigitalChannelOnTime(t *testing.T) {
+	dev := NewDevice("ABC0000001", "TYPE", &testPublisher{})
+	ch := NewSwitchChannel(dev)
+	ch.EnableOnTime()
+	timer := newFakeOnTimer(&ch.onTimer)
+	var offs int
+	ch.OnSetState = func(value bool) bool {
+		if !value {
+			offs++
+		}
+		return true
+	}
+	setValue := func(id string, value interface{}) {
+		ch.Lock()
+		defer ch.Unlock()
+		p, err := ch.ValueParamset().Parameter(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := p.SetValue(value); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// auto off
+	setValue("ON_TIME", 0.1)
+	setValue("STATE", true)
+	timer.fire()
+	ch.Lock()
+	if ch.State() || offs != 1 || ch.onTimer.running() {
+		t.Error(ch.State(), offs)
+	}
+	if !reflect.DeepEqual(timer.durations, []time.Duration{100 * time.Millisecond}) {
+		t.Error(timer.durations)
+	}
+	ch.Unlock()
+
+	// cancelled by switching off
+	setValue("ON_TIME", 0.1)
+	setValue("STATE", true)
+	setValue("STATE", false)
+	ch.Lock()
+	if ch.onTimer.running() || timer.stopped != 1 {
+		t.Error("timer running")
+	}
+	ch.Unlock()
+	setValue("STATE", true)
+	timer.fire()
+	ch.Lock()
+	if !ch.State() || offs != 2 {
+		t.Error(ch.State(), offs)
+	}
+	ch.Unlock()
+
+	// restarted
+	setValue("ON_TIME", 0.1)
+	stale := timer.actions[len(timer.actions)-1]
+	setValue("ON_TIME", 0.3)
+	stale()
+	ch.Lock()
+	if !ch.State() || !ch.onTimer.running() {
+		t.Error("switched off too early")
+	}
+	ch.Unlock()
+	timer.fire()
+	ch.Lock()
+	if ch.State() || offs != 3 {
+		t.Error(ch.State(), offs)
+	}
+	ch.Unlock()
+
+	setValue("ON_TIME", 0.1)
+	dev.Dispose()
+	ch.Lock()
+	if ch.onTimer.running() {
+		t.Error("timer running")
+	}
+	ch.Unlock()
+}
+
+func TestDimmerAutoOff(t *testing.T) {
+	dev := NewDevice("ABC0000001", "TYPE", &testPublisher{})
+	ch := NewDimmerChannel(dev)
+	ch.EnableAutoOff()
+	timer := newFakeOnTimer(&ch.onTimer)
+	setValue := func(id string, value interface{}) {
+		ch.Lock()
+		defer ch.Unlock()
+		p, err := ch.ValueParamset().Parameter(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := p.SetValue(value); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// switched on from off
+	setValue("ON_TIME", 10.0)
+	setValue("LEVEL", 0.7)
+	timer.fire()
+	ch.Lock()
+	if ch.Level() != 0.0 {
+		t.Error(ch.Level())
+	}
+	if !reflect.DeepEqual(timer.durations, []time.Duration{10 * time.Second}) {
+		t.Error(timer.durations)
+	}
+	ch.Unlock()
+
+	// pre-ON level is restored and passed to OnSetLevel
+	ch.Lock()
+	ch.SetLevel(0.2)
+	ch.Unlock()
+	var levels []float64
+	ch.OnSetLevel = func(value float64) bool {
+		levels = append(levels, value)
+		return true
+	}
+	setValue("ON_TIME", 10.0)
+	setValue("LEVEL", 1.0)
+	timer.fire()
+	ch.Lock()
+	defer ch.Unlock()
+	if ch.Level() != 0.2 || !reflect.DeepEqual(levels, []float64{1.0, 0.2}) {
+		t.Error(ch.Level(), levels)
+	}
+}
+
+func TestDimmerOldLevel(t *testing.T) {
+	dev := NewDevice("ABC0000001", "TYPE", &testPublisher{})
+	ch := NewDimmerChannel(dev)
+	ch.Lock()
+	defer ch.Unlock()
+	setValue := func(id string, value interface{}) {
+		p, err := ch.ValueParamset().Parameter(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := p.SetValue(value); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ch.SetLevel(0.6)
+	setValue("LEVEL", 0.0)
+	if ch.OldLevel() != 0.6 {
+		t.Error(ch.OldLevel())
+	}
+	setValue("OLD_LEVEL", true)
+	if ch.Level() != 0.6 || ch.OldLevel() != 0.0 {
+		t.Error(ch.Level(), ch.OldLevel())
+	}
+
+	// restored level is passed to OnSetLevel
+	var levels []float64
+	accept := false
+	ch.OnSetLevel = func(value float64) bool {
+		levels = append(levels, value)
+		return accept
+	}
+	ch.SetLevel(0.0)
+	setValue("OLD_LEVEL", true)
+	if ch.Level() != 0.0 {
+		t.Error(ch.Level())
+	}
+	accept = true
+	setValue("OLD_LEVEL", true)
+	if ch.Level() != 0.6 || !reflect.DeepEqual(levels, []float64{0.6, 0.6}) {
+		t.Error(ch.Level(), levels)
+	}
+	ch.OnSetLevel = nil
+
+	// overridden by the application
+	called := false
+	ch.OnSetOldLevel = func() bool {
+		called = true
+		return true
+	}
+	setValue("LEVEL", 0.0)
+	setValue("OLD_LEVEL", true)
+	if !called || ch.Level() != 0.0 {
+		t.Error(called, ch.Level())
+	}
+}
+
+func TestFirmwareUpdate(t *testing.T) {
+	c := NewContainer()
+	h := NewHandler("", c, nil)
+	defer h.Close()
+	c.Synchronizer = h
+
+	dev := NewDevice("ABC0000001", "TYPE", h)
+	mch := NewMaintenanceChannel(dev)
+	mch.EnableFirmwareUpdate()
+	updated := false
+	dev.OnFirmwareUpdate = func() error {
+		updated = true
+		return nil
+	}
+	dev.SetFirmware("1.0")
+	dev.SetAvailableFirmware("1.1")
+	if dev.Description().Updatable != 1 {
+		t.Error("not updatable")
+	}
+	c.AddDevice(dev)
+	c.AddDevice(NewDevice("ABC0000002", "TYPE", h))
+
+	ok, err := h.UpdateFirmware("ABC0000001")
+	if err != nil || !ok || !updated {
+		t.Error(ok, err, updated)
+	}
+	ok, err = h.UpdateFirmware("ABC0000002")
+	if err != nil || ok {
+		t.Error(ok, err)
+	}
+
+	mch.Lock()
+	defer mch.Unlock()
+	mch.SetUpdateProgress(50)
+	if p, _ := mch.ValueParamset().Parameter("UPDATE_PENDING"); p.Value() != true {
+		t.Error("not pending")
+	}
+	mch.SetUpdateProgress(120)
+	if p, _ := mch.ValueParamset().Parameter("UPDATE_PROGRESS"); p.Value() != 100 {
+		t.Error(p.Value())
+	}
+	if p, _ := mch.ValueParamset().Parameter("UPDATE_PENDING"); p.Value() != false {
+		t.Error("pending")
+	}
+
+	dev.SetFirmware("1.1")
+	if dev.Description().Updatable != 0 {
+		t.Error("updatable")
+	}
+}
+
+func TestCounterAccumulator(t *testing.T) {
+	a := &CounterAccumulator{Max: 100}
+	for _, c := range []struct{ reading, want float64 }{
+		{10, 10},
+		{30, 30},
+		// reset of the source
+		{5, 35},
+		{50, 80},
+		// overflow
+		{70, 0},
+		{0, 0},
+		{20, 20},
+	} {
+		if got := a.Update(c.reading); got != c.want {
+			t.Errorf("reading %v: got %v, want %v", c.reading, got, c.want)
+		}
+	}
+
+	dev := NewDevice("JCK000", "HM-ES-TX-WM", nil)
+	ch := NewEnergyCounterChannel(dev)
+	ch.AccumulateEnergyCounter(12.5)
+	ch.AccumulateEnergyCounter(1.5)
+	if ch.EnergyCounter() != 14 || ch.EnergyAccumulator().Offset != 12.5 {
+		t.Error(ch.EnergyCounter(), ch.EnergyAccumulator().Offset)
+	}
+}
+
+func TestPulseBoot(t *testing.T) {
+	pub := &testPublisher{}
+	dev := NewDevice("JCK000", "HM-ES-PMSw1-Pl", pub)
+	NewMaintenanceChannel(dev)
+	pm := NewPowerMeterChannel(dev)
+	gc := NewGasCounterChannel(dev)
+	pub.events = nil
+
+	pm.PulseBoot()
+	gc.PulseBoot()
+	if !reflect.DeepEqual(pub.events, []interface{}{true, false, true, false}) {
+		t.Error(pub.events)
+	}
+	p, err := pm.ValueParamset().Parameter("BOOT")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Value() != false {
+		t.Error(p.Value())
+	}
+}
+
+func TestComputedParameter(t *testing.T) {
+	pub := &testPublisher{}
+	dev := NewDevice("JCK000", "HM-ES-PMSw1-Pl", pub)
+	ch := new(Channel)
+	ch.Init("POWERMETER")
+	dev.AddChannel(ch)
+	voltage := NewFloatParameter("VOLTAGE")
+	ch.AddValueParam(voltage)
+	current := NewFloatParameter("CURRENT")
+	ch.AddValueParam(current)
+	power := NewComputedParameter("POWER", func() float64 {
+		return voltage.Value().(float64) * current.Value().(float64)
+	}, voltage, current)
+	ch.AddValueParam(power)
+
+	voltage.InternalSetValue(230.0)
+	current.InternalSetValue(2.0)
+	if power.Value() != 460.0 {
+		t.Error(power.Value())
+	}
+	if err := current.SetValue(1.0); err != nil {
+		t.Fatal(err)
+	}
+	if power.Value() != 230.0 {
+		t.Error(power.Value())
+	}
+	if err := power.SetValue(1.0); err == nil {
+		t.Error("expected error")
+	}
+	// voltage, power, current, power, current, power
+	if !reflect.DeepEqual(pub.events, []interface{}{230.0, 0.0, 2.0, 460.0, 1.0, 230.0}) {
+		t.Error(pub.events)
+	}
+}
+
+type lockedPublisher struct {
+	mtx sync.Mutex
+	testPublisher
+}
+
+func (p *lockedPublisher) PublishEvent(address, valueKey string, value interface{}) {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+	p.testPublisher.PublishEvent(address, valueKey, value)
+}
+
+func TestStaleWatchdog(t *testing.T) {
+	dev := NewDevice("JCK000", "HM-WDS10-TH-O", &lockedPublisher{})
+	mc := NewMaintenanceChannel(dev)
+	ch := new(Channel)
+	ch.Init("WEATHER")
+	dev.AddChannel(ch)
+	temp := NewFloatParameter("TEMPERATURE")
+	ch.AddValueParam(temp)
+
+	unreach := func() interface{} {
+		mc.Lock()
+		defer mc.Unlock()
+		p, err := mc.ValueParamset().Parameter("UNREACH")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return p.Value()
+	}
+	waitUnreach := func(want bool) {
+		deadline := time.Now().Add(2 * time.Second)
+		for unreach() != want {
+			if time.Now().After(deadline) {
+				t.Fatal("UNREACH not", want)
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+	}
+
+	w := NewStaleWatchdog(mc, 50*time.Millisecond, temp)
+	defer w.Stop()
+	for i := 0; i < 5; i++ {
+		ch.Lock()
+		temp.InternalSetValue(float64(i))
+		ch.Unlock()
+		time.Sleep(10 * time.Millisecond)
+	}
+	if unreach() != false {
+		t.Error("unexpected UNREACH")
+	}
+	waitUnreach(true)
+	ch.Lock()
+	temp.InternalSetValue(20.0)
+	ch.Unlock()
+	waitUnreach(false)
+}
+
+func TestParameterOnChange(t *testing.T) {
+	pub := &testPublisher{}
+	dev := NewDevice("JCK000", "HmIP-PS", pub)
+	NewMaintenanceChannel(dev)
+	sch := NewSwitchChannel(dev)
+	p, err := sch.ValueParamset().Parameter("STATE")
+	if err != nil {
+		t.Fatal(err)
+	}
+	state := p.(*BoolParameter)
+	var changes []string
+	state.OnChange = func(value interface{}, source ChangeSource) {
+		changes = append(changes, fmt.Sprint(value, " ", source))
+	}
+
+	sch.Lock()
+	defer sch.Unlock()
+	if err := state.SetValue(true); err != nil {
+		t.Fatal(err)
+	}
+	state.InternalSetValue(false)
+	want := []string{"true external", "false internal"}
+	if !reflect.DeepEqual(changes, want) {
+		t.Error(changes)
+	}
+}
+
+func TestJoinTeam(t *testing.T) {
+	pub := &testPublisher{}
+	teamDev := NewDevice("*JCK000", "HM-SEC-SD-TEAM", pub)
+	team := new(Channel)
+	team.Init("SMOKE_DETECTOR_TEAM")
+	teamDev.AddChannel(team)
+	var members []*Channel
+	for _, addr := range []string{"JCK001", "JCK002"} {
+		dev := NewDevice(addr, "HM-SEC-SD", pub)
+		ch := new(Channel)
+		ch.Init("SMOKE_DETECTOR")
+		dev.AddChannel(ch)
+		members = append(members, ch)
+	}
+
+	JoinTeam(team, members[0], "TAG")
+	JoinTeam(team, members[1], "TAG")
+	JoinTeam(team, members[1], "TAG")
+	td := team.Description()
+	if !reflect.DeepEqual(td.TeamChannels, []string{"JCK001:0", "JCK002:0"}) || td.TeamTag != "TAG" {
+		t.Error(td.TeamChannels, td.TeamTag)
+	}
+	md := members[0].Description()
+	if md.Team != "*JCK000:0" || md.TeamTag != "TAG" || !md.IsTeamMember() {
+		t.Error(md.Team, md.TeamTag)
+	}
+
+	LeaveTeam(team, members[0])
+	if !reflect.DeepEqual(td.TeamChannels, []string{"JCK002:0"}) || md.Team != "" || md.TeamTag != "" {
+		t.Error(td.TeamChannels, md.Team, md.TeamTag)
+	}
+	LeaveTeam(team, members[1])
+	if td.IsTeam() {
+		t.Error(td.TeamChannels)
+	}
+}
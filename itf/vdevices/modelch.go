@@ -1,15 +1,80 @@
 package vdevices
 
 import (
+	"math"
+	"sync"
+	"time"
+
 	"github.com/mdzio/go-hmccu/itf"
 )
 
-// addInstallTest adds the INSTALL_TEST parameter for simulating a channel/device test
-func addInstallTest(ch GenericChannel) {
+// interval of the LEVEL updates while ramping (see DimmerChannel.RampTo)
+const rampInterval = 100 * time.Millisecond
+
+// onTimer executes an action after the ON_TIME of a channel has elapsed. The
+// channel must be locked while calling the methods.
+type onTimer struct {
+	// starts a timer, replaceable for tests (default: time.AfterFunc)
+	afterFunc func(d time.Duration, f func()) (stop func())
+	stopTimer func()
+	gen       int
+}
+
+// start (re)starts the timer. The action is executed with the channel locked.
+// A non-positive ON_TIME only stops the timer.
+func (t *onTimer) start(ch sync.Locker, onTime float64, action func()) {
+	t.stop()
+	if onTime <= 0 {
+		return
+	}
+	afterFunc := t.afterFunc
+	if afterFunc == nil {
+		afterFunc = func(d time.Duration, f func()) func() {
+			timer := time.AfterFunc(d, f)
+			return func() { timer.Stop() }
+		}
+	}
+	gen := t.gen
+	t.stopTimer = afterFunc(time.Duration(onTime*float64(time.Second)), func() {
+		ch.Lock()
+		defer ch.Unlock()
+		// stopped or restarted meanwhile?
+		if t.gen != gen {
+			return
+		}
+		t.stopTimer = nil
+		action()
+	})
+}
+
+// stop cancels a pending action.
+func (t *onTimer) stop() {
+	if t.stopTimer != nil {
+		t.stopTimer()
+		t.stopTimer = nil
+	}
+	t.gen++
+}
+
+// running returns true, if an action is pending.
+func (t *onTimer) running() bool {
+	return t.stopTimer != nil
+}
+
+// addInstallTest adds the INSTALL_TEST parameter for simulating a channel/device
+// test. If the test succeeds (see Channel.OnInstallTest), an INSTALL_TEST event
+// is sent back to the CCU to complete the test.
+func addInstallTest(ch *Channel) {
 	p := NewBoolParameter("INSTALL_TEST")
 	p.description.Type = itf.ParameterTypeAction
 	p.description.Operations = itf.ParameterOperationWrite
 	p.description.Flags = itf.ParameterFlagVisible | itf.ParameterFlagInternal
+	p.OnSetValue = func(bool) bool {
+		if ch.OnInstallTest != nil {
+			return ch.OnInstallTest()
+		}
+		return true
+	}
 	ch.AddValueParam(p)
 }
 
@@ -18,8 +83,17 @@ func addInstallTest(ch GenericChannel) {
 type MaintenanceChannel struct {
 	Channel
 
-	unreach       *BoolParameter
-	stickyUnreach *BoolParameter
+	// This callback is executed when the CCU clears STICKY_UNREACH (e.g. the
+	// user acknowledges the service message). The channel is locked.
+	OnClearSticky func()
+
+	unreach          *BoolParameter
+	stickyUnreach    *BoolParameter
+	lowBat           *BoolParameter
+	operatingVoltage *FloatParameter
+	dutyCycle        *BoolParameter
+	updatePending    *BoolParameter
+	updateProgress   *IntParameter
 }
 
 // NewMaintenanceChannel creates a new maintenance channel and adds it to the
@@ -30,7 +104,7 @@ func NewMaintenanceChannel(device *Device) *MaintenanceChannel {
 	c.description.Flags = itf.DeviceFlagVisible | itf.DeviceFlagInternal
 	// adding channel to device also initializes some fields
 	device.AddChannel(c)
-	addInstallTest(c)
+	addInstallTest(&c.Channel)
 
 	// add UNREACH parameter
 	c.unreach = NewBoolParameter("UNREACH")
@@ -42,6 +116,12 @@ func NewMaintenanceChannel(device *Device) *MaintenanceChannel {
 	c.stickyUnreach = NewBoolParameter("STICKY_UNREACH")
 	c.stickyUnreach.description.Operations = itf.ParameterOperationRead | itf.ParameterOperationWrite | itf.ParameterOperationEvent
 	c.stickyUnreach.description.Flags = itf.ParameterFlagVisible | itf.ParameterFlagService | itf.ParameterFlagSticky
+	c.stickyUnreach.OnSetValue = func(value bool) bool {
+		if !value && c.OnClearSticky != nil {
+			c.OnClearSticky()
+		}
+		return true
+	}
 	c.AddValueParam(c.stickyUnreach)
 	return c
 }
@@ -54,6 +134,189 @@ func (c *MaintenanceChannel) SetUnreach(value bool) {
 	}
 }
 
+// ClearStickyUnreach clears STICKY_UNREACH like an acknowledgement of the
+// service message by the user. OnClearSticky is not called.
+func (c *MaintenanceChannel) ClearStickyUnreach() {
+	c.stickyUnreach.InternalSetValue(false)
+}
+
+// StickyUnreach returns the state of STICKY_UNREACH.
+func (c *MaintenanceChannel) StickyUnreach() bool {
+	return c.stickyUnreach.Value().(bool)
+}
+
+// EnableLowBat adds the LOW_BAT parameter for battery powered devices. The CCU
+// lists the device in the low battery service messages, if LOW_BAT is set. This
+// function must be called before the device is added to the Container.
+func (c *MaintenanceChannel) EnableLowBat() {
+	c.lowBat = NewBoolParameter("LOW_BAT")
+	c.lowBat.description.Operations = itf.ParameterOperationRead | itf.ParameterOperationEvent
+	c.lowBat.description.Flags = itf.ParameterFlagVisible | itf.ParameterFlagService
+	c.AddValueParam(c.lowBat)
+}
+
+// SetLowBat sets the battery state of the device. EnableLowBat must be called
+// before, otherwise the value is ignored.
+func (c *MaintenanceChannel) SetLowBat(value bool) {
+	if c.lowBat != nil {
+		c.lowBat.InternalSetValue(value)
+	}
+}
+
+// EnableOperatingVoltage adds the OPERATING_VOLTAGE parameter with the
+// specified range in volts. This function must be called before the device is
+// added to the Container.
+func (c *MaintenanceChannel) EnableOperatingVoltage(min, max float64) {
+	c.operatingVoltage = NewFloatParameter("OPERATING_VOLTAGE")
+	c.operatingVoltage.description.Operations = itf.ParameterOperationRead | itf.ParameterOperationEvent
+	c.operatingVoltage.description.Min = min
+	c.operatingVoltage.description.Max = max
+	c.operatingVoltage.description.Unit = "V"
+	c.AddValueParam(c.operatingVoltage)
+}
+
+// EnableDutyCycle adds the DUTY_CYCLE parameter. It signals the CCU that the
+// transmission budget of the device is exhausted. This function must be called
+// before the device is added to the Container.
+func (c *MaintenanceChannel) EnableDutyCycle() {
+	c.dutyCycle = NewBoolParameter("DUTY_CYCLE")
+	c.dutyCycle.description.Operations = itf.ParameterOperationRead | itf.ParameterOperationEvent
+	c.dutyCycle.description.Flags = itf.ParameterFlagVisible | itf.ParameterFlagService
+	c.AddValueParam(c.dutyCycle)
+}
+
+// SetDutyCycle sets the duty cycle state of the device. EnableDutyCycle must be
+// called before, otherwise the value is ignored.
+func (c *MaintenanceChannel) SetDutyCycle(value bool) {
+	if c.dutyCycle != nil {
+		c.dutyCycle.InternalSetValue(value)
+	}
+}
+
+// EnableFirmwareUpdate adds the UPDATE_PENDING and UPDATE_PROGRESS parameters
+// to the VALUES paramset. UPDATE_PENDING signals, that a firmware update is
+// running. UPDATE_PROGRESS (0-100 %) is not a standard HM parameter, but can be
+// displayed by the CCU. This function must be called before the device is
+// added to the Container (see also Device.OnFirmwareUpdate).
+func (c *MaintenanceChannel) EnableFirmwareUpdate() {
+	c.updatePending = NewBoolParameter("UPDATE_PENDING")
+	c.updatePending.description.Operations = itf.ParameterOperationRead | itf.ParameterOperationEvent
+	c.updatePending.description.Flags = itf.ParameterFlagVisible | itf.ParameterFlagService
+	c.AddValueParam(c.updatePending)
+
+	c.updateProgress = NewIntParameter("UPDATE_PROGRESS")
+	c.updateProgress.description.Operations = itf.ParameterOperationRead | itf.ParameterOperationEvent
+	c.updateProgress.description.Min = 0
+	c.updateProgress.description.Max = 100
+	c.updateProgress.description.Unit = "%"
+	c.AddValueParam(c.updateProgress)
+}
+
+// SetUpdateProgress sets the progress of a firmware update in percent.
+// UPDATE_PENDING is set while the progress is below 100. EnableFirmwareUpdate
+// must be called before, otherwise the value is ignored.
+func (c *MaintenanceChannel) SetUpdateProgress(percent int) {
+	if c.updateProgress == nil {
+		return
+	}
+	if percent < 0 {
+		percent = 0
+	} else if percent > 100 {
+		percent = 100
+	}
+	c.updateProgress.InternalSetValue(percent)
+	c.updatePending.InternalSetValue(percent < 100)
+}
+
+// SetOperatingVoltage sets the operating voltage of the device.
+// EnableOperatingVoltage must be called before, otherwise the value is
+// ignored.
+func (c *MaintenanceChannel) SetOperatingVoltage(value float64) {
+	if c.operatingVoltage != nil {
+		c.operatingVoltage.InternalSetValue(value)
+	}
+}
+
+// StaleWatchdog sets UNREACH of a device, if none of the watched parameters is
+// set within a timeout (e.g. the source of a bridged sensor is offline).
+// UNREACH is cleared on the next update of a watched parameter. Listeners can
+// not be removed from parameters, so a watchdog lives as long as its parameters
+// (e.g. created together with the device). Stop only deactivates it.
+type StaleWatchdog struct {
+	maintenance *MaintenanceChannel
+	timeout     time.Duration
+
+	mtx     sync.Mutex
+	timer   *time.Timer
+	stale   bool
+	stopped bool
+
+	// UNREACH set by the watchdog, the maintenance channel must be locked
+	reported bool
+}
+
+// NewStaleWatchdog creates a StaleWatchdog for the specified parameters and
+// starts monitoring. The locks of the channels are acquired as needed.
+func NewStaleWatchdog(maintenance *MaintenanceChannel, timeout time.Duration, params ...Observable) *StaleWatchdog {
+	w := &StaleWatchdog{maintenance: maintenance, timeout: timeout}
+	w.timer = time.AfterFunc(timeout, w.expired)
+	for _, p := range params {
+		p.AddListener(w.updated)
+	}
+	return w
+}
+
+// Stop stops monitoring. The state of UNREACH is not modified.
+func (w *StaleWatchdog) Stop() {
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+	w.stopped = true
+	w.timer.Stop()
+}
+
+// updated is called, when a watched parameter is set. The channel of the
+// parameter is locked.
+func (w *StaleWatchdog) updated() {
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+	if w.stopped {
+		return
+	}
+	w.timer.Reset(w.timeout)
+	if w.stale {
+		w.stale = false
+		// the maintenance channel can not be locked here (lock order)
+		go w.apply()
+	}
+}
+
+func (w *StaleWatchdog) expired() {
+	w.mtx.Lock()
+	if w.stopped {
+		w.mtx.Unlock()
+		return
+	}
+	w.stale = true
+	w.mtx.Unlock()
+	w.apply()
+}
+
+// apply sets UNREACH according to the current state.
+func (w *StaleWatchdog) apply() {
+	w.maintenance.Lock()
+	defer w.maintenance.Unlock()
+	w.mtx.Lock()
+	stale := w.stale
+	w.mtx.Unlock()
+	if stale != w.reported {
+		if stale {
+			log.Warningf("Parameters of device %s are not updated anymore", w.maintenance.description.Parent)
+		}
+		w.maintenance.SetUnreach(stale)
+		w.reported = stale
+	}
+}
+
 // DigitalChannel implements a standard HM switch channel.
 type DigitalChannel struct {
 	Channel
@@ -62,7 +325,9 @@ type DigitalChannel struct {
 	// state. Only if this function returns true, the state is actually set.
 	OnSetState func(value bool) (ok bool)
 
-	state *BoolParameter
+	state   *BoolParameter
+	onTime  *FloatParameter
+	onTimer onTimer
 }
 
 // NewDigitalChannel creates a new HM digital channel and adds it to the device.
@@ -71,24 +336,66 @@ type DigitalChannel struct {
 func NewDigitalChannel(device *Device, channelType, control string) *DigitalChannel {
 	c := new(DigitalChannel)
 	c.Channel.Init(channelType)
-	// adding channel to device also initializes some fields
-	device.AddChannel(&c.Channel)
+	// adding channel to device also initializes some fields, Dispose of
+	// DigitalChannel must be called by the device
+	device.AddChannel(c)
 	addInstallTest(&c.Channel)
 
 	// add STATE parameter
 	c.state = NewBoolParameter("STATE")
 	c.state.description.Control = control
 	c.state.OnSetValue = func(value bool) bool {
+		ok := true
 		if c.OnSetState != nil {
-			return c.OnSetState(value)
-		} else {
-			return true
+			ok = c.OnSetState(value)
 		}
+		// switching off cancels the ON_TIME
+		if ok && !value {
+			c.onTimer.stop()
+		}
+		return ok
 	}
 	c.AddValueParam(c.state)
 	return c
 }
 
+// EnableOnTime adds the ON_TIME parameter to the VALUES paramset. If the CCU
+// writes ON_TIME (in seconds), the switch is turned off after the time has
+// elapsed. OnSetState is called before. Writing ON_TIME again restarts the
+// timer, 0 cancels it. This function must be called before the device is
+// added to the Container.
+func (c *DigitalChannel) EnableOnTime() {
+	c.onTime = NewFloatParameter("ON_TIME")
+	c.onTime.description.Operations = itf.ParameterOperationWrite
+	c.onTime.description.Control = "NONE"
+	c.onTime.description.Min = 0.0
+	c.onTime.description.Max = 8.58259456e+07
+	c.onTime.description.Unit = "s"
+	c.onTime.OnSetValue = func(value float64) bool {
+		c.onTimer.start(c, value, func() {
+			if c.OnSetState == nil || c.OnSetState(false) {
+				c.SetState(false)
+			}
+		})
+		return true
+	}
+	c.AddValueParam(c.onTime)
+}
+
+// CancelOnTime cancels a pending turn off (see EnableOnTime). The channel must
+// be locked.
+func (c *DigitalChannel) CancelOnTime() {
+	c.onTimer.stop()
+}
+
+// Dispose cancels a pending turn off and disposes the channel.
+func (c *DigitalChannel) Dispose() {
+	c.Lock()
+	c.onTimer.stop()
+	c.Unlock()
+	c.Channel.Dispose()
+}
+
 // SetState sets the state of the switch.
 func (c *DigitalChannel) SetState(value bool) {
 	c.state.InternalSetValue(value)
@@ -251,7 +558,9 @@ type DimmerChannel struct {
 	Channel
 
 	// These callbacks are executed when an external system wants to change the
-	// values. Only if the function returns true, the value is actually set.
+	// values. Only if the function returns true, the value is actually set. If
+	// OnSetOldLevel is not set, the previous level is restored (see OldLevel)
+	// after OnSetLevel has accepted it.
 	OnSetLevel    func(value float64) (ok bool)
 	OnSetOldLevel func() (ok bool)
 	OnSetRampTime func(value float64) (ok bool)
@@ -262,14 +571,23 @@ type DimmerChannel struct {
 	rampTime *FloatParameter
 	onTime   *FloatParameter
 	working  *BoolParameter
+	rampStop chan struct{}
+	// clock and ticker of a ramp, replaceable for tests
+	rampNow    func() time.Time
+	rampTicker func() (ticks <-chan time.Time, stop func())
+	autoOff    bool
+	onTimer    onTimer
+	// level before the last change
+	prevLevel float64
 }
 
 // NewDimmerChannel creates a new HM dimmer channel and adds it to the device.
 func NewDimmerChannel(device *Device) *DimmerChannel {
 	c := new(DimmerChannel)
 	c.Channel.Init("DIMMER")
-	// adding channel to device also initializes some fields
-	device.AddChannel(&c.Channel)
+	// adding channel to device also initializes some fields, Dispose of
+	// DimmerChannel must be called by the device
+	device.AddChannel(c)
 	addInstallTest(&c.Channel)
 
 	// add LEVEL parameter
@@ -281,11 +599,15 @@ func NewDimmerChannel(device *Device) *DimmerChannel {
 	c.level.description.Max = 1.0
 	c.level.description.Unit = "100%"
 	c.level.OnSetValue = func(value float64) bool {
+		ok := true
 		if c.OnSetLevel != nil {
-			return c.OnSetLevel(value)
-		} else {
-			return true
+			ok = c.OnSetLevel(value)
 		}
+		if ok {
+			c.stopRamp()
+			c.trackLevel(value)
+		}
+		return ok
 	}
 	c.AddValueParam(c.level)
 
@@ -298,9 +620,9 @@ func NewDimmerChannel(device *Device) *DimmerChannel {
 	c.oldLevel.OnSetValue = func(value bool) bool {
 		if c.OnSetOldLevel != nil {
 			return c.OnSetOldLevel()
-		} else {
-			return true
 		}
+		c.restoreLevel()
+		return true
 	}
 	c.AddValueParam(c.oldLevel)
 
@@ -336,11 +658,14 @@ func NewDimmerChannel(device *Device) *DimmerChannel {
 	c.onTime.description.Max = 8.58259456e+07
 	c.onTime.description.Unit = "s"
 	c.onTime.OnSetValue = func(value float64) bool {
+		ok := true
 		if c.OnSetOnTime != nil {
-			return c.OnSetOnTime(value)
-		} else {
-			return true
+			ok = c.OnSetOnTime(value)
 		}
+		if ok && c.autoOff {
+			c.onTimer.start(c, value, c.restoreLevel)
+		}
+		return ok
 	}
 	c.AddValueParam(c.onTime)
 
@@ -351,14 +676,43 @@ func NewDimmerChannel(device *Device) *DimmerChannel {
 	c.working.description.TabOrder = 4
 	c.AddValueParam(c.working)
 
+	c.rampNow = time.Now
+	c.rampTicker = func() (<-chan time.Time, func()) {
+		t := time.NewTicker(rampInterval)
+		return t.C, t.Stop
+	}
 	return c
 }
 
-// SetLevel sets the level of the dimmer.
+// SetLevel sets the level of the dimmer. The current level is remembered for
+// OLD_LEVEL. A running ramp is cancelled.
 func (c *DimmerChannel) SetLevel(value float64) {
+	c.stopRamp()
+	c.trackLevel(value)
 	c.level.InternalSetValue(value)
 }
 
+// OldLevel returns the level before the last change. If OnSetOldLevel is not
+// set, this level is restored, when the CCU writes OLD_LEVEL.
+func (c *DimmerChannel) OldLevel() float64 {
+	return c.prevLevel
+}
+
+// restoreLevel restores the level before the last change, if OnSetLevel
+// accepts it. The channel must be locked.
+func (c *DimmerChannel) restoreLevel() {
+	if c.OnSetLevel == nil || c.OnSetLevel(c.prevLevel) {
+		c.SetLevel(c.prevLevel)
+	}
+}
+
+// trackLevel remembers the current level, if it is changed.
+func (c *DimmerChannel) trackLevel(value float64) {
+	if cur := c.Level(); cur != value {
+		c.prevLevel = cur
+	}
+}
+
 // Level returns the level of the dimmer.
 func (c *DimmerChannel) Level() float64 {
 	return c.level.Value().(float64)
@@ -394,6 +748,94 @@ func (c *DimmerChannel) Working() bool {
 	return c.working.Value().(bool)
 }
 
+// RampTo changes the level of the dimmer linearly to target within the
+// specified duration. While ramping, WORKING is set and LEVEL is updated every
+// 100 ms. A running ramp is cancelled. Setting LEVEL (by SetLevel or by the
+// CCU) also cancels the ramp. RampTo returns immediately. The channel
+// is locked while setting the values, so the channel must not be locked by
+// the caller.
+func (c *DimmerChannel) RampTo(target float64, duration time.Duration) {
+	c.Lock()
+	defer c.Unlock()
+	c.stopRamp()
+	if duration <= 0 {
+		c.SetLevel(target)
+		c.SetWorking(false)
+		return
+	}
+	start := c.Level()
+	if start != target {
+		c.prevLevel = start
+	}
+	c.SetWorking(true)
+	stop := make(chan struct{})
+	c.rampStop = stop
+	begin := c.rampNow()
+	ticks, stopTicker := c.rampTicker()
+	go func() {
+		defer stopTicker()
+		for {
+			select {
+			case <-stop:
+				return
+			case now := <-ticks:
+				c.Lock()
+				// cancelled while waiting for the lock?
+				select {
+				case <-stop:
+					c.Unlock()
+					return
+				default:
+				}
+				frac := float64(now.Sub(begin)) / float64(duration)
+				// intermediate levels are not remembered for OLD_LEVEL
+				if frac >= 1 {
+					c.level.InternalSetValue(target)
+					c.SetWorking(false)
+					c.rampStop = nil
+					c.Unlock()
+					return
+				}
+				c.level.InternalSetValue(start + (target-start)*frac)
+				c.Unlock()
+			}
+		}
+	}()
+}
+
+// EnableAutoOff activates the handling of ON_TIME: If the CCU writes ON_TIME
+// (in seconds), the level before switching on (see OldLevel) is restored after
+// the time has elapsed. Usually the CCU writes LEVEL after ON_TIME, so that the
+// dimmer is turned off, if it was off before. OnSetLevel is called before.
+// Writing ON_TIME again restarts the timer, 0 cancels it.
+func (c *DimmerChannel) EnableAutoOff() {
+	c.autoOff = true
+}
+
+// CancelOnTime cancels a pending turn off (see EnableAutoOff). The channel
+// must be locked.
+func (c *DimmerChannel) CancelOnTime() {
+	c.onTimer.stop()
+}
+
+// stopRamp cancels a running ramp. The channel must be locked.
+func (c *DimmerChannel) stopRamp() {
+	if c.rampStop != nil {
+		close(c.rampStop)
+		c.rampStop = nil
+	}
+}
+
+// Dispose cancels a running ramp and a pending turn off, and disposes the
+// channel.
+func (c *DimmerChannel) Dispose() {
+	c.Lock()
+	c.stopRamp()
+	c.onTimer.stop()
+	c.Unlock()
+	c.Channel.Dispose()
+}
+
 // TemperatureChannel implements a HM temperature channel (e.g. HmIP-STHO:1).
 type TemperatureChannel struct {
 	Channel
@@ -522,6 +964,38 @@ func (c *TemperatureChannel) HumidityStatus() int {
 	return c.humidityStatus.Value().(int)
 }
 
+// CounterAccumulator converts the readings of a counter source, which may be
+// reset to zero (e.g. daily or on restart of the source), into a continuously
+// increasing counter as expected by the CCU. If Max is set, the result wraps
+// around at Max like the counter of a real device. The CCU detects this as an
+// overflow and continues the total correctly.
+type CounterAccumulator struct {
+	Max float64
+
+	// Offset is the sum of the readings before the resets. It can be persisted
+	// and restored to keep the counter over restarts.
+	Offset float64
+
+	last    float64
+	started bool
+}
+
+// Update processes a reading of the source and returns the accumulated
+// counter. A reading lower than the previous one is considered a reset of
+// the source.
+func (a *CounterAccumulator) Update(reading float64) float64 {
+	if a.started && reading < a.last {
+		a.Offset += a.last
+	}
+	a.last = reading
+	a.started = true
+	total := a.Offset + reading
+	if a.Max > 0 {
+		total = math.Mod(total, a.Max)
+	}
+	return total
+}
+
 // PowerMeterChannel implements a HM power meter channel (e.g. HM-ES-PMSw1-Pl:1).
 type PowerMeterChannel struct {
 	Channel
@@ -539,6 +1013,8 @@ type PowerMeterChannel struct {
 	current       *FloatParameter
 	voltage       *FloatParameter
 	frequency     *FloatParameter
+	boot          *BoolParameter
+	accumulator   CounterAccumulator
 }
 
 // NewPowerMeterChannel creates a new HM power meter channel and adds it to the
@@ -630,21 +1106,22 @@ func NewPowerMeterChannel(device *Device) *PowerMeterChannel {
 	}
 	c.AddValueParam(c.frequency)
 
-	// Add bool parameter with the fixed value true. This is needed so that
-	// meter overflows are better handled by the CCU total energy meter.
-	boot := NewBoolParameter("BOOT")
-	boot.description.Control = "POWERMETER.BOOT"
+	// Add bool parameter with the initial value true. This is needed so that
+	// meter overflows are better handled by the CCU total energy meter. See
+	// also PulseBoot.
+	c.boot = NewBoolParameter("BOOT")
+	c.boot.description.Control = "POWERMETER.BOOT"
 	// not writeable
-	boot.description.Operations = itf.ParameterOperationRead | itf.ParameterOperationEvent
+	c.boot.description.Operations = itf.ParameterOperationRead | itf.ParameterOperationEvent
 	// internal
-	boot.description.Flags = itf.ParameterFlagVisible | itf.ParameterFlagInternal
-	boot.description.TabOrder = 5
-	// fixed value true
-	boot.InternalSetValue(true)
-	boot.OnSetValue = func(value bool) bool {
+	c.boot.description.Flags = itf.ParameterFlagVisible | itf.ParameterFlagInternal
+	c.boot.description.TabOrder = 5
+	// initial value true
+	c.boot.InternalSetValue(true)
+	c.boot.OnSetValue = func(value bool) bool {
 		return false
 	}
-	c.AddValueParam(boot)
+	c.AddValueParam(c.boot)
 
 	return c
 }
@@ -657,6 +1134,28 @@ func (c *PowerMeterChannel) EnergyCounter() float64 {
 	return c.energyCounter.Value().(float64)
 }
 
+// AccumulateEnergyCounter sets ENERGY_COUNTER from the reading of a source,
+// which may be reset to zero (see CounterAccumulator).
+func (c *PowerMeterChannel) AccumulateEnergyCounter(reading float64) {
+	c.accumulator.Max, _ = c.energyCounter.description.Max.(float64)
+	c.SetEnergyCounter(c.accumulator.Update(reading))
+}
+
+// EnergyAccumulator returns the accumulator used by AccumulateEnergyCounter
+// (e.g. to persist and restore the offset).
+func (c *PowerMeterChannel) EnergyAccumulator() *CounterAccumulator {
+	return &c.accumulator
+}
+
+// PulseBoot signals a restart of the energy counter to the CCU by setting BOOT
+// to true and then to false, like a real device does after a restart. It
+// should be called on start up of the process (after the device is added to
+// the container) and after a reset of the energy counter. The total energy
+// meter of the CCU then continues the total correctly.
+func (c *PowerMeterChannel) PulseBoot() {
+	pulseBoot(c.boot)
+}
+
 func (c *PowerMeterChannel) SetPower(value float64) {
 	c.power.InternalSetValue(value)
 }
@@ -701,6 +1200,7 @@ type EnergyCounterChannel struct {
 
 	energyCounter *FloatParameter
 	power         *FloatParameter
+	accumulator   CounterAccumulator
 }
 
 // NewEnergyCounterChannel creates a new HM energy meter channel and adds it to
@@ -754,6 +1254,19 @@ func (c *EnergyCounterChannel) EnergyCounter() float64 {
 	return c.energyCounter.Value().(float64)
 }
 
+// AccumulateEnergyCounter sets IEC_ENERGY_COUNTER from the reading of a
+// source, which may be reset to zero (see CounterAccumulator).
+func (c *EnergyCounterChannel) AccumulateEnergyCounter(reading float64) {
+	c.accumulator.Max, _ = c.energyCounter.description.Max.(float64)
+	c.SetEnergyCounter(c.accumulator.Update(reading))
+}
+
+// EnergyAccumulator returns the accumulator used by AccumulateEnergyCounter
+// (e.g. to persist and restore the offset).
+func (c *EnergyCounterChannel) EnergyAccumulator() *CounterAccumulator {
+	return &c.accumulator
+}
+
 func (c *EnergyCounterChannel) SetPower(value float64) {
 	c.power.InternalSetValue(value)
 }
@@ -774,6 +1287,7 @@ type GasCounterChannel struct {
 
 	energyCounter *FloatParameter
 	power         *FloatParameter
+	boot          *BoolParameter
 }
 
 // NewGasCounterChannel creates a new HM gas meter channel and adds it to the
@@ -875,17 +1389,17 @@ func NewGasCounterChannel(device *Device) *GasCounterChannel {
 	fakeIECPower.Description().Operations = itf.ParameterOperationRead | itf.ParameterOperationEvent
 	c.AddValueParam(fakeIECPower)
 
-	// add BOOT parameter with the fixed value false
-	fakeBoot := NewBoolParameter("BOOT")
-	fakeBoot.Description().Control = "POWERMETER_IEC1.BOOT"
-	fakeBoot.Description().TabOrder = 6
+	// add BOOT parameter with the initial value false (see PulseBoot)
+	c.boot = NewBoolParameter("BOOT")
+	c.boot.Description().Control = "POWERMETER_IEC1.BOOT"
+	c.boot.Description().TabOrder = 6
 	// not writeable
-	fakeBoot.Description().Operations = itf.ParameterOperationRead | itf.ParameterOperationEvent
+	c.boot.Description().Operations = itf.ParameterOperationRead | itf.ParameterOperationEvent
 	// internal
-	fakeBoot.Description().Flags = itf.ParameterFlagVisible | itf.ParameterFlagInternal
-	// fixed value false
-	fakeBoot.value = false
-	c.AddValueParam(fakeBoot)
+	c.boot.Description().Flags = itf.ParameterFlagVisible | itf.ParameterFlagInternal
+	// initial value false
+	c.boot.value = false
+	c.AddValueParam(c.boot)
 
 	return c
 }
@@ -898,6 +1412,21 @@ func (c *GasCounterChannel) EnergyCounter() float64 {
 	return c.energyCounter.Value().(float64)
 }
 
+// PulseBoot signals a restart of the gas counter to the CCU by setting BOOT to
+// true and then to false, like a real device does after a restart. It should
+// be called on start up of the process (after the device is added to the
+// container) and after a reset of the gas counter.
+func (c *GasCounterChannel) PulseBoot() {
+	pulseBoot(c.boot)
+}
+
+// pulseBoot sets the BOOT parameter to true and then to false. Both changes
+// are published.
+func pulseBoot(boot *BoolParameter) {
+	boot.InternalSetValue(true)
+	boot.InternalSetValue(false)
+}
+
 func (c *GasCounterChannel) SetPower(value float64) {
 	c.power.InternalSetValue(value)
 }
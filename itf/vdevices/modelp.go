@@ -2,15 +2,70 @@ package vdevices
 
 import (
 	"fmt"
+	"math"
 
 	"github.com/mdzio/go-hmccu/itf"
 )
 
+// Transform converts a value between the representations of the CCU and the
+// application (e.g. 0..1 and 0..100 for the level of a dimmer).
+type Transform func(in interface{}) (out interface{}, err error)
+
 // Parameter implements GenericParameter.
 type Parameter struct {
 	description *itf.ParameterDescription
 	parentDescr *itf.DeviceDescription
 	publisher   EventPublisher
+
+	transformToApp Transform
+	transformToCCU Transform
+
+	// If PublishOnChangeOnly is set, InternalSetValue publishes a value only,
+	// if it differs from the current value. Values of ACTION parameters are
+	// always published.
+	PublishOnChangeOnly bool
+
+	// OnChange is called (optional) after the value has been set successfully
+	// by the CCU (SetValue) or by the application (InternalSetValue). The
+	// associated channel is locked while the function is executed.
+	OnChange func(value interface{}, source ChangeSource)
+
+	listeners []func()
+}
+
+// ChangeSource specifies the origin of a value change.
+type ChangeSource int
+
+const (
+	// ExternalChange is a value change by the CCU (SetValue).
+	ExternalChange ChangeSource = iota
+	// InternalChange is a value change by the application (InternalSetValue).
+	InternalChange
+)
+
+// String returns the name of the change source.
+func (s ChangeSource) String() string {
+	if s == ExternalChange {
+		return "external"
+	}
+	return "internal"
+}
+
+// AddListener registers a function, which is called after the value has been
+// set by SetValue or InternalSetValue. The associated channel is locked while
+// the function is executed.
+func (p *Parameter) AddListener(listener func()) {
+	p.listeners = append(p.listeners, listener)
+}
+
+// notify calls OnChange and the registered listeners.
+func (p *Parameter) notify(value interface{}, source ChangeSource) {
+	if p.OnChange != nil {
+		p.OnChange(value, source)
+	}
+	for _, l := range p.listeners {
+		l()
+	}
 }
 
 // SetParentDescr implements interface GenericParameter.
@@ -28,9 +83,59 @@ func (p *Parameter) Description() *itf.ParameterDescription {
 	return p.description
 }
 
+// SetTransform sets the value transformations of the parameter. toApp is
+// applied to values set by the CCU, before they are checked and stored. toCCU is
+// applied to values published to or read by the CCU. Both are optional. The
+// flag ParameterFlagTransform is set in the parameter description.
+func (p *Parameter) SetTransform(toApp, toCCU Transform) {
+	p.transformToApp = toApp
+	p.transformToCCU = toCCU
+	p.description.Flags |= itf.ParameterFlagTransform
+}
+
+func (p *Parameter) toApp(value interface{}) (interface{}, error) {
+	if p.transformToApp == nil {
+		return value, nil
+	}
+	out, err := p.transformToApp(value)
+	if err != nil {
+		return nil, fmt.Errorf("Transformation of value for parameter %s.%s failed: %v", p.parentDescr.Address, p.description.ID, err)
+	}
+	return out, nil
+}
+
+func (p *Parameter) toCCU(value interface{}) (interface{}, error) {
+	if p.transformToCCU == nil {
+		return value, nil
+	}
+	out, err := p.transformToCCU(value)
+	if err != nil {
+		return nil, fmt.Errorf("Transformation of value for parameter %s.%s failed: %v", p.parentDescr.Address, p.description.ID, err)
+	}
+	return out, nil
+}
+
+// mustPublish returns true, if a value set by InternalSetValue must be
+// published.
+func (p *Parameter) mustPublish(changed bool) bool {
+	if p.description.Operations&itf.ParameterOperationEvent == 0 {
+		return false
+	}
+	return changed || !p.PublishOnChangeOnly || p.description.Type == itf.ParameterTypeAction
+}
+
 func (p *Parameter) publishValue(value interface{}) {
 	// updates of master params are not published
 	if pub := p.publisher; pub != nil {
+		value, err := p.toCCU(value)
+		if err != nil {
+			log.Error(err)
+			return
+		}
+		if ap, ok := pub.(actionPublisher); ok && p.description.Type == itf.ParameterTypeAction {
+			ap.PublishActionEvent(p.parentDescr.Address, p.description.ID, value)
+			return
+		}
 		pub.PublishEvent(p.parentDescr.Address, p.description.ID, value)
 	}
 }
@@ -77,6 +182,10 @@ func (p *BoolParameter) SetValue(value interface{}) error {
 	if p.description.Operations&itf.ParameterOperationWrite == 0 {
 		return fmt.Errorf("Parameter not writeable: %s.%s", p.parentDescr.Address, p.description.ID)
 	}
+	value, err := p.toApp(value)
+	if err != nil {
+		return err
+	}
 	bvalue, ok := value.(bool)
 	if !ok {
 		return fmt.Errorf("Invalid data type for parameter %s.%s: %T", p.parentDescr.Address, p.description.ID, value)
@@ -84,6 +193,7 @@ func (p *BoolParameter) SetValue(value interface{}) error {
 	if p.OnSetValue == nil || p.OnSetValue(bvalue) {
 		p.publishValue(bvalue)
 		p.value = bvalue
+		p.notify(bvalue, ExternalChange)
 	}
 	return nil
 }
@@ -95,10 +205,11 @@ func (p *BoolParameter) InternalSetValue(value interface{}) error {
 	if !ok {
 		return fmt.Errorf("Invalid data type for parameter %s.%s: %T", p.parentDescr.Address, p.description.ID, value)
 	}
-	if p.description.Operations&itf.ParameterOperationEvent != 0 {
+	if p.mustPublish(bvalue != p.value) {
 		p.publishValue(bvalue)
 	}
 	p.value = bvalue
+	p.notify(bvalue, InternalChange)
 	return nil
 }
 
@@ -178,6 +289,10 @@ func (p *IntParameter) SetValue(value interface{}) error {
 	if p.description.Operations&itf.ParameterOperationWrite == 0 {
 		return fmt.Errorf("Parameter not writeable: %s.%s", p.parentDescr.Address, p.description.ID)
 	}
+	value, err := p.toApp(value)
+	if err != nil {
+		return err
+	}
 	ivalue, err := p.toInt(value)
 	if err != nil {
 		return err
@@ -187,6 +302,7 @@ func (p *IntParameter) SetValue(value interface{}) error {
 			p.publishValue(ivalue)
 		}
 		p.value = ivalue
+		p.notify(ivalue, ExternalChange)
 	}
 	return nil
 }
@@ -198,10 +314,11 @@ func (p *IntParameter) InternalSetValue(value interface{}) error {
 	if err != nil {
 		return err
 	}
-	if p.description.Operations&itf.ParameterOperationEvent != 0 {
+	if p.mustPublish(ivalue != p.value) {
 		p.publishValue(ivalue)
 	}
 	p.value = ivalue
+	p.notify(ivalue, InternalChange)
 	return nil
 }
 
@@ -220,7 +337,13 @@ type FloatParameter struct {
 	// device/channel is locked.
 	OnSetValue func(value float64) (ok bool)
 
-	value float64
+	// Epsilon is the max. difference between two values, which are considered
+	// equal by PublishOnChangeOnly. A value is compared with the last published
+	// value, so that slow drifts are published nevertheless.
+	Epsilon float64
+
+	value     float64
+	published float64
 }
 
 // check interface implementation
@@ -252,13 +375,19 @@ func (p *FloatParameter) SetValue(value interface{}) error {
 	if p.description.Operations&itf.ParameterOperationWrite == 0 {
 		return fmt.Errorf("Parameter not writeable: %s.%s", p.parentDescr.Address, p.description.ID)
 	}
+	value, err := p.toApp(value)
+	if err != nil {
+		return err
+	}
 	fvalue, ok := value.(float64)
 	if !ok {
 		return fmt.Errorf("Invalid data type for parameter %s.%s: %T", p.parentDescr.Address, p.description.ID, value)
 	}
 	if p.OnSetValue == nil || p.OnSetValue(fvalue) {
 		p.publishValue(fvalue)
+		p.published = fvalue
 		p.value = fvalue
+		p.notify(fvalue, ExternalChange)
 	}
 	return nil
 }
@@ -270,10 +399,12 @@ func (p *FloatParameter) InternalSetValue(value interface{}) error {
 	if !ok {
 		return fmt.Errorf("Invalid data type for parameter %s.%s: %T", p.parentDescr.Address, p.description.ID, value)
 	}
-	if p.description.Operations&itf.ParameterOperationEvent != 0 {
+	if p.mustPublish(math.Abs(fvalue-p.published) > p.Epsilon) {
 		p.publishValue(fvalue)
+		p.published = fvalue
 	}
 	p.value = fvalue
+	p.notify(fvalue, InternalChange)
 	return nil
 }
 
@@ -324,6 +455,10 @@ func (p *StringParameter) SetValue(value interface{}) error {
 	if p.description.Operations&itf.ParameterOperationWrite == 0 {
 		return fmt.Errorf("Parameter not writeable: %s.%s", p.parentDescr.Address, p.description.ID)
 	}
+	value, err := p.toApp(value)
+	if err != nil {
+		return err
+	}
 	svalue, ok := value.(string)
 	if !ok {
 		return fmt.Errorf("Invalid data type for parameter %s.%s: %T", p.parentDescr.Address, p.description.ID, value)
@@ -331,6 +466,7 @@ func (p *StringParameter) SetValue(value interface{}) error {
 	if p.OnSetValue == nil || p.OnSetValue(svalue) {
 		p.publishValue(svalue)
 		p.value = svalue
+		p.notify(svalue, ExternalChange)
 	}
 	return nil
 }
@@ -342,10 +478,11 @@ func (p *StringParameter) InternalSetValue(value interface{}) error {
 	if !ok {
 		return fmt.Errorf("Invalid data type for parameter %s.%s: %T", p.parentDescr.Address, p.description.ID, value)
 	}
-	if p.description.Operations&itf.ParameterOperationEvent != 0 {
+	if p.mustPublish(svalue != p.value) {
 		p.publishValue(svalue)
 	}
 	p.value = svalue
+	p.notify(svalue, InternalChange)
 	return nil
 }
 
@@ -354,3 +491,116 @@ func (p *StringParameter) InternalSetValue(value interface{}) error {
 func (p *StringParameter) Value() interface{} {
 	return p.value
 }
+
+// Observable is implemented by all parameters of this package (by embedding
+// Parameter). It is used to register the dependencies of a ComputedParameter.
+type Observable interface {
+	AddListener(listener func())
+}
+
+// ComputedParameter is a read-only FLOAT parameter, whose value is computed
+// from other parameters (e.g. the apparent power from voltage and current).
+// The value is recomputed and published, whenever a dependency is set. The
+// dependencies must belong to the same channel as the ComputedParameter,
+// because only the channel of the changed dependency is locked.
+type ComputedParameter struct {
+	FloatParameter
+
+	compute func() float64
+}
+
+// check interface implementation
+var _ GenericParameter = (*ComputedParameter)(nil)
+
+// NewComputedParameter creates a ComputedParameter (Type: FLOAT). compute is
+// executed with the channel locked. Following fields in the parameters
+// description are initialized to standard values: Type, Operation (read and
+// event), Flags, Default, Min, Max, ID.
+func NewComputedParameter(id string, compute func() float64, dependencies ...Observable) *ComputedParameter {
+	p := &ComputedParameter{
+		FloatParameter: *NewFloatParameter(id),
+		compute:        compute,
+	}
+	p.description.Operations = itf.ParameterOperationRead | itf.ParameterOperationEvent
+	for _, d := range dependencies {
+		d.AddListener(p.Recompute)
+	}
+	p.value = compute()
+	p.published = p.value
+	return p
+}
+
+// Recompute computes and sets the value. It is called automatically, if a
+// dependency is set. The associated channel, which is also the channel of the
+// dependencies, must be locked.
+func (p *ComputedParameter) Recompute() {
+	p.InternalSetValue(p.compute())
+}
+
+// transformer is implemented by parameters with value transformations (e.g.
+// by embedding Parameter).
+type transformer interface {
+	toCCU(value interface{}) (interface{}, error)
+}
+
+// ccuValue returns the value of the parameter in the representation of the
+// CCU. The associated channel must be locked.
+func ccuValue(p GenericParameter) (interface{}, error) {
+	v := p.Value()
+	if t, ok := p.(transformer); ok {
+		return t.toCCU(v)
+	}
+	return v, nil
+}
+
+// typedCCUValue returns the value of the parameter in the representation of
+// the CCU. The value is converted to the Go type, which corresponds to the
+// declared type of the parameter (e.g. int for ENUM), so that the wire type
+// matches the parameter description. The associated channel must be locked.
+func typedCCUValue(p GenericParameter) (interface{}, error) {
+	v, err := ccuValue(p)
+	if err != nil {
+		return nil, err
+	}
+	return typedValue(p.Description(), v)
+}
+
+// typedValue converts a value in the representation of the CCU to the Go
+// type, which corresponds to the declared type of the parameter.
+func typedValue(d *itf.ParameterDescription, v interface{}) (interface{}, error) {
+	switch d.Type {
+	case itf.ParameterTypeFloat:
+		switch n := v.(type) {
+		case int:
+			return float64(n), nil
+		case int64:
+			return float64(n), nil
+		}
+	case itf.ParameterTypeInteger, itf.ParameterTypeEnum:
+		switch n := v.(type) {
+		case float64:
+			return int(math.Round(n)), nil
+		case int64:
+			return int(n), nil
+		case string:
+			if d.Type == itf.ParameterTypeEnum {
+				if idx, ok := d.EnumIndex(n); ok {
+					return idx, nil
+				}
+			}
+			return nil, fmt.Errorf("Invalid %s value for parameter %s: %s", d.Type, d.ID, n)
+		}
+	case itf.ParameterTypeBool, itf.ParameterTypeAction, itf.ParameterTypeAlarm:
+		switch n := v.(type) {
+		case int:
+			return n != 0, nil
+		case float64:
+			return n != 0, nil
+		}
+	case itf.ParameterTypeString:
+		if _, ok := v.(string); !ok {
+			return fmt.Sprint(v), nil
+		}
+	}
+	return v, nil
+}
@@ -0,0 +1,183 @@
+package vdevices
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// RateLimiter is an EventPublisher, which limits the rate of the events
+// forwarded to another EventPublisher (e.g. Handler) with a token bucket.
+// Excess events are held back. Only the newest held back event of a parameter
+// is forwarded, when the bucket is refilled. Older ones are dropped. Events of
+// ACTION parameters (e.g. PRESS_SHORT) are never rate limited. A RateLimiter
+// can be used as publisher of a single device (see NewDevice), so the events
+// of a chatty device do not flood the logic layers.
+type RateLimiter struct {
+	// If PerParameter is set, a separate token bucket is used for each
+	// parameter (address and value key). Otherwise all events share one token
+	// bucket. PerParameter must be set before the first event is published.
+	PerParameter bool
+
+	publisher EventPublisher
+	rate      float64
+	burst     float64
+
+	mtx       sync.Mutex
+	dropped   uint64
+	buckets   map[string]*tokenBucket // key: address + "." + valueKey or ""
+	pending   map[string]Event        // key: address + "." + valueKey
+	scheduled bool
+	now       func() time.Time
+	schedule  func(d time.Duration, f func())
+}
+
+// check interface implementation
+var _ EventPublisher = (*RateLimiter)(nil)
+var _ BatchEventPublisher = (*RateLimiter)(nil)
+var _ actionPublisher = (*RateLimiter)(nil)
+
+// tokenBucket holds the state of a token bucket.
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// NewRateLimiter creates a RateLimiter, which forwards on average rate events
+// per second to publisher. Up to burst events are forwarded at once.
+func NewRateLimiter(publisher EventPublisher, rate float64, burst int) *RateLimiter {
+	if burst < 1 {
+		burst = 1
+	}
+	return &RateLimiter{
+		publisher: publisher,
+		rate:      rate,
+		burst:     float64(burst),
+		buckets:   make(map[string]*tokenBucket),
+		pending:   make(map[string]Event),
+		now:       time.Now,
+		schedule:  func(d time.Duration, f func()) { time.AfterFunc(d, f) },
+	}
+}
+
+// Dropped returns the number of dropped events. Events, which are held back
+// and forwarded later, are not counted.
+func (r *RateLimiter) Dropped() uint64 {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	return r.dropped
+}
+
+// PublishEvent implements EventPublisher.
+func (r *RateLimiter) PublishEvent(address, valueKey string, value interface{}) {
+	if !r.allow(Event{Address: address, ValueKey: valueKey, Value: value}) {
+		return
+	}
+	r.publisher.PublishEvent(address, valueKey, value)
+}
+
+// PublishActionEvent forwards an event of an ACTION parameter without rate
+// limiting.
+func (r *RateLimiter) PublishActionEvent(address, valueKey string, value interface{}) {
+	publishActionEvent(r.publisher, address, valueKey, value)
+}
+
+// PublishEvents implements BatchEventPublisher. The events are checked
+// individually.
+func (r *RateLimiter) PublishEvents(events []Event) {
+	passed := make([]Event, 0, len(events))
+	for _, e := range events {
+		if r.allow(e) {
+			passed = append(passed, e)
+		}
+	}
+	r.forward(passed)
+}
+
+// forward forwards events to the underlying publisher. If it does not
+// implement BatchEventPublisher, the events are forwarded one by one.
+func (r *RateLimiter) forward(events []Event) {
+	if len(events) == 0 {
+		return
+	}
+	publishEvents(r.publisher, events)
+}
+
+// bucket returns the refilled token bucket for a parameter. The mutex must be
+// locked.
+func (r *RateLimiter) bucket(paramKey string, now time.Time) *tokenBucket {
+	var key string
+	if r.PerParameter {
+		key = paramKey
+	}
+	b, ok := r.buckets[key]
+	if !ok {
+		b = &tokenBucket{tokens: r.burst, last: now}
+		r.buckets[key] = b
+	}
+	b.tokens += now.Sub(b.last).Seconds() * r.rate
+	if b.tokens > r.burst {
+		b.tokens = r.burst
+	}
+	b.last = now
+	return b
+}
+
+// allow takes a token from the responsible bucket. If no token is available,
+// the event is held back and false is returned. A held back event of the same
+// parameter is dropped.
+func (r *RateLimiter) allow(e Event) bool {
+	paramKey := e.Address + "." + e.ValueKey
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	b := r.bucket(paramKey, r.now())
+	// an older held back value is superseded
+	if _, ok := r.pending[paramKey]; ok {
+		delete(r.pending, paramKey)
+		r.dropped++
+	}
+	if b.tokens >= 1 {
+		b.tokens--
+		return true
+	}
+	log.Tracef("Rate limit exceeded, holding back event: %s, %s, %v", e.Address, e.ValueKey, e.Value)
+	r.pending[paramKey] = e
+	r.scheduleFlush(b)
+	return false
+}
+
+// scheduleFlush schedules the forwarding of the held back events, when the
+// bucket has a token again. The mutex must be locked.
+func (r *RateLimiter) scheduleFlush(b *tokenBucket) {
+	if r.scheduled || r.rate <= 0 {
+		return
+	}
+	r.scheduled = true
+	wait := time.Duration((1 - b.tokens) / r.rate * float64(time.Second))
+	r.schedule(wait, r.flush)
+}
+
+// flush forwards the held back events, for which tokens are available.
+func (r *RateLimiter) flush() {
+	r.mtx.Lock()
+	r.scheduled = false
+	now := r.now()
+	keys := make([]string, 0, len(r.pending))
+	for k := range r.pending {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var events []Event
+	for _, k := range keys {
+		b := r.bucket(k, now)
+		if b.tokens < 1 {
+			r.scheduleFlush(b)
+			continue
+		}
+		b.tokens--
+		events = append(events, r.pending[k])
+		delete(r.pending, k)
+	}
+	r.mtx.Unlock()
+	r.forward(events)
+}
@@ -0,0 +1,114 @@
+package vdevices
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+// newTestRateLimiter creates a RateLimiter with a fake clock. The scheduled
+// flush is returned by the function flushes.
+func newTestRateLimiter(pub EventPublisher, rate float64, burst int) (r *RateLimiter, now *time.Time, flushes func() []func()) {
+	r = NewRateLimiter(pub, rate, burst)
+	t := time.Unix(1000, 0)
+	now = &t
+	r.now = func() time.Time { return *now }
+	var fs []func()
+	r.schedule = func(d time.Duration, f func()) { fs = append(fs, f) }
+	flushes = func() []func() {
+		res := fs
+		fs = nil
+		return res
+	}
+	return
+}
+
+func TestRateLimiter(t *testing.T) {
+	pub := &testPublisher{}
+	r, now, flushes := newTestRateLimiter(pub, 2, 3)
+
+	// burst, the newest excess event is held back
+	for i := 0; i < 5; i++ {
+		r.PublishEvent("ABC0000001:1", "POWER", i)
+	}
+	if !reflect.DeepEqual(pub.events, []interface{}{0, 1, 2}) || r.Dropped() != 1 {
+		t.Fatal(pub.events, r.Dropped())
+	}
+
+	// held back event is delivered after refill
+	fs := flushes()
+	if len(fs) != 1 {
+		t.Fatal(len(fs))
+	}
+	*now = now.Add(500 * time.Millisecond)
+	fs[0]()
+	if !reflect.DeepEqual(pub.events, []interface{}{0, 1, 2, 4}) || r.Dropped() != 1 {
+		t.Fatal(pub.events, r.Dropped())
+	}
+
+	// refill with 2 events/s
+	*now = now.Add(time.Second)
+	for i := 5; i < 8; i++ {
+		r.PublishEvent("ABC0000001:1", "POWER", i)
+	}
+	if !reflect.DeepEqual(pub.events, []interface{}{0, 1, 2, 4, 5, 6}) {
+		t.Fatal(pub.events)
+	}
+
+	// shared bucket
+	r.PublishEvents([]Event{{"ABC0000001:1", "ENERGY", 8}})
+	if len(pub.events) != 6 {
+		t.Fatal(pub.events)
+	}
+
+	// too early
+	fs = flushes()
+	if len(fs) != 1 {
+		t.Fatal(len(fs))
+	}
+	*now = now.Add(100 * time.Millisecond)
+	fs[0]()
+	if len(pub.events) != 6 {
+		t.Fatal(pub.events)
+	}
+	fs = flushes()
+	if len(fs) != 1 {
+		t.Fatal(len(fs))
+	}
+	*now = now.Add(time.Second)
+	fs[0]()
+	if !reflect.DeepEqual(pub.events, []interface{}{0, 1, 2, 4, 5, 6, 8, 7}) || r.Dropped() != 1 {
+		t.Fatal(pub.events, r.Dropped())
+	}
+}
+
+func TestRateLimiterPerParameter(t *testing.T) {
+	pub := &testPublisher{}
+	r, _, _ := newTestRateLimiter(pub, 1, 1)
+	r.PerParameter = true
+
+	r.PublishEvent("ABC0000001:1", "POWER", 1)
+	r.PublishEvent("ABC0000001:1", "POWER", 2)
+	r.PublishEvent("ABC0000001:1", "ENERGY", 3)
+	if !reflect.DeepEqual(pub.events, []interface{}{1, 3}) || r.Dropped() != 0 {
+		t.Fatal(pub.events, r.Dropped())
+	}
+}
+
+func TestRateLimiterAction(t *testing.T) {
+	pub := &testPublisher{}
+	r, _, _ := newTestRateLimiter(pub, 1, 1)
+	dev := NewDevice("ABC0000001", "HmIP-BRC2", r)
+	NewMaintenanceChannel(dev)
+	kch := NewKeyChannel(dev)
+	pub.events = nil
+
+	kch.Lock()
+	defer kch.Unlock()
+	for i := 0; i < 3; i++ {
+		kch.PressShort()
+	}
+	if !reflect.DeepEqual(pub.events, []interface{}{true, true, true}) || r.Dropped() != 0 {
+		t.Fatal(pub.events, r.Dropped())
+	}
+}
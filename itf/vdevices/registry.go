@@ -0,0 +1,76 @@
+package vdevices
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// ChannelFactory creates a channel and adds it to the device.
+type ChannelFactory func(device *Device) GenericChannel
+
+// ChannelFactoryRegistry maps channel types (e.g. SWITCH) to ChannelFactory's.
+// It enables the data-driven creation of virtual devices, e.g. from a
+// configuration file or a captured real device. A ChannelFactoryRegistry is
+// safe for concurrent use.
+type ChannelFactoryRegistry struct {
+	mtx       sync.RWMutex
+	factories map[string]ChannelFactory
+}
+
+// DefaultChannelFactories contains the factories of the built-in channels.
+var DefaultChannelFactories = NewChannelFactoryRegistry()
+
+// NewChannelFactoryRegistry creates a ChannelFactoryRegistry, which is
+// prepopulated with the built-in channels. POWERMETER_IEC1 creates an
+// EnergyCounterChannel (the GasCounterChannel uses the same type).
+func NewChannelFactoryRegistry() *ChannelFactoryRegistry {
+	r := &ChannelFactoryRegistry{factories: make(map[string]ChannelFactory)}
+	r.Register("MAINTENANCE", func(d *Device) GenericChannel { return NewMaintenanceChannel(d) })
+	r.Register("SWITCH", func(d *Device) GenericChannel { return NewSwitchChannel(d) })
+	r.Register("SHUTTER_CONTACT", func(d *Device) GenericChannel { return NewDoorSensorChannel(d) })
+	r.Register("KEY_TRANSCEIVER", func(d *Device) GenericChannel { return NewKeyChannel(d) })
+	r.Register("ANALOG_INPUT_TRANSMITTER", func(d *Device) GenericChannel { return NewAnalogInputChannel(d) })
+	r.Register("DIMMER", func(d *Device) GenericChannel { return NewDimmerChannel(d) })
+	r.Register("CLIMATE_TRANSCEIVER", func(d *Device) GenericChannel { return NewTemperatureChannel(d) })
+	r.Register("POWERMETER", func(d *Device) GenericChannel { return NewPowerMeterChannel(d) })
+	r.Register("POWERMETER_IEC1", func(d *Device) GenericChannel { return NewEnergyCounterChannel(d) })
+	return r
+}
+
+// Register adds or replaces the factory for a channel type.
+func (r *ChannelFactoryRegistry) Register(channelType string, factory ChannelFactory) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	r.factories[channelType] = factory
+}
+
+// Factory returns the factory for a channel type. False is returned, if the
+// channel type is not registered.
+func (r *ChannelFactoryRegistry) Factory(channelType string) (ChannelFactory, bool) {
+	r.mtx.RLock()
+	defer r.mtx.RUnlock()
+	f, ok := r.factories[channelType]
+	return f, ok
+}
+
+// Types returns the registered channel types in sorted order.
+func (r *ChannelFactoryRegistry) Types() []string {
+	r.mtx.RLock()
+	defer r.mtx.RUnlock()
+	ts := make([]string, 0, len(r.factories))
+	for t := range r.factories {
+		ts = append(ts, t)
+	}
+	sort.Strings(ts)
+	return ts
+}
+
+// Create creates a channel of the specified type and adds it to the device.
+func (r *ChannelFactoryRegistry) Create(device *Device, channelType string) (GenericChannel, error) {
+	f, ok := r.Factory(channelType)
+	if !ok {
+		return nil, fmt.Errorf("Unsupported channel type: %s", channelType)
+	}
+	return f(device), nil
+}
@@ -0,0 +1,40 @@
+package vdevices
+
+import (
+	"testing"
+)
+
+func TestChannelFactoryRegistry(t *testing.T) {
+	r := NewChannelFactoryRegistry()
+	dev := NewDevice("JCK000", "HmIP-MIO16-PCB", &testPublisher{})
+	for _, typ := range []string{"MAINTENANCE", "SWITCH", "DIMMER"} {
+		ch, err := r.Create(dev, typ)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ch.Description().Type != typ {
+			t.Error(ch.Description().Type)
+		}
+	}
+	if len(dev.Channels()) != 3 || dev.Channels()[2].Description().Address != "JCK000:2" {
+		t.Error(dev.Channels())
+	}
+	if _, err := r.Create(dev, "BLIND"); err == nil || err.Error() != "Unsupported channel type: BLIND" {
+		t.Error(err)
+	}
+
+	// extension
+	r.Register("BLIND", func(d *Device) GenericChannel {
+		return NewDigitalChannel(d, "BLIND", "BLIND.STATE")
+	})
+	ch, err := r.Create(dev, "BLIND")
+	if err != nil || ch.Description().Type != "BLIND" {
+		t.Error(ch, err)
+	}
+	if _, ok := DefaultChannelFactories.Factory("BLIND"); ok {
+		t.Error("default registry modified")
+	}
+	if len(r.Types()) != 10 || r.Types()[0] != "ANALOG_INPUT_TRANSMITTER" {
+		t.Error(r.Types())
+	}
+}
@@ -1,7 +1,10 @@
 package vdevices
 
 import (
+	"errors"
+	"fmt"
 	"sort"
+	"sync"
 	"time"
 
 	"github.com/mdzio/go-hmccu/itf"
@@ -9,13 +12,20 @@ import (
 	"github.com/mdzio/go-lib/conc"
 )
 
+var errServantClosed = errors.New("Servant is closed")
+
 const (
 	servantQueueSize  = 200
 	servantRetryCount = 6
 	servantRetryDelay = 20 * time.Second
 )
 
-type servantSync struct{}
+type servantSync struct {
+	// optional, receives the result of the synchronization
+	done chan<- error
+}
+
+type servantPushValues struct{}
 
 type servantEvent struct {
 	address  string
@@ -23,26 +33,113 @@ type servantEvent struct {
 	value    interface{}
 }
 
+type servantEvents []servantEvent
+
 type servant struct {
+	rcvAddr     string // receiver address as registered by the logic layer
+	resolvedKey string // servant key with resolved host name, guarded by Handler.mtx
 	addr, itfID string
 	model       *Container
+	filter      func(interfaceID string, device GenericDevice) bool
+	typed       bool // see Handler.TypedValues
 	cmds        chan interface{}
 	cancel      func()
+
+	mtx         sync.Mutex // for lastSync, lastCmd, lastCmdTime, closed
+	lastSync    time.Time
+	lastCmd     string
+	lastCmdTime time.Time
+	closed      bool
 }
 
-func newServant(address, interfaceID string, model *Container) *servant {
+func newServant(receiverAddress, address, interfaceID string, model *Container, filter func(interfaceID string, device GenericDevice) bool, typed bool) *servant {
 	s := &servant{
-		addr:  address,
-		itfID: interfaceID,
-		model: model,
-		cmds:  make(chan interface{}, servantQueueSize),
+		rcvAddr: receiverAddress,
+		addr:    address,
+		typed:   typed,
+		itfID:   interfaceID,
+		model:   model,
+		filter:  filter,
+		cmds:    make(chan interface{}, servantQueueSize),
 	}
 	s.cancel = conc.DaemonFunc(s.run)
 	return s
 }
 
+// devices returns the devices, which are advertised to the logic layer.
+func (s *servant) devices() []GenericDevice {
+	if s.filter == nil {
+		return s.model.Devices()
+	}
+	return s.model.DevicesMatching(func(d GenericDevice) bool {
+		return s.filter(s.itfID, d)
+	})
+}
+
+// accepts returns true, if the device of the channel or device address is
+// advertised to the logic layer. Events of other devices must not be sent.
+func (s *servant) accepts(address string) bool {
+	if s.filter == nil {
+		return true
+	}
+	deviceAddr, _ := itf.SplitAddress(address)
+	d, err := s.model.Device(deviceAddr)
+	if err != nil {
+		return false
+	}
+	return s.filter(s.itfID, d)
+}
+
+// eventValue converts the value of an event to the Go type of the parameter,
+// if typed values are enabled. On failure the value is returned unmodified.
+func (s *servant) eventValue(address, valueKey string, value interface{}) interface{} {
+	if !s.typed {
+		return value
+	}
+	deviceAddr, channelAddr := itf.SplitAddress(address)
+	d, err := s.model.Device(deviceAddr)
+	if err != nil {
+		return value
+	}
+	ch, err := d.Channel(channelAddr)
+	if err != nil {
+		return value
+	}
+	p, err := ch.ValueParamset().Parameter(valueKey)
+	if err != nil {
+		return value
+	}
+	tv, err := typedValue(p.Description(), value)
+	if err != nil {
+		log.Warning(err)
+		return value
+	}
+	return tv
+}
+
+// started records the command, whose processing is started, for the
+// statistics.
+func (s *servant) started(cmd interface{}) {
+	var name string
+	switch cmd.(type) {
+	case servantSync:
+		name = "sync"
+	case servantPushValues:
+		name = "push values"
+	case servantEvent:
+		name = "event"
+	case servantEvents:
+		name = "events"
+	}
+	s.mtx.Lock()
+	s.lastCmd = name
+	s.lastCmdTime = time.Now()
+	s.mtx.Unlock()
+}
+
 func (s *servant) run(ctx conc.Context) {
 	log.Debugf("Starting servant for %s, interface ID %s", s.addr, s.itfID)
+	defer s.drain()
 	// use a retrying caller
 	cln := &itf.LogicLayerClient{
 		Name: s.addr,
@@ -56,65 +153,64 @@ func (s *servant) run(ctx conc.Context) {
 	for {
 		select {
 		case cmd := <-s.cmds:
+			s.started(cmd)
 			switch c := cmd.(type) {
 			case servantSync:
-				// get device list of logic layer
-				lds, err := cln.ListDevices(s.itfID)
-				if err != nil {
-					log.Errorf("List devices failed on %s, interface ID %s: %v", s.addr, s.itfID, err)
-					continue
+				err := s.sync(ctx, cln)
+				if c.done != nil {
+					c.done <- err
 				}
 				if ctx.IsDone() {
 					return
 				}
-				// build look up map
-				lset := make(map[string]bool)
-				for _, ld := range lds {
-					lset[ld.Address] = true
-				}
 
-				// get device list of device layer
-				var dds []*itf.DeviceDescription
-				dset := make(map[string]bool)
-				for _, dd := range s.model.Devices() {
-					dds = append(dds, dd.Description())
-					dset[dd.Description().Address] = true
+			case servantPushValues:
+				// send current values to logic layer
+				for _, dd := range s.devices() {
 					for _, dch := range dd.Channels() {
-						dds = append(dds, dch.Description())
-						dset[dch.Description().Address] = true
+						for _, e := range readEventValues(dch, s.typed) {
+							err := cln.Event(s.itfID, e.address, e.valueKey, e.value)
+							if err != nil {
+								log.Errorf("Event failed on %s, interface ID %s: %v", s.addr, s.itfID, err)
+							}
+							if ctx.IsDone() {
+								return
+							}
+						}
 					}
 				}
 
-				// delete devices that no longer exists in the device layer
-				var deldev []string
-				for _, d := range lds {
-					if !dset[d.Address] {
-						deldev = append(deldev, d.Address)
-					}
+			case servantEvent:
+				// send event to logic layer
+				if !s.accepts(c.address) {
+					continue
 				}
-				if len(deldev) > 0 {
-					// delete channels first
-					sort.Sort(sort.Reverse(sort.StringSlice(deldev)))
-					cln.DeleteDevices(s.itfID, deldev)
-					if ctx.IsDone() {
-						return
-					}
+				err := cln.Event(s.itfID, c.address, c.valueKey, s.eventValue(c.address, c.valueKey, c.value))
+				if err != nil {
+					log.Errorf("Event failed on %s, interface ID %s: %v", s.addr, s.itfID, err)
 				}
 
-				// create devices that are missing in the logic layer
-				var newdev []*itf.DeviceDescription
-				for _, d := range dds {
-					if !lset[d.Address] {
-						newdev = append(newdev, d)
+			case servantEvents:
+				// send events to logic layer with a single system.multicall
+				var evs []itf.Event
+				for _, e := range c {
+					if !s.accepts(e.address) {
+						continue
 					}
+					evs = append(evs, itf.Event{
+						Address:  e.address,
+						ValueKey: e.valueKey,
+						Value:    s.eventValue(e.address, e.valueKey, e.value),
+					})
 				}
-				if len(newdev) > 0 {
-					cln.NewDevices(s.itfID, newdev)
+				var err error
+				switch len(evs) {
+				case 0:
+				case 1:
+					err = cln.Event(s.itfID, evs[0].Address, evs[0].ValueKey, evs[0].Value)
+				default:
+					err = cln.Events(s.itfID, evs)
 				}
-
-			case servantEvent:
-				// send event to logic layer
-				err := cln.Event(s.itfID, c.address, c.valueKey, c.value)
 				if err != nil {
 					log.Errorf("Event failed on %s, interface ID %s: %v", s.addr, s.itfID, err)
 				}
@@ -126,11 +222,158 @@ func (s *servant) run(ctx conc.Context) {
 	}
 }
 
-func (s *servant) command(cmd interface{}) {
+// sync updates the device list of the logic layer. The first error is
+// returned.
+func (s *servant) sync(ctx conc.Context, cln *itf.LogicLayerClient) error {
+	// get device list of logic layer
+	lds, err := cln.ListDevices(s.itfID)
+	if err != nil {
+		err = fmt.Errorf("List devices failed on %s, interface ID %s: %v", s.addr, s.itfID, err)
+		log.Error(err)
+		return err
+	}
+	if ctx.IsDone() {
+		return errServantClosed
+	}
+	// build look up map
+	lset := make(map[string]bool)
+	lver := make(map[string]int)
+	for _, ld := range lds {
+		lset[ld.Address] = true
+		lver[ld.Address] = ld.Version
+	}
+
+	// get device list of device layer
+	var dds []*itf.DeviceDescription
+	dset := make(map[string]bool)
+	for _, dd := range s.devices() {
+		dds = append(dds, dd.Description())
+		dset[dd.Description().Address] = true
+		for _, dch := range dd.Channels() {
+			dds = append(dds, dch.Description())
+			dset[dch.Description().Address] = true
+		}
+	}
+
+	// delete devices that no longer exists in the device layer
+	var first error
+	var deldev []string
+	for _, d := range lds {
+		if !dset[d.Address] {
+			deldev = append(deldev, d.Address)
+		}
+	}
+	if len(deldev) > 0 {
+		// delete channels first
+		sort.Sort(sort.Reverse(sort.StringSlice(deldev)))
+		if err := cln.DeleteDevices(s.itfID, deldev); err != nil {
+			first = fmt.Errorf("Delete devices failed on %s, interface ID %s: %v", s.addr, s.itfID, err)
+			log.Error(first)
+		}
+		if ctx.IsDone() {
+			return errServantClosed
+		}
+	}
+
+	// create devices that are missing in the logic layer, update
+	// devices with a different version (if known)
+	var newdev []*itf.DeviceDescription
+	for _, d := range dds {
+		if !lset[d.Address] || (lver[d.Address] != 0 && lver[d.Address] != d.Version) {
+			newdev = append(newdev, d)
+		}
+	}
+	if len(newdev) > 0 {
+		if err := cln.NewDevices(s.itfID, newdev); err != nil && first == nil {
+			first = fmt.Errorf("New devices failed on %s, interface ID %s: %v", s.addr, s.itfID, err)
+			log.Error(first)
+		}
+	}
+	if first != nil {
+		return first
+	}
+	s.mtx.Lock()
+	s.lastSync = time.Now()
+	s.mtx.Unlock()
+	return nil
+}
+
+// readEventValues reads the current values of all readable VALUES parameters
+// with events of a channel. If typed is set, the values are converted to the
+// Go types of the parameters.
+func readEventValues(ch GenericChannel, typed bool) []servantEvent {
+	conv := ccuValue
+	if typed {
+		conv = typedCCUValue
+	}
+	ch.Lock()
+	defer ch.Unlock()
+	var es []servantEvent
+	for _, p := range ch.ValueParamset().Parameters() {
+		ops := p.Description().Operations
+		if ops&itf.ParameterOperationRead != 0 && ops&itf.ParameterOperationEvent != 0 {
+			v, err := conv(p)
+			if err != nil {
+				log.Error(err)
+				continue
+			}
+			es = append(es, servantEvent{
+				address:  ch.Description().Address,
+				valueKey: p.Description().ID,
+				value:    v,
+			})
+		}
+	}
+	return es
+}
+
+func (s *servant) stat() ServantStat {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	return ServantStat{
+		ReceiverAddress: s.rcvAddr,
+		Address:         s.addr,
+		InterfaceID:     s.itfID,
+		QueueLength:     len(s.cmds),
+		LastSync:        s.lastSync,
+		LastCommand:     s.lastCmd,
+		LastCommandTime: s.lastCmdTime,
+	}
+}
+
+// command queues a command for the servant. An error is returned, if the
+// queue is full or the servant is closed.
+func (s *servant) command(cmd interface{}) error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	if s.closed {
+		return errServantClosed
+	}
 	select {
 	case s.cmds <- cmd:
+		return nil
 	default:
-		log.Errorf("Queue overflow for %s, interface ID %s", s.addr, s.itfID)
+		err := fmt.Errorf("Queue overflow for %s, interface ID %s", s.addr, s.itfID)
+		log.Error(err)
+		return err
+	}
+}
+
+// drain marks the servant as closed and answers all pending synchronization
+// requests.
+func (s *servant) drain() {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	s.closed = true
+	for {
+		select {
+		case cmd := <-s.cmds:
+			if c, ok := cmd.(servantSync); ok && c.done != nil {
+				c.done <- errServantClosed
+			}
+		default:
+			return
+		}
 	}
 }
 
@@ -0,0 +1,87 @@
+package vdevices
+
+import (
+	"net/http"
+	"sync"
+
+	"golang.org/x/net/websocket"
+)
+
+// size of the event queue of a WebSocket client
+const streamQueueSize = 100
+
+// EventStream is an EventPublisher, which streams the events as JSON messages
+// (see Event) to WebSocket clients. It implements http.Handler for the
+// WebSocket endpoint. If a client is too slow, events are dropped. An
+// EventStream can be combined with a Handler by a TeeEventPublisher.
+type EventStream struct {
+	mtx     sync.Mutex
+	clients map[chan Event]struct{}
+}
+
+// check interface implementation
+var _ EventPublisher = (*EventStream)(nil)
+
+// NewEventStream creates an EventStream.
+func NewEventStream() *EventStream {
+	return &EventStream{
+		clients: make(map[chan Event]struct{}),
+	}
+}
+
+// PublishEvent implements EventPublisher.
+func (s *EventStream) PublishEvent(address, valueKey string, value interface{}) {
+	e := Event{Address: address, ValueKey: valueKey, Value: value}
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	for c := range s.clients {
+		select {
+		case c <- e:
+		default:
+			log.Warningf("Queue overflow for WebSocket client, event dropped: %s, %s", address, valueKey)
+		}
+	}
+}
+
+// ServeHTTP implements http.Handler.
+func (s *EventStream) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	websocket.Handler(s.serve).ServeHTTP(w, r)
+}
+
+func (s *EventStream) serve(ws *websocket.Conn) {
+	log.Debugf("WebSocket client connected: %s", ws.Request().RemoteAddr)
+	c := make(chan Event, streamQueueSize)
+	s.mtx.Lock()
+	s.clients[c] = struct{}{}
+	s.mtx.Unlock()
+	defer func() {
+		s.mtx.Lock()
+		delete(s.clients, c)
+		s.mtx.Unlock()
+		log.Debugf("WebSocket client disconnected: %s", ws.Request().RemoteAddr)
+	}()
+
+	// detect closing of the connection, received messages are ignored
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		var msg string
+		for {
+			if err := websocket.Message.Receive(ws, &msg); err != nil {
+				return
+			}
+		}
+	}()
+
+	for {
+		select {
+		case e := <-c:
+			if err := websocket.JSON.Send(ws, e); err != nil {
+				log.Debugf("Sending to WebSocket client %s failed: %v", ws.Request().RemoteAddr, err)
+				return
+			}
+		case <-closed:
+			return
+		}
+	}
+}
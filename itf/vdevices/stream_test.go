@@ -0,0 +1,47 @@
+package vdevices
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/net/websocket"
+)
+
+func TestEventStream(t *testing.T) {
+	es := NewEventStream()
+	srv := httptest.NewServer(es)
+	defer srv.Close()
+
+	ws, err := websocket.Dial("ws"+strings.TrimPrefix(srv.URL, "http"), "", srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ws.Close()
+
+	// wait for registration of the client
+	for i := 0; i < 100; i++ {
+		es.mtx.Lock()
+		n := len(es.clients)
+		es.mtx.Unlock()
+		if n == 1 {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	tee := &TeeEventPublisher{First: &testPublisher{}, Second: es}
+	dev := NewDevice("JCK000", "HmIP-PSM", tee)
+	pm := NewPowerMeterChannel(dev)
+	pm.SetPower(12.5)
+
+	var e Event
+	ws.SetReadDeadline(time.Now().Add(3 * time.Second))
+	if err := websocket.JSON.Receive(ws, &e); err != nil {
+		t.Fatal(err)
+	}
+	if e.Address != "JCK000:0" || e.ValueKey != "POWER" || e.Value != 12.5 {
+		t.Error(e)
+	}
+}
@@ -9,8 +9,7 @@ import (
 
 	"github.com/mdzio/go-logging"
 
-	"golang.org/x/net/html/charset"
-	"golang.org/x/text/encoding/charmap"
+	"github.com/mdzio/go-hmccu/internal/enc"
 )
 
 // max. size of a valid response, if not specified: 10 MB
@@ -27,11 +26,71 @@ var clnLog = logging.Get("xmlrpc-client")
 type Client struct {
 	Addr              string
 	ResponseSizeLimit int64
+
+	// MaxDepth limits the nesting depth of arrays and structs in responses
+	// (default: DefaultMaxDepth).
+	MaxDepth int
 }
 
 // Call executes an remote procedure call. Call implements Caller.
 func (c *Client) Call(method string, params Values) (*Value, error) {
-	clnLog.Tracef("Calling method %s on %s", method, c.Addr)
+	respBuf, err := c.exchange(method, params)
+	if err != nil {
+		return nil, err
+	}
+
+	// decode response from xml
+	resp := &MethodResponse{}
+	err = decodeXML(respBuf, resp, c.MaxDepth)
+	if err != nil {
+		return nil, fmt.Errorf("Decoding of response from %s failed: %v", Redact(c.Addr), err)
+	}
+
+	// check fault
+	if resp.Fault != nil {
+		return nil, faultError(resp.Fault)
+	}
+
+	// check response
+	if resp.Params == nil || len(resp.Params.Param) != 1 {
+		return nil, fmt.Errorf("Invalid or no parameters in response from %s", Redact(c.Addr))
+	}
+	return resp.Params.Param[0].Value, nil
+}
+
+// CallLazy executes an remote procedure call like Call, but the response is
+// not decoded. Selected values can be decoded later on from the returned
+// LazyResponse. This avoids building the whole value tree of large responses
+// (e.g. getParamset), if only a few struct members are needed.
+func (c *Client) CallLazy(method string, params Values) (*LazyResponse, error) {
+	respBuf, err := c.exchange(method, params)
+	if err != nil {
+		return nil, err
+	}
+	r := &LazyResponse{buf: respBuf, maxDepth: c.MaxDepth}
+	// check fault
+	if err := r.checkFault(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// faultError converts an XML-RPC fault value into an error.
+func faultError(fault *Value) error {
+	e := Q(fault)
+	faultCode := e.Key("faultCode").Int()
+	faultString := e.Key("faultString").String()
+	if e.Err() != nil {
+		return fmt.Errorf("Invalid XML-RPC fault response: %v", e.Err())
+	}
+	return &MethodError{faultCode, faultString}
+}
+
+// exchange sends the method call and returns the raw response.
+func (c *Client) exchange(method string, params Values) ([]byte, error) {
+	if clnLog.TraceEnabled() {
+		clnLog.Tracef("Calling method %s on %s", method, Redact(c.Addr))
+	}
 
 	// build XML object tree
 	ps := make([]*Param, len(params))
@@ -43,34 +102,34 @@ func (c *Client) Call(method string, params Values) (*Value, error) {
 		Params:     &Params{ps},
 	}
 
-	// use ISO8859-1 character encoding for request
-	var reqBuf bytes.Buffer
-	reqWriter := charmap.ISO8859_1.NewEncoder().Writer(&reqBuf)
-
 	// write xml header
-	reqWriter.Write([]byte("<?xml version=\"1.0\" encoding=\"ISO-8859-1\"?>\n"))
+	var xmlBuf bytes.Buffer
+	xmlBuf.WriteString("<?xml version=\"1.0\" encoding=\"ISO-8859-1\"?>\n")
 
 	// encode request to xml
-	enc := xml.NewEncoder(reqWriter)
-	err := enc.Encode(methodCall)
+	xmlEnc := xml.NewEncoder(&xmlBuf)
+	err := xmlEnc.Encode(methodCall)
 	if err != nil {
-		return nil, fmt.Errorf("Encoding of request for %s failed: %v", c.Addr, err)
+		return nil, fmt.Errorf("Encoding of request for %s failed: %v", Redact(c.Addr), err)
 	}
+
+	// use ISO8859-1 character encoding for request
+	reqBuf := bytes.NewBuffer(enc.ToISO8859_1(xmlBuf.Bytes()))
 	if clnLog.TraceEnabled() {
 		// attention: log message is ISO8859-1 encoded!
-		clnLog.Tracef("Request XML: %s", reqBuf.String())
+		clnLog.Tracef("Request XML: %s", Redact(reqBuf.String()))
 	}
 
 	// http post
 	httpResp, err := http.Post("http://"+c.Addr, "text/xml", bytes.NewReader(reqBuf.Bytes()))
 	if err != nil {
-		return nil, fmt.Errorf("HTTP request failed on %s: %v", c.Addr, err)
+		return nil, fmt.Errorf("HTTP request failed on %s: %v", Redact(c.Addr), err)
 	}
 	defer httpResp.Body.Close()
 
 	// check status
 	if httpResp.StatusCode < 200 || httpResp.StatusCode >= 299 {
-		return nil, fmt.Errorf("HTTP request failed on %s with code: %s", c.Addr, httpResp.Status)
+		return nil, fmt.Errorf("HTTP request failed on %s with code: %s", Redact(c.Addr), httpResp.Status)
 	}
 
 	// read response
@@ -81,37 +140,11 @@ func (c *Client) Call(method string, params Values) (*Value, error) {
 	limitReader := io.LimitReader(httpResp.Body, limit)
 	respBuf, err := io.ReadAll(limitReader)
 	if err != nil {
-		return nil, fmt.Errorf("Reading of response failed from %s: %v", c.Addr, err)
+		return nil, fmt.Errorf("Reading of response failed from %s: %v", Redact(c.Addr), err)
 	}
 	if clnLog.TraceEnabled() {
 		// attention: log message is probably ISO8859-1 encoded!
-		clnLog.Tracef("Response XML: %s", string(respBuf))
-	}
-
-	// decode response from xml
-	respReader := bytes.NewBuffer(respBuf)
-	resp := &MethodResponse{}
-	dec := xml.NewDecoder(respReader)
-	dec.CharsetReader = charset.NewReaderLabel
-	err = dec.Decode(resp)
-	if err != nil {
-		return nil, fmt.Errorf("Decoding of response from %s failed: %v", c.Addr, err)
-	}
-
-	// check fault
-	if resp.Fault != nil {
-		e := Q(resp.Fault)
-		faultCode := e.Key("faultCode").Int()
-		faultString := e.Key("faultString").String()
-		if e.Err() != nil {
-			return nil, fmt.Errorf("Invalid XML-RPC fault response: %v", e.Err())
-		}
-		return nil, &MethodError{faultCode, faultString}
-	}
-
-	// check response
-	if resp.Params == nil || len(resp.Params.Param) != 1 {
-		return nil, fmt.Errorf("Invalid or no parameters in response from %s", c.Addr)
+		clnLog.Tracef("Response XML: %s", Redact(string(respBuf)))
 	}
-	return resp.Params.Param[0].Value, nil
+	return respBuf, nil
 }
@@ -0,0 +1,158 @@
+package xmlrpc
+
+import (
+	"encoding/base64"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+// dateTimeLayout is the format of the XML-RPC type dateTime.iso8601.
+const dateTimeLayout = "20060102T15:04:05"
+
+var (
+	valuePtrType = reflect.TypeOf((*Value)(nil))
+	timeType     = reflect.TypeOf(time.Time{})
+)
+
+// NewValueDeep creates a value from arbitrary nested Go data by reflection.
+// Supported are booleans, integers, floats, strings, time.Time
+// (dateTime.iso8601), []byte (base64), *Value, slices, arrays, maps with
+// string keys and structs. Pointers and interfaces are dereferenced.
+//
+// The exported fields of a struct are converted to members. The member name
+// can be specified with the struct tag xmlrpc (e.g. `xmlrpc:"ADDRESS"`). The
+// option omitempty skips a field with a zero value, the name "-" skips the
+// field always. Fields of embedded structs are promoted. The members of maps
+// and structs are sorted by name.
+func NewValueDeep(in interface{}) (*Value, error) {
+	return newValueDeep(reflect.ValueOf(in))
+}
+
+func newValueDeep(rv reflect.Value) (*Value, error) {
+	if !rv.IsValid() {
+		return nil, fmt.Errorf("Conversion of nil is not supported")
+	}
+	if rv.Type() == valuePtrType {
+		if rv.IsNil() {
+			return nil, fmt.Errorf("Conversion of nil is not supported")
+		}
+		return rv.Interface().(*Value), nil
+	}
+	if rv.Type() == timeType {
+		return &Value{DateTime: rv.Interface().(time.Time).Format(dateTimeLayout)}, nil
+	}
+	switch rv.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if rv.IsNil() {
+			return nil, fmt.Errorf("Conversion of nil %s is not supported", rv.Type())
+		}
+		return newValueDeep(rv.Elem())
+	case reflect.Bool:
+		return NewBool(rv.Bool()), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return NewInt(int(rv.Int())), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return NewInt(int(rv.Uint())), nil
+	case reflect.Float32, reflect.Float64:
+		return NewFloat64(rv.Float()), nil
+	case reflect.String:
+		return NewString(rv.String()), nil
+	case reflect.Slice:
+		if rv.Type().Elem().Kind() == reflect.Uint8 {
+			return &Value{Base64: base64.StdEncoding.EncodeToString(rv.Bytes())}, nil
+		}
+		return newArrayDeep(rv)
+	case reflect.Array:
+		return newArrayDeep(rv)
+	case reflect.Map:
+		if rv.Type().Key().Kind() != reflect.String {
+			return nil, fmt.Errorf("Conversion of map with key type %s is not supported", rv.Type().Key())
+		}
+		ms := make([]*Member, 0, rv.Len())
+		iter := rv.MapRange()
+		for iter.Next() {
+			name := iter.Key().String()
+			cv, err := newValueDeep(iter.Value())
+			if err != nil {
+				return nil, fmt.Errorf("Member %s: %v", name, err)
+			}
+			ms = append(ms, &Member{Name: name, Value: cv})
+		}
+		sortMembers(ms)
+		return &Value{Struct: &Struct{Members: ms}}, nil
+	case reflect.Struct:
+		var ms []*Member
+		if err := appendFieldsDeep(&ms, rv); err != nil {
+			return nil, err
+		}
+		sortMembers(ms)
+		if ms == nil {
+			ms = []*Member{}
+		}
+		return &Value{Struct: &Struct{Members: ms}}, nil
+	default:
+		return nil, fmt.Errorf("Conversion of type %s is not supported", rv.Type())
+	}
+}
+
+func newArrayDeep(rv reflect.Value) (*Value, error) {
+	es := make([]*Value, rv.Len())
+	for i := range es {
+		cv, err := newValueDeep(rv.Index(i))
+		if err != nil {
+			return nil, fmt.Errorf("Element %d: %v", i, err)
+		}
+		es[i] = cv
+	}
+	return &Value{Array: &Array{es}}, nil
+}
+
+// appendFieldsDeep appends the exported fields of a struct as members.
+func appendFieldsDeep(ms *[]*Member, rv reflect.Value) error {
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		f := rt.Field(i)
+		tag := f.Tag.Get("xmlrpc")
+		// promote fields of embedded structs
+		if f.Anonymous && tag == "" && f.Type.Kind() == reflect.Struct {
+			if err := appendFieldsDeep(ms, rv.Field(i)); err != nil {
+				return err
+			}
+			continue
+		}
+		// unexported field?
+		if f.PkgPath != "" {
+			continue
+		}
+		name, opts := f.Name, ""
+		if tag != "" {
+			parts := strings.SplitN(tag, ",", 2)
+			if parts[0] != "" {
+				name = parts[0]
+			}
+			if len(parts) > 1 {
+				opts = parts[1]
+			}
+		}
+		if name == "-" {
+			continue
+		}
+		fv := rv.Field(i)
+		if opts == "omitempty" && fv.IsZero() {
+			continue
+		}
+		cv, err := newValueDeep(fv)
+		if err != nil {
+			return fmt.Errorf("Field %s: %v", f.Name, err)
+		}
+		*ms = append(*ms, &Member{Name: name, Value: cv})
+	}
+	return nil
+}
+
+func sortMembers(ms []*Member) {
+	sort.Slice(ms, func(i, j int) bool { return ms[i].Name < ms[j].Name })
+}
@@ -0,0 +1,76 @@
+package xmlrpc
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+type deepBase struct {
+	ID string `xmlrpc:"ID"`
+}
+
+type deepConfig struct {
+	deepBase
+	Name     string            `xmlrpc:"NAME"`
+	Level    float64           `xmlrpc:"LEVEL,omitempty"`
+	Channels []int             `xmlrpc:"CHANNELS"`
+	Params   map[string]string `xmlrpc:"PARAMS"`
+	Ignored  string            `xmlrpc:"-"`
+	Enabled  *bool
+	internal int
+}
+
+func TestNewValueDeep(t *testing.T) {
+	enabled := true
+	cfg := deepConfig{
+		deepBase: deepBase{ID: "abc"},
+		Name:     "dev",
+		Channels: []int{1, 2},
+		Params:   map[string]string{"B": "2", "A": "1"},
+		Ignored:  "x",
+		Enabled:  &enabled,
+		internal: 5,
+	}
+	v, err := NewValueDeep([]map[string]interface{}{{"CFG": cfg}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &Value{Array: &Array{[]*Value{
+		{Struct: &Struct{[]*Member{
+			{"CFG", &Value{Struct: &Struct{[]*Member{
+				{"CHANNELS", &Value{Array: &Array{[]*Value{NewInt(1), NewInt(2)}}}},
+				{"Enabled", NewBool(true)},
+				{"ID", NewString("abc")},
+				{"NAME", NewString("dev")},
+				{"PARAMS", &Value{Struct: &Struct{[]*Member{
+					{"A", NewString("1")},
+					{"B", NewString("2")},
+				}}}},
+			}}}},
+		}}},
+	}}}
+	if !reflect.DeepEqual(v, want) {
+		t.Errorf("unexpected value: %v", v)
+	}
+
+	tm := time.Date(2021, 3, 4, 5, 6, 7, 0, time.UTC)
+	v, err = NewValueDeep(map[string]interface{}{"T": tm, "B": []byte("hi"), "V": NewInt(3)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = &Value{Struct: &Struct{[]*Member{
+		{"B", &Value{Base64: "aGk="}},
+		{"T", &Value{DateTime: "20210304T05:06:07"}},
+		{"V", NewInt(3)},
+	}}}
+	if !reflect.DeepEqual(v, want) {
+		t.Errorf("unexpected value: %v", v)
+	}
+
+	for _, in := range []interface{}{nil, map[int]string{1: "a"}, make(chan int), []interface{}{(*int)(nil)}} {
+		if _, err := NewValueDeep(in); err == nil {
+			t.Errorf("expected error for %#v", in)
+		}
+	}
+}
@@ -85,6 +85,11 @@ func (d *BasicDispatcher) AddSystemMethods() {
 				if err != nil {
 					return nil, fmt.Errorf("Method %s in system.multicall failed: %v", methodName, err)
 				}
+				// nested arrays are encoded by encoding/xml
+				res, err = res.Materialize()
+				if err != nil {
+					return nil, fmt.Errorf("Method %s in system.multicall failed: %v", methodName, err)
+				}
 				results = append(results, res)
 			}
 			return &Value{Array: &Array{results}}, nil
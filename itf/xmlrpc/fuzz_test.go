@@ -0,0 +1,173 @@
+//go:build go1.18
+// +build go1.18
+
+package xmlrpc
+
+import (
+	"strconv"
+	"testing"
+)
+
+// fuzzValueBuilder builds a Value tree from arbitrary bytes.
+type fuzzValueBuilder struct {
+	data []byte
+}
+
+func (b *fuzzValueBuilder) next() byte {
+	if len(b.data) == 0 {
+		return 0
+	}
+	c := b.data[0]
+	b.data = b.data[1:]
+	return c
+}
+
+func (b *fuzzValueBuilder) str() string {
+	n := int(b.next() % 8)
+	if n > len(b.data) {
+		n = len(b.data)
+	}
+	s := string(b.data[:n])
+	b.data = b.data[n:]
+	return s
+}
+
+func (b *fuzzValueBuilder) value(depth int) *Value {
+	c := b.next()
+	v := &Value{}
+	switch c % 8 {
+	case 0:
+		v.I4 = b.str()
+	case 1:
+		v.Int = strconv.Itoa(int(int8(b.next())))
+	case 2:
+		v.Boolean = b.str()
+	case 3:
+		v.ElemString = b.str()
+	case 4:
+		v.Double = b.str()
+	case 5:
+		v.FlatString = b.str()
+	case 6:
+		v.Array = &Array{}
+		if depth < 4 {
+			for n := b.next() % 4; n > 0; n-- {
+				v.Array.Data = append(v.Array.Data, b.value(depth+1))
+			}
+		}
+	case 7:
+		v.Struct = &Struct{}
+		if depth < 4 {
+			for n := b.next() % 4; n > 0; n-- {
+				v.Struct.Members = append(v.Struct.Members, &Member{Name: b.str(), Value: b.value(depth + 1)})
+			}
+		}
+	}
+	// malformed: additional character data
+	if c&8 != 0 {
+		v.FlatString += b.str()
+	}
+	return v
+}
+
+// checkQuery exercises the accessors of Query and checks, that a type mismatch
+// always sets an error.
+func checkQuery(t *testing.T, v *Value) {
+	q := Q(v)
+	if i := q.Int(); q.Err() == nil && v.I4 == "" && v.Int == "" {
+		t.Errorf("Int: no error for %s", v)
+	} else if q.Err() != nil && i != 0 {
+		t.Errorf("Int: %d returned with error for %s", i, v)
+	}
+	q = Q(v)
+	if b := q.Bool(); q.Err() == nil && v.Boolean != "0" && v.Boolean != "1" {
+		t.Errorf("Bool: no error for %s", v)
+	} else if q.Err() != nil && b {
+		t.Errorf("Bool: true returned with error for %s", v)
+	}
+	q = Q(v)
+	if f := q.Float64(); q.Err() == nil && v.Double == "" {
+		t.Errorf("Float64: no error for %s", v)
+	} else if q.Err() != nil && f != 0 {
+		t.Errorf("Float64: %v returned with error for %s", f, v)
+	}
+	q = Q(v)
+	_ = q.String()
+	if q.Err() == nil && (v.Boolean != "" || v.I4 != "" || v.Int != "" || v.Double != "" ||
+		v.Array != nil || v.Struct != nil) && v.ElemString == "" {
+		t.Errorf("String: no error for %s", v)
+	}
+	q = Q(v)
+	if a := q.Any(); q.Err() != nil && a != nil {
+		switch a.(type) {
+		case string:
+			// String returns the character data also on error
+		default:
+			if a != 0 && a != false && a != 0.0 {
+				t.Errorf("Any: %v returned with error for %s", a, v)
+			}
+		}
+	}
+	q = Q(v)
+	if ss := q.Strings(); q.Err() != nil && ss != nil {
+		t.Errorf("Strings: %v returned with error for %s", ss, v)
+	}
+
+	// struct
+	q = Q(v)
+	m := q.Map()
+	if q.Err() == nil && v.Struct == nil {
+		t.Errorf("Map: no error for %s", v)
+	}
+	for name, mq := range m {
+		checkQuery(t, mq.Value())
+		// chained error is shared
+		if q.Key(name).Value() != mq.Value() {
+			t.Errorf("Key: wrong member %s of %s", name, v)
+		}
+	}
+	if q.Err() == nil {
+		q.Key("\x00missing")
+		if q.Err() == nil {
+			t.Errorf("Key: no error for missing member of %s", v)
+		}
+		// subsequent accesses return zero values
+		if q.Map() != nil || q.Slice() != nil || q.Key("any").Int() != 0 {
+			t.Errorf("Previous error not honored for %s", v)
+		}
+	}
+
+	// array
+	q = Q(v)
+	s := q.Slice()
+	if q.Err() == nil && v.Array == nil {
+		t.Errorf("Slice: no error for %s", v)
+	}
+	for i, e := range s {
+		checkQuery(t, e.Value())
+		if q.Idx(i).Value() != e.Value() {
+			t.Errorf("Idx: wrong element %d of %s", i, v)
+		}
+	}
+	if q.Err() == nil {
+		q.Idx(len(s))
+		if q.Err() == nil {
+			t.Errorf("Idx: no error for index out of bounds of %s", v)
+		}
+	}
+}
+
+// FuzzQuery constructs Value trees from arbitrary bytes and checks the Query
+// accessors.
+func FuzzQuery(f *testing.F) {
+	f.Add([]byte{})
+	f.Add([]byte{0, 2, '4', '2'})
+	f.Add([]byte{2, 1, '1'})
+	f.Add([]byte{4, 3, '1', '.', '5'})
+	f.Add([]byte{6, 2, 1, 5, 3, 3, 'a', 'b', 'c'})
+	f.Add([]byte{7, 2, 1, 'a', 1, 7, 1, 1, 'b', 12, 1, 'x', 1})
+	f.Fuzz(func(t *testing.T, data []byte) {
+		b := &fuzzValueBuilder{data: data}
+		checkQuery(t, b.value(0))
+	})
+}
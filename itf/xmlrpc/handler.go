@@ -2,59 +2,267 @@ package xmlrpc
 
 import (
 	"bytes"
+	"crypto/subtle"
 	"encoding/xml"
+	"fmt"
 	"io"
+	"net"
 	"net/http"
 	"strconv"
+	"sync"
+	"time"
 
 	"github.com/mdzio/go-logging"
 
-	"golang.org/x/net/html/charset"
-	"golang.org/x/text/encoding/charmap"
+	"github.com/mdzio/go-hmccu/internal/enc"
+	"github.com/mdzio/go-hmccu/internal/remote"
 )
 
 // max. size of a valid request, if not specified: 10 MB
 const requestSizeLimit = 10 * 1024 * 1024
 
+// Character encodings for the responses of the Handler.
+const (
+	CharsetISO88591 = "ISO-8859-1"
+	CharsetUTF8     = "UTF-8"
+)
+
 var svrLog = logging.Get("xmlrpc-server")
 
+// max. capacity of a request buffer to be put back into the pool
+const maxPooledBufferSize = 256 * 1024
+
+// requestBufferPool holds reusable buffers for reading requests.
+var requestBufferPool = sync.Pool{
+	New: func() interface{} { return new(bytes.Buffer) },
+}
+
 // Handler implements a http.Handler which can handle XML-RPC requests. Remote
 // calls are dispatched to the registered Method's.
 type Handler struct {
 	RequestSizeLimit int64
+
+	// ResponseCharset selects the character encoding of the responses:
+	// CharsetISO88591 (default, expected by the CCU) or CharsetUTF8.
+	ResponseCharset string
+
+	// If Recorder is set, all requests and responses are recorded (e.g. for
+	// debugging). Recorded requests can be replayed with Replay. Responses
+	// with an ArrayStream (see NewArrayStream) are then not streamed.
+	Recorder Recorder
+
+	// MaxDepth limits the nesting depth of arrays and structs in requests
+	// (default: DefaultMaxDepth).
+	MaxDepth int
+
+	// If Authenticator is set, only requests are accepted, for which the
+	// Authenticator returns true. Other requests are rejected with status 401
+	// (Unauthorized). See BasicAuth for an example.
+	Authenticator func(*http.Request) bool
+
+	// If AllowedRemotes is set, only requests from these networks are accepted
+	// (e.g. only from the CCU). Other requests are rejected with status 403
+	// (Forbidden).
+	AllowedRemotes []net.IPNet
+
 	Dispatcher
 }
 
+// BasicAuth returns an Authenticator for the Handler, which accepts only
+// requests with the specified credentials (HTTP basic authentication).
+func BasicAuth(user, password string) func(*http.Request) bool {
+	return func(req *http.Request) bool {
+		u, p, ok := req.BasicAuth()
+		// evaluate both comparisons to avoid timing differences
+		userOK := subtle.ConstantTimeCompare([]byte(u), []byte(user)) == 1
+		passwordOK := subtle.ConstantTimeCompare([]byte(p), []byte(password)) == 1
+		return ok && userOK && passwordOK
+	}
+}
+
+// MaxRequestSize returns the max. size of a request in bytes. Larger requests
+// are rejected with status 413 (Payload Too Large).
+func (h *Handler) MaxRequestSize() int64 {
+	if h.RequestSizeLimit == 0 {
+		return requestSizeLimit
+	}
+	return h.RequestSizeLimit
+}
+
 func (h *Handler) ServeHTTP(resp http.ResponseWriter, req *http.Request) {
-	svrLog.Tracef("Request received from %s, URI %s", req.RemoteAddr, req.RequestURI)
+	if svrLog.TraceEnabled() {
+		svrLog.Tracef("Request received from %s, URI %s", req.RemoteAddr, Redact(req.RequestURI))
+	}
+
+	// check remote address
+	if !remote.Allowed(h.AllowedRemotes, req.RemoteAddr) {
+		svrLog.Warningf("Rejecting request from not allowed remote address %s", req.RemoteAddr)
+		http.Error(resp, "Forbidden", http.StatusForbidden)
+		return
+	}
+
+	// authenticate request
+	if h.Authenticator != nil && !h.Authenticator(req) {
+		svrLog.Warningf("Rejecting unauthenticated request from %s", req.RemoteAddr)
+		resp.Header().Set("WWW-Authenticate", `Basic realm="XML-RPC"`)
+		http.Error(resp, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
 
 	// read request
-	limit := h.RequestSizeLimit
-	if limit == 0 {
-		limit = requestSizeLimit
+	limit := h.MaxRequestSize()
+	reqLimitReader := io.LimitReader(req.Body, limit+1)
+	buf := requestBufferPool.Get().(*bytes.Buffer)
+	buf.Reset()
+	// a recorder may hold the request, do not reuse the buffer in this case
+	if h.Recorder == nil {
+		defer func() {
+			if buf.Cap() <= maxPooledBufferSize {
+				requestBufferPool.Put(buf)
+			}
+		}()
 	}
-	reqLimitReader := http.MaxBytesReader(resp, req.Body, limit)
-	reqBuf, err := io.ReadAll(reqLimitReader)
+	_, err := buf.ReadFrom(reqLimitReader)
+	reqBuf := buf.Bytes()
 	if err != nil {
 		svrLog.Errorf("Reading of request failed from %s: %v", req.RemoteAddr, err)
 		http.Error(resp, "Reading of request failed: "+err.Error(), http.StatusBadRequest)
 		return
 	}
+	if int64(len(reqBuf)) > limit {
+		svrLog.Errorf("Request from %s exceeds size limit of %d bytes", req.RemoteAddr, limit)
+		// do not reuse connection, the rest of the request is not read
+		resp.Header().Set("Connection", "close")
+		http.Error(resp, fmt.Sprintf("Request exceeds size limit of %d bytes", limit), http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	// process request
+	r, herr := h.handle(reqBuf, req.RemoteAddr)
+	if herr == nil && r.stream != nil && h.Recorder == nil {
+		h.sendStream(resp, r, req.RemoteAddr)
+		return
+	}
+	var respBuf []byte
+	if herr == nil {
+		respBuf, herr = h.encode(r, req.RemoteAddr)
+	}
+	if h.Recorder != nil {
+		rec := &Record{Time: time.Now(), RemoteAddr: req.RemoteAddr, Request: reqBuf, Response: respBuf}
+		if herr != nil {
+			rec.Error = herr.msg
+		}
+		h.Recorder.Record(rec)
+	}
+	if herr != nil {
+		http.Error(resp, herr.msg, herr.code)
+		return
+	}
+
+	// send response
+	resp.Header().Set("Content-Type", "text/xml")
+	resp.Header().Set("Content-Length", strconv.Itoa(len(respBuf)))
+	_, err = resp.Write(respBuf)
+	if err != nil {
+		svrLog.Warningf("Sending of response for %s failed: %v", req.RemoteAddr, err)
+		return
+	}
+}
+
+// httpError is an error with a HTTP status code.
+type httpError struct {
+	msg  string
+	code int
+}
+
+// response is the result of a dispatched call.
+type response struct {
+	charset string
+	msg     *MethodResponse
+	// result with elements created on demand, msg is nil
+	stream *ArrayStream
+}
+
+// writeTo encodes the response in the selected character encoding. The
+// elements of an ArrayStream are written one by one.
+func (r *response) writeTo(w io.Writer) error {
+	var encw *enc.ISO8859_1Writer
+	if r.charset == CharsetISO88591 {
+		encw = enc.NewISO8859_1Writer(w)
+		w = encw
+	}
+	// write xml header
+	if _, err := fmt.Fprintf(w, "<?xml version=\"1.0\" encoding=\"%s\"?>\n", r.charset); err != nil {
+		return err
+	}
+	// encode response to xml
+	var err error
+	if r.stream != nil {
+		// array elements are created while encoding
+		err = writeStreamedResponse(w, r.stream)
+	} else {
+		err = xml.NewEncoder(w).Encode(r.msg)
+	}
+	if err != nil {
+		return err
+	}
+	if encw != nil {
+		return encw.Flush()
+	}
+	return nil
+}
+
+// process decodes the request, dispatches the call and returns the encoded
+// response.
+func (h *Handler) process(reqBuf []byte, remoteAddr string) ([]byte, *httpError) {
+	r, herr := h.handle(reqBuf, remoteAddr)
+	if herr != nil {
+		return nil, herr
+	}
+	return h.encode(r, remoteAddr)
+}
+
+// encode returns the complete encoded response.
+func (h *Handler) encode(r *response, remoteAddr string) ([]byte, *httpError) {
+	var respBuf bytes.Buffer
+	if err := r.writeTo(&respBuf); err != nil {
+		svrLog.Errorf("Encoding of response for %s failed: %v", remoteAddr, err)
+		return nil, &httpError{"Encoding of response failed: " + err.Error(), http.StatusInternalServerError}
+	}
+	resp := respBuf.Bytes()
 	if svrLog.TraceEnabled() {
 		// attention: log message is probably ISO8859-1 encoded!
-		svrLog.Tracef("Request XML: %s", string(reqBuf))
+		svrLog.Tracef("Response XML: %s", Redact(string(resp)))
+	}
+	return resp, nil
+}
+
+// sendStream writes a response with an ArrayStream directly to the client
+// (chunked transfer encoding). Only one array element exists at a time. If
+// an element can not be created, the response is truncated and invalid.
+func (h *Handler) sendStream(resp http.ResponseWriter, r *response, remoteAddr string) {
+	if svrLog.TraceEnabled() {
+		svrLog.Tracef("Response XML: streamed array with %d elements", r.stream.Len)
+	}
+	resp.Header().Set("Content-Type", "text/xml")
+	if err := r.writeTo(resp); err != nil {
+		svrLog.Errorf("Sending of streamed response for %s failed: %v", remoteAddr, err)
+	}
+}
+
+// handle decodes the request and dispatches the call.
+func (h *Handler) handle(reqBuf []byte, remoteAddr string) (*response, *httpError) {
+	if svrLog.TraceEnabled() {
+		// attention: log message is probably ISO8859-1 encoded!
+		svrLog.Tracef("Request XML: %s", Redact(string(reqBuf)))
 	}
 
 	// decode request from xml
-	reqReader := bytes.NewBuffer(reqBuf)
 	methodCall := &MethodCall{}
-	dec := xml.NewDecoder(reqReader)
-	dec.CharsetReader = charset.NewReaderLabel
-	err = dec.Decode(methodCall)
+	err := decodeXML(reqBuf, methodCall, h.MaxDepth)
 	if err != nil {
-		svrLog.Errorf("Decoding of request from %s failed: %v", req.RemoteAddr, err)
-		http.Error(resp, "Decoding of request failed: "+err.Error(), http.StatusBadRequest)
-		return
+		svrLog.Errorf("Decoding of request from %s failed: %v", remoteAddr, err)
+		return nil, &httpError{"Decoding of request failed: " + err.Error(), http.StatusBadRequest}
 	}
 
 	// convert Params to Array
@@ -70,40 +278,27 @@ func (h *Handler) ServeHTTP(resp http.ResponseWriter, req *http.Request) {
 
 	// dispatch call
 	res, err := h.Dispatch(methodCall.MethodName, args)
-	var methodResponse *MethodResponse
+	r := &response{}
 	if err != nil {
-		svrLog.Warningf("Sending error response to %s: %v", req.RemoteAddr, err)
-		methodResponse = newFaultResponse(err)
+		svrLog.Warningf("Sending error response to %s: %v", remoteAddr, err)
+		r.msg = newFaultResponse(err)
 	} else {
-		methodResponse = newMethodResponse(res)
-	}
-
-	// use ISO8859-1 character encoding for response
-	var respBuf bytes.Buffer
-	respWriter := charmap.ISO8859_1.NewEncoder().Writer(&respBuf)
-
-	// write xml header
-	respWriter.Write([]byte("<?xml version=\"1.0\" encoding=\"ISO-8859-1\"?>\n"))
-
-	// encode response to xml
-	enc := xml.NewEncoder(respWriter)
-	err = enc.Encode(methodResponse)
-	if err != nil {
-		svrLog.Errorf("Encoding of response for %s failed: %v", req.RemoteAddr, err)
-		http.Error(resp, "Encoding of response failed: "+err.Error(), http.StatusInternalServerError)
-		return
-	}
-	if svrLog.TraceEnabled() {
-		// attention: log message is ISO8859-1 encoded!
-		svrLog.Tracef("Response XML: %s", respBuf.String())
+		r.stream = res.Stream()
+		if r.stream == nil {
+			r.msg = newMethodResponse(res)
+		}
 	}
 
-	// send response
-	resp.Header().Set("Content-Type", "text/xml")
-	resp.Header().Set("Content-Length", strconv.Itoa(respBuf.Len()))
-	_, err = resp.Write(respBuf.Bytes())
-	if err != nil {
-		svrLog.Warningf("Sending of response for %s failed: %v", req.RemoteAddr, err)
-		return
+	// select character encoding for response
+	respCharset := h.ResponseCharset
+	switch respCharset {
+	case "", CharsetISO88591:
+		respCharset = CharsetISO88591
+	case CharsetUTF8:
+	default:
+		svrLog.Errorf("Unsupported response character encoding: %s", respCharset)
+		return nil, &httpError{"Unsupported response character encoding: " + respCharset, http.StatusInternalServerError}
 	}
+	r.charset = respCharset
+	return r, nil
 }
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"errors"
 	"io/ioutil"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"strings"
@@ -30,6 +31,32 @@ func TestServerBadRequest(t *testing.T) {
 	}
 }
 
+func TestServerRequestTooLarge(t *testing.T) {
+	h := &Handler{Dispatcher: &BasicDispatcher{}, RequestSizeLimit: 10}
+	if h.MaxRequestSize() != 10 {
+		t.Error(h.MaxRequestSize())
+	}
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+
+	buf := bytes.NewBufferString("<methodCall>")
+	resp, err := http.Post(srv.URL, "text/xml", buf)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer resp.Body.Close()
+	msg, _ := ioutil.ReadAll(resp.Body)
+	if string(msg) != "Request exceeds size limit of 10 bytes\n" {
+		t.Errorf("unexpected status message: %s", string(msg))
+	}
+	if resp.StatusCode != http.StatusRequestEntityTooLarge {
+		t.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+	if (&Handler{}).MaxRequestSize() != requestSizeLimit {
+		t.Error("unexpected default limit")
+	}
+}
+
 func TestServerUnknownMethod(t *testing.T) {
 	h := &Handler{Dispatcher: &BasicDispatcher{}}
 	srv := httptest.NewServer(h)
@@ -53,6 +80,58 @@ func TestServerUnknownMethod(t *testing.T) {
 	}
 }
 
+func TestServerAuthentication(t *testing.T) {
+	h := &Handler{Dispatcher: &BasicDispatcher{}, Authenticator: BasicAuth("user", "secret")}
+	h.AddSystemMethods()
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+	host := strings.TrimPrefix(srv.URL, "http://")
+
+	// without credentials
+	resp, err := http.Post(srv.URL, "text/xml", strings.NewReader("<methodCall/>"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+	if resp.Header.Get("WWW-Authenticate") == "" {
+		t.Error("missing authentication header")
+	}
+
+	// invalid credentials
+	cln := Client{Addr: "user:wrong@" + host}
+	if _, err := cln.Call("system.listMethods", []*Value{}); err == nil {
+		t.Error("expected error")
+	}
+
+	// valid credentials
+	cln = Client{Addr: "user:secret@" + host}
+	if _, err := cln.Call("system.listMethods", []*Value{}); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestServerAllowedRemotes(t *testing.T) {
+	_, other, _ := net.ParseCIDR("192.168.0.20/32")
+	h := &Handler{Dispatcher: &BasicDispatcher{}, AllowedRemotes: []net.IPNet{*other}}
+	h.AddSystemMethods()
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+	cln := Client{Addr: strings.TrimPrefix(srv.URL, "http://")}
+
+	if _, err := cln.Call("system.listMethods", []*Value{}); err == nil {
+		t.Error("expected error")
+	}
+
+	_, local, _ := net.ParseCIDR("127.0.0.0/8")
+	h.AllowedRemotes = append(h.AllowedRemotes, *local)
+	if _, err := cln.Call("system.listMethods", []*Value{}); err != nil {
+		t.Error(err)
+	}
+}
+
 func TestServer(t *testing.T) {
 	h := &Handler{Dispatcher: &BasicDispatcher{}}
 	h.AddSystemMethods()
@@ -111,6 +190,36 @@ func TestServer(t *testing.T) {
 	}
 }
 
+func TestServerResponseCharset(t *testing.T) {
+	for _, c := range []struct {
+		charset string
+		want    string
+	}{
+		{"", "<?xml version=\"1.0\" encoding=\"ISO-8859-1\"?>\n" +
+			"<methodResponse><params><param><value>\xe4</value></param></params></methodResponse>"},
+		{CharsetUTF8, "<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n" +
+			"<methodResponse><params><param><value>\xc3\xa4</value></param></params></methodResponse>"},
+	} {
+		h := &Handler{Dispatcher: &BasicDispatcher{}, ResponseCharset: c.charset}
+		h.HandleFunc("umlaut", func(args *Value) (*Value, error) {
+			return &Value{FlatString: "\u00e4"}, nil
+		})
+		srv := httptest.NewServer(h)
+
+		buf := bytes.NewBufferString("<methodCall><methodName>umlaut</methodName><params></params></methodCall>")
+		resp, err := http.Post(srv.URL, "text/xml", buf)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		msg, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		srv.Close()
+		if string(msg) != c.want {
+			t.Errorf("unexpected response: %q", string(msg))
+		}
+	}
+}
+
 func TestServerMulticall(t *testing.T) {
 	h := &Handler{Dispatcher: &BasicDispatcher{}}
 	h.AddSystemMethods()
@@ -216,3 +325,36 @@ func TestServerWithUnknownMethod(t *testing.T) {
 		t.Fatalf("unexpected result: %+v", res)
 	}
 }
+
+// eventMulticall returns a system.multicall request with n events, like it is
+// sent by the CCU.
+func eventMulticall(n int) []byte {
+	var sb strings.Builder
+	sb.WriteString("<?xml version=\"1.0\" encoding=\"ISO-8859-1\"?>\n<methodCall><methodName>system.multicall</methodName><params><param><value><array><data>")
+	for i := 0; i < n; i++ {
+		sb.WriteString("<value><struct><member><name>methodName</name><value>event</value></member>" +
+			"<member><name>params</name><value><array><data><value>BidCos-RF</value><value>ABC0000001:1</value>" +
+			"<value>LEVEL</value><value><double>0.750000</double></value></data></array></value></member></struct></value>")
+	}
+	sb.WriteString("</data></array></value></param></params></methodCall>")
+	return []byte(sb.String())
+}
+
+func BenchmarkHandlerEventMulticall(b *testing.B) {
+	h := &Handler{Dispatcher: &BasicDispatcher{}}
+	h.AddSystemMethods()
+	h.HandleFunc("event", func(args *Value) (*Value, error) {
+		return &Value{}, nil
+	})
+	reqBuf := eventMulticall(20)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		req := httptest.NewRequest(http.MethodPost, "/RPC2", bytes.NewReader(reqBuf))
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if rec.Code != http.StatusOK {
+			b.Fatal(rec.Code)
+		}
+	}
+}
@@ -0,0 +1,380 @@
+package xmlrpc
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"golang.org/x/net/html/charset"
+)
+
+// LazyResponse holds the raw XML of a method response (see Client.CallLazy).
+// Values are only decoded on request.
+type LazyResponse struct {
+	buf      []byte
+	maxDepth int
+}
+
+// Value decodes the whole result of the method call.
+func (r *LazyResponse) Value() (*Value, error) {
+	resp := &MethodResponse{}
+	if err := decodeXML(r.buf, resp, r.maxDepth); err != nil {
+		return nil, fmt.Errorf("Decoding of response failed: %v", err)
+	}
+	if resp.Fault != nil {
+		return nil, faultError(resp.Fault)
+	}
+	if resp.Params == nil || len(resp.Params.Param) != 1 {
+		return nil, errors.New("Invalid or no parameters in response")
+	}
+	return resp.Params.Param[0].Value, nil
+}
+
+// Member decodes only the specified member of the result struct. If the
+// member does not exist, nil is returned (like Query.TryKey).
+func (r *LazyResponse) Member(name string) (*Value, error) {
+	ms, err := r.Members(name)
+	if err != nil {
+		return nil, err
+	}
+	return ms[name], nil
+}
+
+// Members decodes only the specified members of the result struct. The
+// decoding stops, when all members are found. Missing members are not
+// contained in the returned map.
+func (r *LazyResponse) Members(names ...string) (map[string]*Value, error) {
+	maxDepth := r.maxDepth
+	if maxDepth == 0 {
+		maxDepth = DefaultMaxDepth
+	}
+	wanted := make(map[string]bool, len(names))
+	for _, n := range names {
+		wanted[n] = true
+	}
+	res := make(map[string]*Value, len(names))
+	dec := r.decoder()
+
+	// seek the result value
+	if err := seekResult(dec); err != nil {
+		return nil, err
+	}
+	// seek struct
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, fmt.Errorf("Decoding of response failed: %v", err)
+		}
+		if t, ok := tok.(xml.StartElement); ok && t.Name.Local == "struct" {
+			break
+		}
+		if _, ok := tok.(xml.CharData); !ok {
+			return nil, errors.New("Result of method call is not a struct")
+		}
+	}
+	if maxDepth < 1 {
+		return nil, fmt.Errorf("Max. nesting depth of %d exceeded", maxDepth)
+	}
+
+	// iterate over members
+	for len(res) < len(wanted) {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, fmt.Errorf("Decoding of response failed: %v", err)
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			if t.Name.Local != "member" {
+				err = skipElement(dec)
+			} else {
+				err = readLazyMember(dec, wanted, res, maxDepth)
+			}
+			if err != nil {
+				return nil, fmt.Errorf("Decoding of response failed: %v", err)
+			}
+		case xml.EndElement:
+			// end of struct
+			return res, nil
+		}
+	}
+	return res, nil
+}
+
+// readLazyMember decodes the value of a struct member, if the member is
+// wanted. Otherwise the value is skipped.
+func readLazyMember(dec *xml.Decoder, wanted map[string]bool, res map[string]*Value, maxDepth int) error {
+	var name string
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "name":
+				if err := dec.DecodeElement(&name, &t); err != nil {
+					return err
+				}
+			case "value":
+				if _, done := res[name]; wanted[name] && !done {
+					v, err := readValue(dec, 1, maxDepth)
+					if err != nil {
+						return err
+					}
+					res[name] = v
+				} else if err := skipElement(dec); err != nil {
+					return err
+				}
+			default:
+				if err := skipElement(dec); err != nil {
+					return err
+				}
+			}
+		case xml.EndElement:
+			return nil
+		}
+	}
+}
+
+// checkFault returns the fault of the response as error, if any.
+func (r *LazyResponse) checkFault() error {
+	dec := r.decoder()
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return fmt.Errorf("Decoding of response failed: %v", err)
+		}
+		t, ok := tok.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		switch t.Name.Local {
+		case "methodResponse":
+			// descend
+		case "params":
+			return nil
+		case "fault":
+			// faults are small, decode completely
+			_, err := r.Value()
+			return err
+		default:
+			return errors.New("Invalid or no parameters in response")
+		}
+	}
+}
+
+func (r *LazyResponse) decoder() *xml.Decoder {
+	dec := xml.NewDecoder(bytes.NewReader(r.buf))
+	dec.CharsetReader = charset.NewReaderLabel
+	return dec
+}
+
+// seekResult moves the decoder behind the start element of the result value.
+func seekResult(dec *xml.Decoder) error {
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			return errors.New("Invalid or no parameters in response")
+		}
+		if err != nil {
+			return fmt.Errorf("Decoding of response failed: %v", err)
+		}
+		t, ok := tok.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		switch t.Name.Local {
+		case "methodResponse", "params", "param":
+			// descend
+		case "value":
+			return nil
+		default:
+			return errors.New("Invalid or no parameters in response")
+		}
+	}
+}
+
+// skipElement skips the current element. In contrast to xml.Decoder.Skip, no
+// recursion is used. Like the following readers, errors are returned without
+// context, which is added by the callers.
+func skipElement(dec *xml.Decoder) error {
+	depth := 0
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		switch tok.(type) {
+		case xml.StartElement:
+			depth++
+		case xml.EndElement:
+			if depth == 0 {
+				return nil
+			}
+			depth--
+		}
+	}
+}
+
+// readValue decodes a value. The start element must already be consumed. The
+// nesting depth of arrays and structs is limited by maxDepth.
+func readValue(dec *xml.Decoder, depth, maxDepth int) (*Value, error) {
+	v := &Value{XMLName: xml.Name{Local: "value"}}
+	var flat strings.Builder
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		switch t := tok.(type) {
+		case xml.CharData:
+			flat.Write(t)
+		case xml.StartElement:
+			var field *string
+			switch t.Name.Local {
+			case "i4":
+				field = &v.I4
+			case "int":
+				field = &v.Int
+			case "boolean":
+				field = &v.Boolean
+			case "string":
+				field = &v.ElemString
+			case "double":
+				field = &v.Double
+			case "dateTime.iso8601":
+				field = &v.DateTime
+			case "base64":
+				field = &v.Base64
+			case "struct", "array":
+				if depth+1 > maxDepth {
+					return nil, fmt.Errorf("Max. nesting depth of %d exceeded", maxDepth)
+				}
+				if t.Name.Local == "struct" {
+					v.Struct, err = readStruct(dec, depth+1, maxDepth)
+				} else {
+					v.Array, err = readArray(dec, depth+1, maxDepth)
+				}
+				if err != nil {
+					return nil, err
+				}
+				continue
+			default:
+				if err := skipElement(dec); err != nil {
+					return nil, err
+				}
+				continue
+			}
+			if err := dec.DecodeElement(field, &t); err != nil {
+				return nil, err
+			}
+		case xml.EndElement:
+			v.FlatString = flat.String()
+			return v, nil
+		}
+	}
+}
+
+func readStruct(dec *xml.Decoder, depth, maxDepth int) (*Struct, error) {
+	s := &Struct{}
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			if t.Name.Local != "member" {
+				if err := skipElement(dec); err != nil {
+					return nil, err
+				}
+				continue
+			}
+			m, err := readMember(dec, depth, maxDepth)
+			if err != nil {
+				return nil, err
+			}
+			s.Members = append(s.Members, m)
+		case xml.EndElement:
+			return s, nil
+		}
+	}
+}
+
+func readMember(dec *xml.Decoder, depth, maxDepth int) (*Member, error) {
+	m := &Member{}
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "name":
+				if err := dec.DecodeElement(&m.Name, &t); err != nil {
+					return nil, err
+				}
+			case "value":
+				m.Value, err = readValue(dec, depth, maxDepth)
+				if err != nil {
+					return nil, err
+				}
+			default:
+				if err := skipElement(dec); err != nil {
+					return nil, err
+				}
+			}
+		case xml.EndElement:
+			return m, nil
+		}
+	}
+}
+
+func readArray(dec *xml.Decoder, depth, maxDepth int) (*Array, error) {
+	a := &Array{}
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			if t.Name.Local != "data" {
+				if err := skipElement(dec); err != nil {
+					return nil, err
+				}
+				continue
+			}
+			// read values of data element
+			for done := false; !done; {
+				tok, err := dec.Token()
+				if err != nil {
+					return nil, err
+				}
+				switch t := tok.(type) {
+				case xml.StartElement:
+					if t.Name.Local != "value" {
+						if err := skipElement(dec); err != nil {
+							return nil, err
+						}
+						continue
+					}
+					v, err := readValue(dec, depth, maxDepth)
+					if err != nil {
+						return nil, err
+					}
+					a.Data = append(a.Data, v)
+				case xml.EndElement:
+					done = true
+				}
+			}
+		case xml.EndElement:
+			return a, nil
+		}
+	}
+}
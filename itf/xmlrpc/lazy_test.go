@@ -0,0 +1,95 @@
+package xmlrpc
+
+import (
+	"errors"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCallLazy(t *testing.T) {
+	paramset, err := NewMap(map[string]interface{}{
+		"LEVEL":   0.5,
+		"WORKING": true,
+		"NAME":    "Küche",
+		"LIST":    []interface{}{1, "a", map[string]interface{}{"X": 2}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := &Handler{Dispatcher: &BasicDispatcher{}}
+	h.HandleFunc("getParamset", func(*Value) (*Value, error) {
+		return paramset, nil
+	})
+	h.HandleFunc("fail", func(*Value) (*Value, error) {
+		return nil, errors.New("failed")
+	})
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+	cln := Client{Addr: strings.TrimPrefix(srv.URL, "http://")}
+
+	full, err := cln.Call("getParamset", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := cln.CallLazy("getParamset", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v, err := r.Value()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(v, full) {
+		t.Error(v)
+	}
+	for _, n := range []string{"LEVEL", "WORKING", "NAME", "LIST"} {
+		m, err := r.Member(n)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(m, Q(full).Key(n).Value()) {
+			t.Error(n, m)
+		}
+	}
+	if s := Q(mustMember(t, r, "NAME")).String(); s != "Küche" {
+		t.Error(s)
+	}
+	m, err := r.Member("UNKNOWN")
+	if err != nil || m != nil {
+		t.Error(m, err)
+	}
+	ms, err := r.Members("LEVEL", "WORKING", "UNKNOWN")
+	if err != nil || len(ms) != 2 {
+		t.Error(ms, err)
+	}
+
+	_, err = cln.CallLazy("fail", nil)
+	if err == nil || err.Error() != "RPC fault (code: -1, message: failed)" {
+		t.Error(err)
+	}
+}
+
+func mustMember(t *testing.T, r *LazyResponse, name string) *Value {
+	v, err := r.Member(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
+
+func TestLazyResponseMaxDepth(t *testing.T) {
+	nested := strings.Repeat("<value><array><data>", 3) + strings.Repeat("</data></array></value>", 3)
+	buf := "<?xml version=\"1.0\"?><methodResponse><params><param><value><struct>" +
+		"<member><name>A</name>" + nested + "</member>" +
+		"</struct></value></param></params></methodResponse>"
+	r := &LazyResponse{buf: []byte(buf), maxDepth: 3}
+	if _, err := r.Member("A"); err == nil {
+		t.Error("expected error")
+	}
+	r.maxDepth = 4
+	if _, err := r.Member("A"); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,122 @@
+package xmlrpc
+
+import (
+	"encoding/xml"
+	"io"
+	"unicode/utf8"
+)
+
+// scalar returns true, if the value contains no struct and no array.
+func (v *Value) scalar() bool {
+	return v.Struct == nil && v.Array == nil
+}
+
+// flat returns true, if the value is a scalar or a struct with only scalar
+// members.
+func (v *Value) flat() bool {
+	if v.Array != nil {
+		return false
+	}
+	if v.Struct != nil {
+		for _, m := range v.Struct.Members {
+			if m == nil || m.Value == nil || !m.Value.scalar() {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// MarshalXMLTo writes the XML representation of the value. The output is the
+// same as with encoding/xml, but the frequent cases (scalars and structs with
+// only scalar members, e.g. for events and setValue) are encoded without
+// reflection. Other values are encoded with encoding/xml.
+func (v *Value) MarshalXMLTo(w io.Writer) error {
+	if !v.flat() {
+		return xml.NewEncoder(w).Encode(v)
+	}
+	_, err := w.Write(v.appendFlat(make([]byte, 0, 256)))
+	return err
+}
+
+// appendFlat appends a flat value (see flat).
+func (v *Value) appendFlat(b []byte) []byte {
+	b = append(b, "<value>"...)
+	b = appendElem(b, "i4", v.I4)
+	b = appendElem(b, "int", v.Int)
+	b = appendElem(b, "boolean", v.Boolean)
+	b = appendElem(b, "string", v.ElemString)
+	b = appendEscaped(b, v.FlatString)
+	b = appendElem(b, "double", v.Double)
+	b = appendElem(b, "dateTime.iso8601", v.DateTime)
+	b = appendElem(b, "base64", v.Base64)
+	if v.Struct != nil {
+		b = append(b, "<struct>"...)
+		for _, m := range v.Struct.Members {
+			b = append(b, "<member><name>"...)
+			b = appendEscaped(b, m.Name)
+			b = append(b, "</name>"...)
+			b = m.Value.appendFlat(b)
+			b = append(b, "</member>"...)
+		}
+		b = append(b, "</struct>"...)
+	}
+	return append(b, "</value>"...)
+}
+
+// appendElem appends an element with text content, if the content is not
+// empty (omitempty).
+func appendElem(b []byte, name, content string) []byte {
+	if content == "" {
+		return b
+	}
+	b = append(b, '<')
+	b = append(b, name...)
+	b = append(b, '>')
+	b = appendEscaped(b, content)
+	b = append(b, "</"...)
+	b = append(b, name...)
+	return append(b, '>')
+}
+
+// appendEscaped appends the text escaped like xml.EscapeText.
+func appendEscaped(b []byte, s string) []byte {
+	for i := 0; i < len(s); {
+		r, size := utf8.DecodeRuneInString(s[i:])
+		switch {
+		case r == '"':
+			b = append(b, "&#34;"...)
+		case r == '\'':
+			b = append(b, "&#39;"...)
+		case r == '&':
+			b = append(b, "&amp;"...)
+		case r == '<':
+			b = append(b, "&lt;"...)
+		case r == '>':
+			b = append(b, "&gt;"...)
+		case r == '\t':
+			b = append(b, "&#x9;"...)
+		case r == '\n':
+			b = append(b, "&#xA;"...)
+		case r == '\r':
+			b = append(b, "&#xD;"...)
+		case !inCharacterRange(r) || (r == utf8.RuneError && size == 1):
+			b = append(b, "\uFFFD"...)
+		default:
+			b = append(b, s[i:i+size]...)
+		}
+		i += size
+	}
+	return b
+}
+
+// inCharacterRange checks, whether the rune is allowed in XML (see
+// encoding/xml).
+func inCharacterRange(r rune) bool {
+	return r == 0x09 ||
+		r == 0x0A ||
+		r == 0x0D ||
+		r >= 0x20 && r <= 0xD7FF ||
+		r >= 0xE000 && r <= 0xFFFD ||
+		r >= 0x10000 && r <= 0x10FFFF
+}
@@ -0,0 +1,79 @@
+package xmlrpc
+
+import (
+	"bytes"
+	"encoding/xml"
+	"testing"
+)
+
+func TestMarshalXMLTo(t *testing.T) {
+	cases := []*Value{
+		{},
+		NewString("a<b>&\"c\"\n"),
+		NewString("'\t\r\x01\xff😀"),
+		{ElemString: "Küche"},
+		NewInt(-123),
+		{Int: "7"},
+		NewBool(true),
+		NewFloat64(0.5),
+		{DateTime: "20210101T00:00:00"},
+		{Base64: "YWJj"},
+		{Struct: &Struct{}},
+		{Struct: &Struct{Members: []*Member{
+			{Name: "faultCode", Value: NewInt(-1)},
+			{Name: "faultString", Value: NewString("<error>")},
+		}}},
+		// fall back to encoding/xml
+		NewStrings([]string{"a", "b"}),
+		{Struct: &Struct{Members: []*Member{
+			{Name: "A", Value: NewStrings([]string{"x"})},
+		}}},
+	}
+	for _, v := range cases {
+		var want bytes.Buffer
+		if err := xml.NewEncoder(&want).Encode(v); err != nil {
+			t.Fatal(err)
+		}
+		var got bytes.Buffer
+		if err := v.MarshalXMLTo(&got); err != nil {
+			t.Fatal(err)
+		}
+		if got.String() != want.String() {
+			t.Errorf("got %s, want %s", got.String(), want.String())
+		}
+	}
+}
+
+var benchmarkEventValues = []*Value{
+	NewString("CUxD"),
+	NewString("CUX2801001:1"),
+	NewString("LEVEL"),
+	NewFloat64(0.75),
+}
+
+func BenchmarkMarshalXMLReflection(b *testing.B) {
+	var buf bytes.Buffer
+	b.ReportAllocs()
+	for n := 0; n < b.N; n++ {
+		buf.Reset()
+		enc := xml.NewEncoder(&buf)
+		for _, v := range benchmarkEventValues {
+			if err := enc.Encode(v); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
+
+func BenchmarkMarshalXMLTo(b *testing.B) {
+	var buf bytes.Buffer
+	b.ReportAllocs()
+	for n := 0; n < b.N; n++ {
+		buf.Reset()
+		for _, v := range benchmarkEventValues {
+			if err := v.MarshalXMLTo(&buf); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
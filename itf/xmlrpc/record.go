@@ -0,0 +1,115 @@
+package xmlrpc
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// Record is a recorded XML-RPC request with the response. The XML is stored
+// unmodified (e.g. ISO8859-1 encoded).
+type Record struct {
+	Time       time.Time
+	RemoteAddr string
+	Request    []byte
+	// Response is empty, if the request failed on HTTP level (see Error).
+	Response []byte
+	Error    string `json:",omitempty"`
+}
+
+// Recorder receives the records of a Handler.
+type Recorder interface {
+	Record(r *Record)
+}
+
+// WriterRecorder writes the records as JSON lines to a writer. The output can
+// be replayed with Handler.Replay.
+type WriterRecorder struct {
+	Writer io.Writer
+
+	mtx sync.Mutex
+}
+
+// Record implements Recorder.
+func (w *WriterRecorder) Record(r *Record) {
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+	if err := json.NewEncoder(w.Writer).Encode(r); err != nil {
+		svrLog.Errorf("Writing of record failed: %v", err)
+	}
+}
+
+// RingRecorder keeps the last records in memory.
+type RingRecorder struct {
+	// Max. number of records
+	Size int
+
+	mtx     sync.Mutex
+	records []*Record
+	next    int
+}
+
+// Record implements Recorder.
+func (rr *RingRecorder) Record(r *Record) {
+	rr.mtx.Lock()
+	defer rr.mtx.Unlock()
+	if rr.Size <= 0 {
+		return
+	}
+	if len(rr.records) < rr.Size {
+		rr.records = append(rr.records, r)
+		return
+	}
+	rr.records[rr.next] = r
+	rr.next = (rr.next + 1) % rr.Size
+}
+
+// Records returns the kept records, oldest first.
+func (rr *RingRecorder) Records() []*Record {
+	rr.mtx.Lock()
+	defer rr.mtx.Unlock()
+	rs := make([]*Record, 0, len(rr.records))
+	rs = append(rs, rr.records[rr.next:]...)
+	rs = append(rs, rr.records[:rr.next]...)
+	return rs
+}
+
+// Save writes the kept records as JSON lines to w. The output can be
+// replayed with Handler.Replay.
+func (rr *RingRecorder) Save(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	for _, r := range rr.Records() {
+		if err := enc.Encode(r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Replay reads recorded requests (JSON lines, see WriterRecorder) and
+// dispatches them again. The records with the new responses are returned. The
+// Recorder of the Handler is not invoked.
+func (h *Handler) Replay(r io.Reader) ([]*Record, error) {
+	var res []*Record
+	dec := json.NewDecoder(bufio.NewReader(r))
+	for {
+		var rec Record
+		err := dec.Decode(&rec)
+		if err == io.EOF {
+			return res, nil
+		}
+		if err != nil {
+			return res, fmt.Errorf("Reading of record failed: %v", err)
+		}
+		svrLog.Debugf("Replaying request from %s recorded at %s", rec.RemoteAddr, rec.Time)
+		resp, herr := h.process(rec.Request, rec.RemoteAddr)
+		rep := &Record{Time: time.Now(), RemoteAddr: rec.RemoteAddr, Request: rec.Request, Response: resp}
+		if herr != nil {
+			rep.Error = herr.msg
+		}
+		res = append(res, rep)
+	}
+}
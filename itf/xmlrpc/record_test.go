@@ -0,0 +1,76 @@
+package xmlrpc
+
+import (
+	"bytes"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRecordReplay(t *testing.T) {
+	var calls int
+	newHandler := func(rec Recorder) *Handler {
+		h := &Handler{Dispatcher: &BasicDispatcher{}, Recorder: rec}
+		h.HandleFunc("echo", func(args *Value) (*Value, error) {
+			calls++
+			return Q(args).Idx(0).Value(), nil
+		})
+		return h
+	}
+
+	// record
+	var buf bytes.Buffer
+	ring := &RingRecorder{Size: 2}
+	tee := &teeRecorder{&WriterRecorder{Writer: &buf}, ring}
+	srv := httptest.NewServer(newHandler(tee))
+	cln := Client{Addr: strings.TrimPrefix(srv.URL, "http://")}
+	for _, s := range []string{"a", "b", "c"} {
+		if _, err := cln.Call("echo", []*Value{NewString(s)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	srv.Close()
+	if calls != 3 {
+		t.Fatal(calls)
+	}
+	rs := ring.Records()
+	if len(rs) != 2 || !bytes.Contains(rs[0].Request, []byte("b")) || !bytes.Contains(rs[1].Response, []byte("c")) {
+		t.Fatal(rs)
+	}
+
+	// replay
+	h := newHandler(nil)
+	reps, err := h.Replay(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reps) != 3 || calls != 6 {
+		t.Fatal(len(reps), calls)
+	}
+	if !bytes.Contains(reps[0].Response, []byte("<value>a</value>")) || reps[0].Error != "" {
+		t.Error(string(reps[0].Response))
+	}
+
+	// replay of an invalid request
+	ring.Record(&Record{Request: []byte("invalid")})
+	var buf2 bytes.Buffer
+	if err := ring.Save(&buf2); err != nil {
+		t.Fatal(err)
+	}
+	reps, err = h.Replay(&buf2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reps) != 2 || reps[1].Error != "Decoding of request failed: EOF" {
+		t.Error(reps)
+	}
+}
+
+type teeRecorder struct {
+	first, second Recorder
+}
+
+func (t *teeRecorder) Record(r *Record) {
+	t.first.Record(r)
+	t.second.Record(r)
+}
@@ -0,0 +1,24 @@
+package xmlrpc
+
+import "github.com/mdzio/go-hmccu/internal/redact"
+
+// SetRedactSecrets enables or disables the masking of credentials in log
+// messages and errors of all packages of this module (e.g. xmlrpc, script).
+// It should only be disabled for debugging purposes. The masking is enabled by
+// default.
+func SetRedactSecrets(enabled bool) {
+	redact.SetEnabled(enabled)
+}
+
+// RedactSecrets returns true, if the masking of credentials is enabled (see
+// SetRedactSecrets).
+func RedactSecrets() bool {
+	return redact.Enabled()
+}
+
+// Redact masks credentials (passwords in URLs, session IDs, Authorization
+// headers) in a string, if the masking is enabled. It should be used for all
+// log messages, which contain addresses, URIs or request/response data.
+func Redact(s string) string {
+	return redact.String(s)
+}
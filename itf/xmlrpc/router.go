@@ -0,0 +1,76 @@
+package xmlrpc
+
+import (
+	"net/http"
+	"sort"
+	"sync"
+)
+
+// Router implements a http.Handler, which serves XML-RPC requests for multiple
+// paths. Each path is dispatched to its own Dispatcher. The CCU registers the
+// interface processes with different callback paths (e.g. /RPC2, /bidcos,
+// /groups). With a Router one process can serve these paths distinctly, and
+// the callbacks can be attributed to the correct interface.
+type Router struct {
+	// Settings are applied to all paths (e.g. RequestSizeLimit,
+	// ResponseCharset, Recorder, MaxDepth). The Dispatcher field is ignored.
+	Settings Handler
+
+	mtx      sync.RWMutex
+	handlers map[string]*Handler // key: path
+}
+
+// Handle registers the dispatcher for the specified path. A previously
+// registered dispatcher for the path is replaced.
+func (r *Router) Handle(path string, d Dispatcher) {
+	h := r.Settings
+	h.Dispatcher = d
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	if r.handlers == nil {
+		r.handlers = make(map[string]*Handler)
+	}
+	r.handlers[path] = &h
+}
+
+// Remove unregisters the dispatcher for the specified path.
+func (r *Router) Remove(path string) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	delete(r.handlers, path)
+}
+
+// Paths returns the registered paths in sorted order.
+func (r *Router) Paths() []string {
+	r.mtx.RLock()
+	defer r.mtx.RUnlock()
+	ps := make([]string, 0, len(r.handlers))
+	for p := range r.handlers {
+		ps = append(ps, p)
+	}
+	sort.Strings(ps)
+	return ps
+}
+
+// RegisterAt registers the Router for all currently known paths at the
+// specified http.ServeMux. If mux is nil, the http.DefaultServeMux is used.
+func (r *Router) RegisterAt(mux *http.ServeMux) {
+	if mux == nil {
+		mux = http.DefaultServeMux
+	}
+	for _, p := range r.Paths() {
+		mux.Handle(p, r)
+	}
+}
+
+func (r *Router) ServeHTTP(resp http.ResponseWriter, req *http.Request) {
+	r.mtx.RLock()
+	h, ok := r.handlers[req.URL.Path]
+	r.mtx.RUnlock()
+	if !ok {
+		svrLog.Warningf("Request from %s for unknown path: %s", req.RemoteAddr, Redact(req.URL.Path))
+		http.NotFound(resp, req)
+		return
+	}
+	h.ServeHTTP(resp, req)
+}
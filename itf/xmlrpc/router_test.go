@@ -0,0 +1,50 @@
+package xmlrpc
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRouter(t *testing.T) {
+	newDispatcher := func(name string) Dispatcher {
+		d := &BasicDispatcher{}
+		d.HandleFunc("name", func(*Value) (*Value, error) {
+			return &Value{FlatString: name}, nil
+		})
+		return d
+	}
+	r := &Router{}
+	r.Handle("/RPC2", newDispatcher("rpc2"))
+	r.Handle("/bidcos", newDispatcher("bidcos"))
+	r.Handle("/groups", newDispatcher("groups"))
+	r.Remove("/groups")
+	if !reflect.DeepEqual(r.Paths(), []string{"/RPC2", "/bidcos"}) {
+		t.Error(r.Paths())
+	}
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+	addr := strings.TrimPrefix(srv.URL, "http://")
+
+	for _, path := range []string{"/RPC2", "/bidcos"} {
+		c := &Client{Addr: addr + path}
+		res, err := c.Call("name", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.FlatString != strings.ToLower(path[1:]) {
+			t.Error(path, res.FlatString)
+		}
+	}
+
+	resp, err := http.Post(srv.URL+"/groups", "text/xml", strings.NewReader("<methodCall/>"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Error(resp.StatusCode)
+	}
+}
@@ -0,0 +1,121 @@
+package xmlrpc
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// SignatureCheckingCaller checks the arguments of a call against the method
+// signatures of the server before sending the call. The signatures are
+// retrieved with system.methodSignature on first use of a method and are
+// cached. If the server provides no signature for a method, the arguments are
+// not checked.
+type SignatureCheckingCaller struct {
+	Caller Caller
+
+	mtx  sync.Mutex
+	sigs map[string][][]string // key: method name
+}
+
+// Call implements Caller.
+func (c *SignatureCheckingCaller) Call(method string, params Values) (*Value, error) {
+	// do not check system methods
+	if !strings.HasPrefix(method, "system.") {
+		sigs := c.signatures(method)
+		if len(sigs) > 0 && !matchesSignatures(sigs, params) {
+			var ss []string
+			for _, sig := range sigs {
+				ss = append(ss, "("+strings.Join(sig[1:], ", ")+")")
+			}
+			return nil, fmt.Errorf("Invalid arguments for method %s: (%s), expected: %s", method,
+				strings.Join(valueTypes(params), ", "), strings.Join(ss, " or "))
+		}
+	}
+	return c.Caller.Call(method, params)
+}
+
+// signatures returns the (cached) signatures of a method. Each signature
+// starts with the return type followed by the parameter types.
+func (c *SignatureCheckingCaller) signatures(method string) [][]string {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	if sigs, ok := c.sigs[method]; ok {
+		return sigs
+	}
+	resp, err := c.Caller.Call("system.methodSignature", Values{NewString(method)})
+	if err != nil {
+		// try again on next call
+		clnLog.Debugf("Retrieving signature of method %s failed: %v", method, err)
+		return nil
+	}
+	// the server returns a non-array value (e.g. "undef"), if no signature is
+	// defined
+	var sigs [][]string
+	if resp.Array != nil {
+		q := Q(resp)
+		for _, e := range q.Slice() {
+			sig := e.Strings()
+			if len(sig) > 0 {
+				sigs = append(sigs, sig)
+			}
+		}
+		if q.Err() != nil {
+			clnLog.Warningf("Invalid signature of method %s: %v", method, q.Err())
+			sigs = nil
+		}
+	}
+	if c.sigs == nil {
+		c.sigs = make(map[string][][]string)
+	}
+	c.sigs[method] = sigs
+	return sigs
+}
+
+func matchesSignatures(sigs [][]string, params Values) bool {
+	types := valueTypes(params)
+nextSig:
+	for _, sig := range sigs {
+		if len(sig)-1 != len(types) {
+			continue
+		}
+		for i, t := range types {
+			st := sig[i+1]
+			if st == "i4" {
+				st = "int"
+			}
+			// undef and nil are not checked
+			if st != t && st != "undef" && st != "nil" {
+				continue nextSig
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// valueTypes returns the XML-RPC data types of the values.
+func valueTypes(vs Values) []string {
+	ts := make([]string, len(vs))
+	for i, v := range vs {
+		switch {
+		case v.I4 != "" || v.Int != "":
+			ts[i] = "int"
+		case v.Boolean != "":
+			ts[i] = "boolean"
+		case v.Double != "":
+			ts[i] = "double"
+		case v.DateTime != "":
+			ts[i] = "dateTime.iso8601"
+		case v.Base64 != "":
+			ts[i] = "base64"
+		case v.Struct != nil:
+			ts[i] = "struct"
+		case v.Array != nil:
+			ts[i] = "array"
+		default:
+			ts[i] = "string"
+		}
+	}
+	return ts
+}
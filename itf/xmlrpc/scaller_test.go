@@ -0,0 +1,68 @@
+package xmlrpc
+
+import (
+	"errors"
+	"testing"
+)
+
+type sigTestCaller struct {
+	sigCalls int
+	calls    int
+}
+
+func (c *sigTestCaller) Call(method string, params Values) (*Value, error) {
+	if method != "system.methodSignature" {
+		c.calls++
+		return &Value{}, nil
+	}
+	c.sigCalls++
+	switch Q(params[0]).String() {
+	case "setValue":
+		return &Value{Array: &Array{[]*Value{
+			NewStrings([]string{"string", "string", "string", "int"}),
+			NewStrings([]string{"string", "string", "string", "double"}),
+		}}}, nil
+	case "unknown":
+		return nil, errors.New("no connection")
+	default:
+		return NewString("undef"), nil
+	}
+}
+
+func TestSignatureCheckingCaller(t *testing.T) {
+	sc := &sigTestCaller{}
+	c := &SignatureCheckingCaller{Caller: sc}
+
+	_, err := c.Call("setValue", Values{NewString("ABC:1"), NewString("LEVEL"), NewFloat64(1.0)})
+	if err != nil {
+		t.Error(err)
+	}
+	_, err = c.Call("setValue", Values{NewString("ABC:1"), NewString("LEVEL"), NewInt(1)})
+	if err != nil {
+		t.Error(err)
+	}
+	_, err = c.Call("setValue", Values{NewString("ABC:1"), NewString("LEVEL")})
+	if err == nil || err.Error() != "Invalid arguments for method setValue: (string, string), expected: (string, string, int) or (string, string, double)" {
+		t.Error(err)
+	}
+	_, err = c.Call("getValue", Values{NewString("ABC:1")})
+	if err != nil {
+		t.Error(err)
+	}
+	_, err = c.Call("getValue", Values{})
+	if err != nil {
+		t.Error(err)
+	}
+	_, err = c.Call("unknown", Values{})
+	if err != nil {
+		t.Error(err)
+	}
+	_, err = c.Call("unknown", Values{})
+	if err != nil {
+		t.Error(err)
+	}
+	// signatures are cached, except on errors
+	if sc.sigCalls != 4 || sc.calls != 6 {
+		t.Error(sc.sigCalls, sc.calls)
+	}
+}
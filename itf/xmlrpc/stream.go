@@ -0,0 +1,67 @@
+package xmlrpc
+
+import (
+	"fmt"
+	"io"
+)
+
+// ArrayStream creates the elements of an array on demand.
+type ArrayStream struct {
+	// Len is the number of elements.
+	Len int
+	// Elem creates the element with the specified index.
+	Elem func(i int) (*Value, error)
+}
+
+// NewArrayStream creates an array value, whose elements are created one by one
+// while the response of a Handler (or binrpc.Server) is encoded. The array is
+// not materialized as a whole, only one element exists at a time. This keeps
+// the memory bounded for large responses (e.g. listDevices). Other consumers
+// must call Materialize before accessing the elements.
+func NewArrayStream(n int, elem func(i int) (*Value, error)) *Value {
+	return &Value{stream: &ArrayStream{Len: n, Elem: elem}}
+}
+
+// Stream returns the ArrayStream of a value created by NewArrayStream, or nil.
+func (v *Value) Stream() *ArrayStream {
+	if v == nil {
+		return nil
+	}
+	return v.stream
+}
+
+// Materialize returns the value with all elements of an ArrayStream created.
+// Other values are returned unmodified.
+func (v *Value) Materialize() (*Value, error) {
+	if v == nil || v.stream == nil {
+		return v, nil
+	}
+	es := make([]*Value, v.stream.Len)
+	for i := range es {
+		e, err := v.stream.Elem(i)
+		if err != nil {
+			return nil, err
+		}
+		es[i] = e
+	}
+	return &Value{Array: &Array{es}}, nil
+}
+
+// writeStreamedResponse writes a method response with an ArrayStream as
+// result. The output is the same as for the materialized array.
+func writeStreamedResponse(w io.Writer, s *ArrayStream) error {
+	if _, err := io.WriteString(w, "<methodResponse><params><param><value><array><data>"); err != nil {
+		return err
+	}
+	for i := 0; i < s.Len; i++ {
+		e, err := s.Elem(i)
+		if err != nil {
+			return fmt.Errorf("Creating of array element %d failed: %w", i, err)
+		}
+		if err := e.MarshalXMLTo(w); err != nil {
+			return err
+		}
+	}
+	_, err := io.WriteString(w, "</data></array></value></param></params></methodResponse>")
+	return err
+}
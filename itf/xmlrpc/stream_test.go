@@ -0,0 +1,116 @@
+package xmlrpc
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func streamElem(i int) (*Value, error) {
+	return &Value{Struct: &Struct{[]*Member{
+		{"ADDRESS", NewString("JCK00000" + string(rune('0'+i)))},
+		{"CHILDREN", NewStrings([]string{"A", "B"})},
+		{"INDEX", NewInt(i)},
+	}}}, nil
+}
+
+func TestArrayStream(t *testing.T) {
+	h := &Handler{Dispatcher: &BasicDispatcher{}}
+	h.HandleFunc("stream", func(*Value) (*Value, error) {
+		return NewArrayStream(3, streamElem), nil
+	})
+	h.HandleFunc("array", func(*Value) (*Value, error) {
+		return NewArrayStream(3, streamElem).Materialize()
+	})
+	h.HandleFunc("failing", func(*Value) (*Value, error) {
+		return NewArrayStream(3, func(int) (*Value, error) { return nil, errors.New("failed") }), nil
+	})
+
+	req := func(method string) []byte {
+		return []byte(`<?xml version="1.0"?><methodCall><methodName>` + method + `</methodName><params></params></methodCall>`)
+	}
+	streamed, herr := h.process(req("stream"), "test")
+	if herr != nil {
+		t.Fatal(herr.msg)
+	}
+	materialized, herr := h.process(req("array"), "test")
+	if herr != nil {
+		t.Fatal(herr.msg)
+	}
+	if !bytes.Equal(streamed, materialized) {
+		t.Errorf("responses differ:\n%s\n%s", streamed, materialized)
+	}
+	if _, herr := h.process(req("failing"), "test"); herr == nil {
+		t.Error("expected error")
+	}
+
+	// querying materializes the array
+	q := Q(NewArrayStream(3, streamElem))
+	if len(q.Slice()) != 3 || q.Idx(2).Key("INDEX").Int() != 2 || q.Err() != nil {
+		t.Error(q.Err())
+	}
+	v, err := NewArrayStream(3, streamElem).Materialize()
+	if err != nil {
+		t.Fatal(err)
+	}
+	e, _ := streamElem(1)
+	if len(v.Array.Data) != 3 || !reflect.DeepEqual(v.Array.Data[1], e) {
+		t.Error(v)
+	}
+}
+
+// streamResponseWriter records the size of the written data.
+type streamResponseWriter struct {
+	header   http.Header
+	written  int
+	maxWrite int
+}
+
+func (w *streamResponseWriter) Header() http.Header { return w.header }
+
+func (w *streamResponseWriter) WriteHeader(int) {}
+
+func (w *streamResponseWriter) Write(b []byte) (int, error) {
+	w.written += len(b)
+	if len(b) > w.maxWrite {
+		w.maxWrite = len(b)
+	}
+	return len(b), nil
+}
+
+func TestArrayStreamBuffering(t *testing.T) {
+	const n = 1000
+	w := &streamResponseWriter{header: make(http.Header)}
+	var written []int
+	h := &Handler{Dispatcher: &BasicDispatcher{}}
+	h.HandleFunc("listDevices", func(*Value) (*Value, error) {
+		return NewArrayStream(n, func(i int) (*Value, error) {
+			// remember the size of the response so far
+			written = append(written, w.written)
+			return streamElem(i % 10)
+		}), nil
+	})
+	req, err := http.NewRequest("POST", "/", strings.NewReader(
+		`<?xml version="1.0"?><methodCall><methodName>listDevices</methodName><params></params></methodCall>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.ServeHTTP(w, req)
+
+	// previous elements are written, before the next one is created
+	for i := 1; i < n; i++ {
+		if written[i] <= written[i-1] {
+			t.Fatalf("element %d not written incrementally", i-1)
+		}
+	}
+	// no element is buffered with the others
+	if w.maxWrite > 1000 || w.written < n*100 {
+		t.Error(w.maxWrite, w.written)
+	}
+	if w.header.Get("Content-Length") != "" {
+		t.Error("unexpected Content-Length")
+	}
+}
@@ -1,13 +1,22 @@
 package xmlrpc
 
 import (
+	"bytes"
 	"encoding/xml"
 	"errors"
 	"fmt"
+	"io"
 	"strconv"
 	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/net/html/charset"
 )
 
+// DefaultMaxDepth is the default limit for the nesting depth of arrays and
+// structs in received messages.
+const DefaultMaxDepth = 64
+
 // MethodCall represents an XML-RPC method call.
 type MethodCall struct {
 	MethodName string   `xml:"methodName"`
@@ -45,11 +54,17 @@ type Value struct {
 	Struct     *Struct  `xml:"struct"`
 	Array      *Array   `xml:"array"`
 	XMLName    xml.Name `xml:"value"`
+
+	// elements of an array created on demand (see NewArrayStream)
+	stream *ArrayStream
 }
 
 // String implements the Stringer interface. Data types are indicated by the
 // representation.
 func (v *Value) String() string {
+	if v.stream != nil {
+		return fmt.Sprintf("[%d streamed elements]", v.stream.Len)
+	}
 	if v.I4 != "" {
 		return v.I4
 	}
@@ -174,6 +189,10 @@ type Query struct {
 // Q creates a new Query for the specified Value.
 func Q(v *Value) *Query {
 	var err error
+	// elements of an ArrayStream are needed for querying
+	if v != nil && v.stream != nil {
+		v, err = v.Materialize()
+	}
 	return &Query{value: v, err: &err}
 }
 
@@ -249,7 +268,7 @@ func (q *Query) String() string {
 func (q *Query) allZero() bool {
 	return q.value.Boolean == "" && q.value.I4 == "" && q.value.Int == "" && q.value.Double == "" &&
 		q.value.ElemString == "" && q.value.FlatString == "" && q.value.Base64 == "" &&
-		q.value.DateTime == "" && q.value.Array == nil && q.value.Struct == nil
+		q.value.DateTime == "" && q.value.Array == nil && q.value.Struct == nil && q.value.stream == nil
 }
 
 // IsEmpty returns true, if there is no previous error and the value is empty.
@@ -320,6 +339,35 @@ func (q *Query) Any() interface{} {
 	return q.String()
 }
 
+// Action is the value of a HomeMatic parameter of type ACTION. On the wire it
+// is a boolean, but it represents a trigger and not a state.
+type Action bool
+
+// AnyTyped is like Any, but uses the HomeMatic parameter type (e.g. FLOAT,
+// INTEGER, BOOL, ENUM, STRING, ACTION) to select the returned data type. For
+// type ACTION a value of type Action is returned. For an unknown parameter
+// type Any is used.
+func (q *Query) AnyTyped(paramType string) interface{} {
+	// previous error or empty optional?
+	if q.Err() != nil || q.value == nil {
+		return nil
+	}
+	switch paramType {
+	case "ACTION":
+		return Action(q.Bool())
+	case "BOOL":
+		return q.Bool()
+	case "INTEGER", "ENUM":
+		return q.Int()
+	case "FLOAT":
+		return q.Float64()
+	case "STRING":
+		return q.String()
+	default:
+		return q.Any()
+	}
+}
+
 // Map returns all members of an XML-RPC struct.
 func (q *Query) Map() map[string]*Query {
 	// previous error or empty optional?
@@ -499,12 +547,15 @@ func NewMap(val map[string]interface{}) (*Value, error) {
 	return &Value{Struct: &Struct{Members: ms}}, nil
 }
 
-// NewValue creates a value from a native data type. Supported types: bool, int,
-// float64, string, []string, []interface{} and map[string]interface{}.
+// NewValue creates a value from a native data type. Supported types: bool,
+// Action, int, float64, string, []string, []interface{} and
+// map[string]interface{}.
 func NewValue(in interface{}) (*Value, error) {
 	switch val := in.(type) {
 	case bool:
 		return NewBool(val), nil
+	case Action:
+		return NewBool(bool(val)), nil
 	case int:
 		return NewInt(val), nil
 	case float64:
@@ -551,3 +602,212 @@ func newMethodResponse(value *Value) *MethodResponse {
 		},
 	}
 }
+
+// decodeXML decodes a XML-RPC message (*MethodCall, *MethodResponse or a
+// single *Value). The message is decoded in a single pass with the token based
+// readers of the values (see readValue), which limit the nesting depth of
+// arrays and structs. If maxDepth is 0, DefaultMaxDepth is used.
+func decodeXML(buf []byte, v interface{}, maxDepth int) error {
+	if maxDepth == 0 {
+		maxDepth = DefaultMaxDepth
+	}
+	dec := xml.NewDecoder(bytes.NewReader(buf))
+	dec.CharsetReader = charsetReaderFor(buf)
+	switch m := v.(type) {
+	case *MethodCall:
+		return readMethodCall(dec, m, maxDepth)
+	case *MethodResponse:
+		return readMethodResponse(dec, m, maxDepth)
+	case *Value:
+		if _, err := readRoot(dec, "value"); err != nil {
+			return err
+		}
+		v, err := readValue(dec, 0, maxDepth)
+		if err != nil {
+			return err
+		}
+		*m = *v
+		return nil
+	default:
+		return fmt.Errorf("Unsupported type of XML-RPC message: %T", v)
+	}
+}
+
+// readRoot reads the start element of the message and checks its name.
+func readRoot(dec *xml.Decoder, name string) (xml.Name, error) {
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return xml.Name{}, err
+		}
+		if t, ok := tok.(xml.StartElement); ok {
+			if t.Name.Local != name {
+				return xml.Name{}, fmt.Errorf("Expected element type <%s> but have <%s>", name, t.Name.Local)
+			}
+			return t.Name, nil
+		}
+	}
+}
+
+func readMethodCall(dec *xml.Decoder, c *MethodCall, maxDepth int) error {
+	var err error
+	c.XMLName, err = readRoot(dec, "methodCall")
+	if err != nil {
+		return err
+	}
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "methodName":
+				err = dec.DecodeElement(&c.MethodName, &t)
+			case "params":
+				c.Params, err = readParams(dec, maxDepth)
+			default:
+				err = skipElement(dec)
+			}
+			if err != nil {
+				return err
+			}
+		case xml.EndElement:
+			return nil
+		}
+	}
+}
+
+func readMethodResponse(dec *xml.Decoder, r *MethodResponse, maxDepth int) error {
+	var err error
+	r.XMLName, err = readRoot(dec, "methodResponse")
+	if err != nil {
+		return err
+	}
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "params":
+				r.Params, err = readParams(dec, maxDepth)
+			case "fault":
+				r.Fault, err = readValueElement(dec, maxDepth)
+			default:
+				err = skipElement(dec)
+			}
+			if err != nil {
+				return err
+			}
+		case xml.EndElement:
+			return nil
+		}
+	}
+}
+
+func readParams(dec *xml.Decoder, maxDepth int) (*Params, error) {
+	ps := &Params{}
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			if t.Name.Local != "param" {
+				if err := skipElement(dec); err != nil {
+					return nil, err
+				}
+				continue
+			}
+			v, err := readValueElement(dec, maxDepth)
+			if err != nil {
+				return nil, err
+			}
+			ps.Param = append(ps.Param, &Param{v})
+		case xml.EndElement:
+			return ps, nil
+		}
+	}
+}
+
+// readValueElement reads the value element contained in the current element
+// (e.g. param or fault). The start element must already be consumed.
+func readValueElement(dec *xml.Decoder, maxDepth int) (*Value, error) {
+	var v *Value
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			if t.Name.Local != "value" {
+				err = skipElement(dec)
+			} else {
+				v, err = readValue(dec, 0, maxDepth)
+			}
+			if err != nil {
+				return nil, err
+			}
+		case xml.EndElement:
+			return v, nil
+		}
+	}
+}
+
+// charsetReaderFor returns the CharsetReader for decoding the XML message. The
+// CCU declares ISO-8859-1, but most messages (e.g. events) contain only ASCII
+// characters. In this case no conversion is needed, and the setup of the
+// conversion for each decoder is saved.
+func charsetReaderFor(buf []byte) func(string, io.Reader) (io.Reader, error) {
+	for _, b := range buf {
+		if b >= utf8.RuneSelf {
+			return charset.NewReaderLabel
+		}
+	}
+	if _, name := charset.Lookup(declaredEncoding(buf)); name != "windows-1252" {
+		return charset.NewReaderLabel
+	}
+	// ISO-8859-1 (resp. windows-1252) is ASCII compatible
+	return func(_ string, r io.Reader) (io.Reader, error) {
+		return r, nil
+	}
+}
+
+// declaredEncoding returns the encoding of the XML declaration, or an empty
+// string, if not declared.
+func declaredEncoding(buf []byte) string {
+	buf = bytes.TrimLeft(buf, " \t\r\n")
+	if !bytes.HasPrefix(buf, []byte("<?xml")) {
+		return ""
+	}
+	end := bytes.Index(buf, []byte("?>"))
+	if end == -1 {
+		return ""
+	}
+	decl := buf[:end]
+	p := bytes.Index(decl, []byte("encoding"))
+	if p == -1 {
+		return ""
+	}
+	decl = bytes.TrimLeft(decl[p+len("encoding"):], " \t\r\n")
+	if len(decl) == 0 || decl[0] != '=' {
+		return ""
+	}
+	decl = bytes.TrimLeft(decl[1:], " \t\r\n")
+	if len(decl) == 0 || (decl[0] != '"' && decl[0] != '\'') {
+		return ""
+	}
+	q := decl[0]
+	decl = decl[1:]
+	e := bytes.IndexByte(decl, q)
+	if e == -1 {
+		return ""
+	}
+	return string(decl[:e])
+}
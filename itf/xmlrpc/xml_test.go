@@ -3,6 +3,7 @@ package xmlrpc
 import (
 	"encoding/xml"
 	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -511,6 +512,40 @@ func TestQuery_Strings(t *testing.T) {
 	}
 }
 
+func TestQuery_AnyTyped(t *testing.T) {
+	cases := []struct {
+		v         *Value
+		paramType string
+		want      interface{}
+		wantErr   bool
+	}{
+		{&Value{Boolean: "1"}, "ACTION", Action(true), false},
+		{&Value{Boolean: "1"}, "BOOL", true, false},
+		{&Value{I4: "2"}, "ENUM", int(2), false},
+		{&Value{Double: "1.5"}, "FLOAT", 1.5, false},
+		{&Value{FlatString: "abc"}, "STRING", "abc", false},
+		{&Value{I4: "123"}, "UNKNOWN", int(123), false},
+		{&Value{FlatString: "abc"}, "ACTION", nil, true},
+		{nil, "ACTION", nil, false},
+	}
+	for _, c := range cases {
+		e := Q(c.v)
+		v := e.AnyTyped(c.paramType)
+		if (e.Err() != nil) && !c.wantErr {
+			t.Errorf("unexpected error: %v", e.Err())
+		} else if (e.Err() == nil) && c.wantErr {
+			t.Error("missing error")
+		}
+		if e.Err() == nil && !reflect.DeepEqual(v, c.want) {
+			t.Errorf("unexpected value: %v, expected: %v", v, c.want)
+		}
+	}
+	v, err := NewValue(Action(true))
+	if err != nil || v.Boolean != "1" {
+		t.Error(v, err)
+	}
+}
+
 func TestQuery_Any(t *testing.T) {
 	cases := []struct {
 		v       *Value
@@ -580,3 +615,93 @@ func TestNewValue(t *testing.T) {
 		}
 	}
 }
+
+func TestDecodeXMLMaxDepth(t *testing.T) {
+	nested := func(depth int) []byte {
+		return []byte("<methodResponse><params><param>" +
+			strings.Repeat("<value><array><data>", depth) + "<value><i4>1</i4></value>" +
+			strings.Repeat("</data></array></value>", depth) +
+			"</param></params></methodResponse>")
+	}
+
+	resp := &MethodResponse{}
+	if err := decodeXML(nested(3), resp, 3); err != nil {
+		t.Fatal(err)
+	}
+	if Q(resp.Params.Param[0].Value).Idx(0).Idx(0).Idx(0).Int() != 1 {
+		t.Error(resp.Params.Param[0].Value)
+	}
+
+	err := decodeXML(nested(4), &MethodResponse{}, 3)
+	if err == nil || err.Error() != "Max. nesting depth of 3 exceeded" {
+		t.Error(err)
+	}
+
+	err = decodeXML(nested(DefaultMaxDepth+1), &MethodResponse{}, 0)
+	if err == nil {
+		t.Error("expected error")
+	}
+}
+
+func TestDecodeXMLUnmarshal(t *testing.T) {
+	msgs := []struct {
+		in  string
+		new func() interface{}
+	}{
+		{"<?xml version=\"1.0\"?><methodCall><methodName>event</methodName><params>" +
+			"<param><value>id</value></param>" +
+			"<param><value><struct><member><name>A</name><value><array><data>" +
+			"<value><i4>1</i4></value><value><boolean>1</boolean></value>" +
+			"</data></array></value></member></struct></value></param>" +
+			"</params></methodCall>",
+			func() interface{} { return &MethodCall{} }},
+		{"<methodResponse><fault><value><struct>" +
+			"<member><name>faultCode</name><value><i4>-1</i4></value></member>" +
+			"<member><name>faultString</name><value>failed</value></member>" +
+			"</struct></value></fault></methodResponse>",
+			func() interface{} { return &MethodResponse{} }},
+	}
+	for _, m := range msgs {
+		want := m.new()
+		if err := xml.Unmarshal([]byte(m.in), want); err != nil {
+			t.Fatal(err)
+		}
+		got := m.new()
+		if err := decodeXML([]byte(m.in), got, 0); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("unexpected value: %+v, expected: %+v", got, want)
+		}
+	}
+
+	err := decodeXML([]byte("<methodCall></methodCall>"), &MethodResponse{}, 0)
+	if err == nil {
+		t.Error("expected error")
+	}
+}
+
+func TestDecodeXMLCharset(t *testing.T) {
+	cases := []struct {
+		in   string
+		want string
+	}{
+		{"<?xml version=\"1.0\" encoding=\"ISO-8859-1\"?><value>abc</value>", "abc"},
+		{"<?xml version=\"1.0\" encoding=\"ISO-8859-1\"?><value>\xe4</value>", "ä"},
+		{"<?xml version='1.0' encoding='iso-8859-1'?><value>\xe4</value>", "ä"},
+		{"<?xml version=\"1.0\" encoding=\"UTF-8\"?><value>\xc3\xa4</value>", "ä"},
+		{"<value>abc</value>", "abc"},
+	}
+	for _, c := range cases {
+		v := &Value{}
+		if err := decodeXML([]byte(c.in), v, 0); err != nil {
+			t.Fatal(err)
+		}
+		if v.FlatString != c.want {
+			t.Errorf("%q: %q", c.in, v.FlatString)
+		}
+	}
+	if e := declaredEncoding([]byte(" <?xml version=\"1.0\" encoding=\"ISO-8859-1\"?>")); e != "ISO-8859-1" {
+		t.Error(e)
+	}
+}
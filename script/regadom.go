@@ -1,16 +1,23 @@
 package script
 
 import (
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
 	"sync/atomic"
 	"time"
 )
 
 const (
-	// exploration cycle for the ReGa DOM
+	// default exploration cycle for the ReGa DOM
 	reGaDomExploreCycle = 30 * time.Minute
 
-	// delay between ReGaHss requests while exploring
+	// default delay between ReGaHss requests while exploring
 	reGaHssDelay = 50 * time.Millisecond
+
+	// max. number of queued device refreshes
+	refreshDeviceQueueSize = 100
 )
 
 type model struct {
@@ -18,18 +25,105 @@ type model struct {
 	functions map[string]AspectDef  // key: ISEID
 	devices   map[string]DeviceDef  // key: Address
 	channels  map[string]ChannelDef // key: Address
+	addresses map[string]string     // key: ISEID of channel, value: Address
+}
+
+// clone returns a shallow copy of the model. The maps are copied.
+func (m model) clone() model {
+	n := model{
+		rooms:     make(map[string]AspectDef, len(m.rooms)),
+		functions: make(map[string]AspectDef, len(m.functions)),
+		devices:   make(map[string]DeviceDef, len(m.devices)),
+		channels:  make(map[string]ChannelDef, len(m.channels)),
+		addresses: make(map[string]string, len(m.addresses)),
+	}
+	for k, v := range m.rooms {
+		n.rooms[k] = v
+	}
+	for k, v := range m.functions {
+		n.functions[k] = v
+	}
+	for k, v := range m.devices {
+		n.devices[k] = v
+	}
+	for k, v := range m.channels {
+		n.channels[k] = v
+	}
+	for k, v := range m.addresses {
+		n.addresses[k] = v
+	}
+	return n
+}
+
+// addChannel stores a channel and adds it to the rooms and functions.
+func (m model) addChannel(c ChannelDef) {
+	m.channels[c.Address] = c
+	m.addresses[c.ISEID] = c.Address
+	for _, rid := range c.Rooms {
+		if r, ok := m.rooms[rid]; ok {
+			r.Channels = append(r.Channels, c.Address)
+			m.rooms[rid] = r
+		}
+	}
+	for _, fid := range c.Functions {
+		if f, ok := m.functions[fid]; ok {
+			f.Channels = append(f.Channels, c.Address)
+			m.functions[fid] = f
+		}
+	}
+}
+
+// removeChannels removes all channels of a device, also from the rooms and
+// functions. The channel lists of the rooms and functions are reallocated.
+func (m model) removeChannels(deviceAddr string) {
+	prefix := deviceAddr + ":"
+	for addr, c := range m.channels {
+		if strings.HasPrefix(addr, prefix) {
+			delete(m.channels, addr)
+			delete(m.addresses, c.ISEID)
+		}
+	}
+	filter := func(as map[string]AspectDef) {
+		for id, a := range as {
+			var cs []string
+			for _, c := range a.Channels {
+				if !strings.HasPrefix(c, prefix) {
+					cs = append(cs, c)
+				}
+			}
+			a.Channels = cs
+			as[id] = a
+		}
+	}
+	filter(m.rooms)
+	filter(m.functions)
 }
 
 // ReGaDOM retrieves and caches information (e.g. rooms, functions) from the ReGa DOM of the CCU.
 type ReGaDOM struct {
 	ScriptClient *Client
 
+	// ExploreCycle is the interval between explorations of the ReGa DOM. If
+	// not set, 30 minutes are used.
+	ExploreCycle time.Duration
+
+	// RequestDelay is the delay between ReGaHss requests while exploring. If
+	// not set, 50 milliseconds are used.
+	RequestDelay time.Duration
+
+	// RequestJitter adds a random duration between 0 and RequestJitter to each
+	// delay between ReGaHss requests (optional). This avoids synchronized load
+	// spikes, if multiple applications explore the ReGa DOM.
+	RequestJitter time.Duration
+
 	model atomic.Value
 
 	timer       *time.Timer
 	stopRequest chan struct{}
 	stopped     chan struct{}
 	refresh     chan struct{}
+
+	refreshDevice chan string
 }
 
 // NewReGaDOM creates a new ReGaDOM.
@@ -39,6 +133,8 @@ func NewReGaDOM(scriptClient *Client) *ReGaDOM {
 		stopRequest:  make(chan struct{}),
 		stopped:      make(chan struct{}),
 		refresh:      make(chan struct{}, 1),
+
+		refreshDevice: make(chan string, refreshDeviceQueueSize),
 	}
 	r.model.Store(model{})
 	return r
@@ -61,18 +157,37 @@ func (rd *ReGaDOM) Start() {
 			if rd.explore() {
 				return
 			}
-			rd.timer = time.NewTimer(reGaDomExploreCycle)
-			select {
-			case <-rd.stopRequest:
-				// clean up timer
-				if !rd.timer.Stop() {
-					<-rd.timer.C
+			cycle := rd.ExploreCycle
+			if cycle == 0 {
+				cycle = reGaDomExploreCycle
+			}
+			rd.timer = time.NewTimer(cycle)
+		wait:
+			for {
+				select {
+				case <-rd.stopRequest:
+					// clean up timer
+					if !rd.timer.Stop() {
+						<-rd.timer.C
+					}
+					return
+				case <-rd.timer.C:
+					break wait
+				case <-rd.refresh:
+					// clean up timer
+					if !rd.timer.Stop() {
+						<-rd.timer.C
+					}
+					break wait
+				case addr := <-rd.refreshDevice:
+					if rd.exploreDevice(addr) {
+						// clean up timer
+						if !rd.timer.Stop() {
+							<-rd.timer.C
+						}
+						return
+					}
 				}
-				return
-			case <-rd.timer.C:
-				// loop
-			case <-rd.refresh:
-				// loop
 			}
 		}
 	}()
@@ -93,8 +208,33 @@ func (rd *ReGaDOM) Refresh() {
 	}
 }
 
+// RefreshDevice triggers a reexploration of the channels of a single device
+// (e.g. after a notification about a changed device). If the device is not yet
+// known, the whole ReGa DOM is reexplored. The periodic exploration of the
+// whole ReGa DOM is not affected.
+func (rd *ReGaDOM) RefreshDevice(address string) {
+	select {
+	case rd.refreshDevice <- address:
+	default:
+		scriptLog.Warning("Queue overflow for device refreshes, refreshing whole ReGa DOM")
+		rd.Refresh()
+	}
+}
+
+// requestDelay returns the delay before the next ReGaHss request.
+func (rd *ReGaDOM) requestDelay() time.Duration {
+	d := rd.RequestDelay
+	if d == 0 {
+		d = reGaHssDelay
+	}
+	if rd.RequestJitter > 0 {
+		d += time.Duration(rand.Int63n(int64(rd.RequestJitter) + 1))
+	}
+	return d
+}
+
 func (rd *ReGaDOM) delay() bool {
-	t := time.NewTimer(reGaHssDelay)
+	t := time.NewTimer(rd.requestDelay())
 	select {
 	case <-rd.stopRequest:
 		// clean up timer
@@ -117,6 +257,7 @@ func (rd *ReGaDOM) explore() bool {
 	model.functions = make(map[string]AspectDef)
 	model.devices = make(map[string]DeviceDef)
 	model.channels = make(map[string]ChannelDef)
+	model.addresses = make(map[string]string)
 
 	// retrieve rooms
 	rs, err := rd.ScriptClient.Rooms()
@@ -166,22 +307,7 @@ func (rd *ReGaDOM) explore() bool {
 			return true
 		}
 		for _, c := range cs {
-			// store channel
-			model.channels[c.Address] = c
-			// add to rooms
-			for _, rid := range c.Rooms {
-				if r, ok := model.rooms[rid]; ok {
-					r.Channels = append(r.Channels, c.Address)
-					model.rooms[rid] = r
-				}
-			}
-			// add to function
-			for _, fid := range c.Functions {
-				if f, ok := model.functions[fid]; ok {
-					f.Channels = append(f.Channels, c.Address)
-					model.functions[fid] = f
-				}
-			}
+			model.addChannel(c)
 		}
 	}
 
@@ -191,6 +317,30 @@ func (rd *ReGaDOM) explore() bool {
 	return false
 }
 
+// exploreDevice retrieves the channels of a single device and updates the
+// model. Returns true, if the exploration cycle should be stopped.
+func (rd *ReGaDOM) exploreDevice(addr string) bool {
+	scriptLog.Debug("Exploring device in ReGa DOM: ", addr)
+	d, ok := rd.model.Load().(model).devices[addr]
+	if !ok {
+		// new device, rooms and functions may also have changed
+		return rd.explore()
+	}
+	cs, err := rd.ScriptClient.Channels(d.ISEID)
+	if err != nil {
+		scriptLog.Error("Retrieving of channels from the CCU failed: ", err)
+		return false
+	}
+	// the model may only be modified by the explorer goroutine
+	m := rd.model.Load().(model).clone()
+	m.removeChannels(addr)
+	for _, c := range cs {
+		m.addChannel(c)
+	}
+	rd.model.Store(m)
+	return rd.delay()
+}
+
 // Room returns info about a room.
 func (rd *ReGaDOM) Room(iseID string) *AspectDef {
 	tm := rd.model.Load()
@@ -248,3 +398,84 @@ func (rd *ReGaDOM) Channel(addr string) *ChannelDef {
 	}
 	return &c
 }
+
+// ISEIDForAddress returns the ISE ID of a channel.
+func (rd *ReGaDOM) ISEIDForAddress(address string) (string, bool) {
+	tm := rd.model.Load()
+	model := tm.(model)
+	c, ok := model.channels[address]
+	if !ok {
+		return "", false
+	}
+	return c.ISEID, true
+}
+
+// AddressForISEID returns the address of a channel.
+func (rd *ReGaDOM) AddressForISEID(iseID string) (string, bool) {
+	tm := rd.model.Load()
+	model := tm.(model)
+	addr, ok := model.addresses[iseID]
+	return addr, ok
+}
+
+// DeviceView combines a device with its channels and the names of the assigned
+// rooms and functions.
+type DeviceView struct {
+	Device   DeviceDef
+	Channels []ChannelView // sorted by channel index
+}
+
+// ChannelView combines a channel with the names of the assigned rooms and
+// functions.
+type ChannelView struct {
+	Channel   ChannelDef
+	Rooms     []string // display names
+	Functions []string // display names
+}
+
+// DeviceView returns a device together with its channels. The rooms and
+// functions of the channels are resolved to display names.
+func (rd *ReGaDOM) DeviceView(address string) (*DeviceView, bool) {
+	tm := rd.model.Load()
+	model := tm.(model)
+	d, ok := model.devices[address]
+	if !ok {
+		return nil, false
+	}
+	dv := &DeviceView{Device: d}
+	prefix := address + ":"
+	for addr, c := range model.channels {
+		if !strings.HasPrefix(addr, prefix) {
+			continue
+		}
+		cv := ChannelView{Channel: c}
+		for _, rid := range c.Rooms {
+			if r, ok := model.rooms[rid]; ok {
+				cv.Rooms = append(cv.Rooms, r.DisplayName)
+			}
+		}
+		for _, fid := range c.Functions {
+			if f, ok := model.functions[fid]; ok {
+				cv.Functions = append(cv.Functions, f.DisplayName)
+			}
+		}
+		dv.Channels = append(dv.Channels, cv)
+	}
+	sort.Slice(dv.Channels, func(i, j int) bool {
+		return channelIndex(dv.Channels[i].Channel.Address) < channelIndex(dv.Channels[j].Channel.Address)
+	})
+	return dv, true
+}
+
+// channelIndex returns the index of a channel address (e.g. 1 for ABC:1).
+func channelIndex(address string) int {
+	i := strings.LastIndex(address, ":")
+	if i < 0 {
+		return -1
+	}
+	idx, err := strconv.Atoi(address[i+1:])
+	if err != nil {
+		return -1
+	}
+	return idx
+}
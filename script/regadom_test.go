@@ -0,0 +1,113 @@
+package script
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestReGaDOMRequestDelay(t *testing.T) {
+	rd := NewReGaDOM(nil)
+	if d := rd.requestDelay(); d != reGaHssDelay {
+		t.Error(d)
+	}
+	rd.RequestDelay = 100 * time.Millisecond
+	rd.RequestJitter = 20 * time.Millisecond
+	for i := 0; i < 100; i++ {
+		d := rd.requestDelay()
+		if d < 100*time.Millisecond || d > 120*time.Millisecond {
+			t.Fatal(d)
+		}
+	}
+}
+
+func TestModelUpdateDevice(t *testing.T) {
+	m := model{
+		rooms:     map[string]AspectDef{"1": {ISEID: "1"}},
+		functions: map[string]AspectDef{"2": {ISEID: "2"}},
+		devices:   map[string]DeviceDef{"A": {ISEID: "3", Address: "A"}},
+		channels:  make(map[string]ChannelDef),
+		addresses: make(map[string]string),
+	}
+	m.addChannel(ChannelDef{ISEID: "4", Address: "A:1", Rooms: []string{"1"}, Functions: []string{"2"}})
+	m.addChannel(ChannelDef{ISEID: "5", Address: "AB:1", Rooms: []string{"1"}})
+
+	n := m.clone()
+	n.removeChannels("A")
+	n.addChannel(ChannelDef{ISEID: "6", Address: "A:2", Functions: []string{"2"}})
+
+	// old model is unchanged
+	if len(m.channels) != 2 || !reflect.DeepEqual(m.rooms["1"].Channels, []string{"A:1", "AB:1"}) ||
+		!reflect.DeepEqual(m.functions["2"].Channels, []string{"A:1"}) {
+		t.Error(m)
+	}
+	if m.addresses["4"] != "A:1" {
+		t.Error(m.addresses)
+	}
+	// new model
+	if _, ok := n.addresses["4"]; ok || n.addresses["6"] != "A:2" {
+		t.Error(n.addresses)
+	}
+	if _, ok := n.channels["A:1"]; ok || len(n.channels) != 2 {
+		t.Error(n.channels)
+	}
+	if !reflect.DeepEqual(n.rooms["1"].Channels, []string{"AB:1"}) ||
+		!reflect.DeepEqual(n.functions["2"].Channels, []string{"A:2"}) {
+		t.Error(n)
+	}
+}
+
+func TestReGaDOMISEID(t *testing.T) {
+	rd := NewReGaDOM(nil)
+	if _, ok := rd.ISEIDForAddress("A:1"); ok {
+		t.Error("unexpected channel")
+	}
+	m := model{
+		channels:  make(map[string]ChannelDef),
+		addresses: make(map[string]string),
+	}
+	m.addChannel(ChannelDef{ISEID: "4", Address: "A:1"})
+	rd.model.Store(m)
+	if id, ok := rd.ISEIDForAddress("A:1"); !ok || id != "4" {
+		t.Error(id, ok)
+	}
+	if addr, ok := rd.AddressForISEID("4"); !ok || addr != "A:1" {
+		t.Error(addr, ok)
+	}
+	if _, ok := rd.AddressForISEID("5"); ok {
+		t.Error("unexpected channel")
+	}
+}
+
+func TestReGaDOMDeviceView(t *testing.T) {
+	rd := NewReGaDOM(nil)
+	m := model{
+		rooms:     map[string]AspectDef{"1": {ISEID: "1", DisplayName: "Kitchen"}},
+		functions: map[string]AspectDef{"2": {ISEID: "2", DisplayName: "Light"}},
+		devices:   map[string]DeviceDef{"A": {ISEID: "3", Address: "A"}},
+		channels:  make(map[string]ChannelDef),
+		addresses: make(map[string]string),
+	}
+	m.addChannel(ChannelDef{ISEID: "4", Address: "A:10", Rooms: []string{"1"}, Functions: []string{"2", "9"}})
+	m.addChannel(ChannelDef{ISEID: "5", Address: "A:2"})
+	m.addChannel(ChannelDef{ISEID: "6", Address: "AB:1"})
+	rd.model.Store(m)
+
+	if _, ok := rd.DeviceView("B"); ok {
+		t.Error("unexpected device")
+	}
+	dv, ok := rd.DeviceView("A")
+	if !ok {
+		t.Fatal("device not found")
+	}
+	if dv.Device.ISEID != "3" || len(dv.Channels) != 2 {
+		t.Fatal(dv)
+	}
+	if dv.Channels[0].Channel.Address != "A:2" || dv.Channels[1].Channel.Address != "A:10" {
+		t.Error(dv.Channels)
+	}
+	cv := dv.Channels[1]
+	if !reflect.DeepEqual(cv.Rooms, []string{"Kitchen"}) || !reflect.DeepEqual(cv.Functions, []string{"Light"}) {
+		t.Error(cv)
+	}
+}
@@ -3,9 +3,11 @@ package script
 import (
 	"bufio"
 	"bytes"
+	"context"
 	"errors"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"net/http"
 	"net/url"
 	"sort"
@@ -14,15 +16,25 @@ import (
 	"text/template"
 	"time"
 
+	"github.com/mdzio/go-hmccu/internal/enc"
+	"github.com/mdzio/go-hmccu/internal/redact"
 	"github.com/mdzio/go-logging"
-
-	"golang.org/x/text/encoding/charmap"
 )
 
+// ErrTruncatedResponse is returned, if the response of the ReGaHss is not
+// complete (e.g. ReGaHss is overloaded). The whole script should be retried.
+var ErrTruncatedResponse = errors.New("Truncated response")
+
 const (
 	// max. size of a valid response, if not specified: 10 MB
-	// (max. size of a single response line is always 64 KB)
 	scriptRespLimit = 10 * 1024 * 1024
+
+	// max. size of a single response line, if not specified: 64 KB
+	scriptLineLimit = bufio.MaxScanTokenSize
+
+	// default port and path of the ReGaHss script endpoint
+	scriptPort = 8181
+	scriptPath = "/tclrega.exe"
 )
 
 const enumAspectsScript = `! Enumerating aspects
@@ -65,6 +77,69 @@ if (dobj && dobj.Type()==OT_DEVICE) {
 	WriteLine("Object not found or has wrong type");
 }`
 
+const enumFavoritesScript = `! Enumerating favorites
+object eobj = dom.GetObject(ID_FAVORITES);
+if (eobj) {
+	WriteLine("OK");
+	string id;
+	foreach (id, eobj.EnumIDs()) {
+		object obj = dom.GetObject(id);
+		WriteLine(obj.ID() # "\t" # obj.Name());
+	}
+} else {
+	WriteLine("Object not found");
+}`
+
+const enumFavoriteChannelsScript = `! Enumerating channels of favorite
+object fobj = dom.GetObject({{ . }});
+if (fobj) {
+	WriteLine("OK");
+	string id;
+	foreach (id, fobj.EnumIDs()) {
+		object obj = dom.GetObject(id);
+		if (obj && obj.IsTypeOf(OT_CHANNEL)) {
+			WriteLine(obj.Address());
+		}
+	}
+} else {
+	WriteLine("Object not found");
+}`
+
+// readDeviceStatusScript expects as dot parameter the quoted device address.
+const readDeviceStatusScript = `! Reading device status
+object dev = null;
+string id; foreach(id, dom.GetObject(ID_DEVICES).EnumIDs()) {
+	object obj = dom.GetObject(id);
+	if (obj.Address() == {{ . }}) { dev = obj; }
+}
+if (dev) {
+	boolean unreach = false;
+	boolean lowbat = false;
+	string cid; foreach(cid, dev.Channels()) {
+		object cobj = dom.GetObject(cid);
+		if (cobj.Address() == (dev.Address() # ":0")) {
+			string did; foreach(did, cobj.DPs().EnumUsedIDs()) {
+				object dp = dom.GetObject(did);
+				string hssid = dp.HssType();
+				if (hssid == "UNREACH") { unreach = dp.Value(); }
+				if ((hssid == "LOWBAT") || (hssid == "LOW_BAT")) { lowbat = dp.Value(); }
+			}
+		}
+	}
+	WriteLine("OK");
+	WriteLine(unreach);
+	WriteLine(lowbat);
+} else {
+	WriteLine("Device not found");
+}`
+
+// readCCUInfoScript outputs the ReGaHss version.
+const readCCUInfoScript = `! Reading CCU info
+WriteLine("OK");
+WriteLine(dom.BuildLabel());`
+
+// enumProgramsScript expects as dot parameter a bool, which enables the output
+// of the last execution times.
 const enumProgramsScript = `! Enumerating programs
 object eobj = dom.GetObject(ID_PROGRAMS);
 if (eobj) {
@@ -72,7 +147,7 @@ if (eobj) {
 	string id;
 	foreach (id, eobj.EnumIDs()) {
 		object obj = dom.GetObject(id);
-		WriteLine(obj.ID() # "\t" # obj.Name() # "\t" # obj.PrgInfo() # "\t" # obj.Active() # "\t" # obj.Visible());
+		WriteLine(obj.ID() # "\t" # obj.Name() # "\t" # obj.PrgInfo() # "\t" # obj.Active() # "\t" # obj.Visible(){{ if . }} # "\t" # obj.ProgramLastExecuteTime(){{ end }});
 	}
 } else {
 	WriteLine("Object not found");
@@ -96,6 +171,44 @@ if (pobj && pobj.Type()==OT_PROGRAM) {
 	WriteLine("Object not found or has wrong type");
 }`
 
+// execTimesScript outputs the last execution time of a program and the
+// current time of the CCU. Afterwards the program is executed, if requested.
+const execTimesScript = `! Reading execution times of program
+object pobj = dom.GetObject({{ .ISEID }});
+if (pobj && pobj.Type()==OT_PROGRAM) {
+	WriteLine("OK");
+	WriteLine(pobj.ProgramLastExecuteTime());
+	WriteLine(system.Date("%Y-%m-%d %H:%M:%S"));{{ if .Exec }}
+	pobj.ProgramExecute();{{ end }}
+} else {
+	WriteLine("Object not found or has wrong type");
+}`
+
+// readProgramSourceScript outputs the HM scripts of the script actions
+// (destinations with a string parameter) of a program. The else-if and else
+// branches are chained as sub rules. Special characters are returned percent
+// encoded, one script per line.
+const readProgramSourceScript = `! Reading program source
+object pobj = dom.GetObject({{ . }});
+if (pobj && pobj.Type()==OT_PROGRAM) {
+	WriteLine("OK");
+	object rule = pobj.Rule();
+	while (rule) {
+		object dests = rule.RuleDestination();
+		integer i = 0;
+		while (i < dests.DestinationCount()) {
+			object dest = dests.GetDestination(i);
+			if (dest.DestinationParam() == ivtString) {
+				WriteLine(dest.DestinationValue().ToString().Replace("%", "%25").Replace("\r", "%0D").Replace("\n", "%0A"));
+			}
+			i = i + 1;
+		}
+		rule = rule.RuleSubRule();
+	}
+} else {
+	WriteLine("Object not found or has wrong type");
+}`
+
 const enumSysVarsScript = `! Enumerating system variables
 string id; foreach(id, dom.GetObject(ID_SYSTEM_VARIABLES).EnumIDs()) {
 	var sv=dom.GetObject(id);
@@ -130,6 +243,43 @@ string id; foreach(id,"{{ . }}") {
 	}
 }`
 
+// readChannelValuesScript expects as dot parameter a map with the quoted
+// channel address (Address) and a tab separated string of value keys (Keys).
+const readChannelValuesScript = `! Reading values of channel
+object chn = null;
+string id; foreach(id, dom.GetObject(ID_CHANNELS).EnumIDs()) {
+	object obj = dom.GetObject(id);
+	if (obj.Address() == {{ .Address }}) { chn = obj; }
+}
+if (chn) {
+	WriteLine("OK");
+	string key; foreach(key, "{{ .Keys }}") {
+		object dp = null;
+		string did; foreach(did, chn.DPs().EnumUsedIDs()) {
+			object obj = dom.GetObject(did);
+			if (obj.HssType() == key) { dp = obj; }
+		}
+		if (dp) {
+			var vt=dp.ValueType(); var st=dp.ValueSubType();
+			var outvt="";
+			if (vt==ivtBinary) { outvt="BOOL"; }
+			if ((vt==ivtBinary) && (st==istAction)) { outvt="ACTION"; }
+			if (vt==ivtInteger) { outvt="INTEGER"; }
+			if ((vt==ivtInteger) && (st==istEnum)) { outvt="ENUM"; }
+			if (vt==ivtFloat) { outvt="FLOAT"; }
+			if (vt==ivtString) { outvt="STRING"; }
+			WriteLine("OK");
+			WriteLine(outvt);
+			WriteLine(dp.Timestamp().ToInteger());
+			WriteLine(dp.Value().ToString().Replace("%", "%25").Replace("\n", "%0A"));
+		} else {
+			WriteLine("Not found");
+		}
+	}
+} else {
+	WriteLine("Channel not found");
+}`
+
 const writeValueScript = `! Writing value
 var sv=dom.GetObject({{ .ISEID }});
 if (sv) {
@@ -143,20 +293,78 @@ if (sv) {
 	WriteLine("Not found");
 }`
 
+// saveSysVarScript creates (ISEID is empty) or updates a system variable. All
+// strings must be quoted.
+const saveSysVarScript = `! Saving system variable
+object sv = null;
+{{ if .ISEID }}sv = dom.GetObject({{ .ISEID }});
+if (sv && !sv.IsTypeOf(OT_VARDP) && !sv.IsTypeOf(OT_ALARMDP)) { sv = null; }
+{{ else }}sv = dom.CreateObject({{ .ObjType }});
+if (sv) {
+	sv.Name({{ .Name }});
+	sv.ValueType({{ .ValueType }});
+	sv.ValueSubType({{ .ValueSubType }});
+	dom.GetObject(ID_SYSTEM_VARIABLES).Add(sv.ID());
+}
+{{ end }}if (sv) {
+	sv.DPInfo({{ .Description }});
+	sv.ValueUnit({{ .Unit }});
+{{ if .Minimum }}	sv.ValueMin({{ .Minimum }});
+{{ end }}{{ if .Maximum }}	sv.ValueMax({{ .Maximum }});
+{{ end }}{{ if .ValueName0 }}	sv.ValueName0({{ .ValueName0 }});
+{{ end }}{{ if .ValueName1 }}	sv.ValueName1({{ .ValueName1 }});
+{{ end }}{{ if .ValueList }}	sv.ValueList({{ .ValueList }});
+{{ end }}	dom.RTUpdate(false);
+	WriteLine("OK");
+	WriteLine(sv.ID());
+} else {
+	WriteLine("Object not found, has wrong type or can not be created");
+}`
+
+const deleteSysVarScript = `! Deleting system variable
+object sv = dom.GetObject({{ . }});
+if (sv && (sv.IsTypeOf(OT_VARDP) || sv.IsTypeOf(OT_ALARMDP))) {
+	dom.GetObject(ID_SYSTEM_VARIABLES).Remove(sv.ID());
+	dom.DeleteObject(sv.ID());
+	dom.RTUpdate(false);
+	WriteLine("OK");
+} else {
+	WriteLine("Object not found or has wrong type");
+}`
+
 var (
 	scriptLog = logging.Get("script-client")
 
-	enumAspectsTempl  = template.Must(template.New("enumAspects").Parse(enumAspectsScript))
-	enumDevicesTempl  = template.Must(template.New("enumDevices").Parse(enumDevicesScript))
-	enumChannelsTempl = template.Must(template.New("enumChannels").Parse(enumChannelsScript))
-	enumProgramsTempl = template.Must(template.New("enumPrograms").Parse(enumProgramsScript))
-	execProgramTempl  = template.Must(template.New("execProgram").Parse(execProgramScript))
-	readExecTimeTempl = template.Must(template.New("readExecTime").Parse(readExecTimeScript))
-	enumSysVarsTempl  = template.Must(template.New("enumSysVars").Parse(enumSysVarsScript))
-	readValuesTempl   = template.Must(template.New("readValues").Parse(readValuesScript))
-	writeValueTempl   = template.Must(template.New("writeValue").Parse(writeValueScript))
+	enumAspectsTempl       = template.Must(template.New("enumAspects").Parse(enumAspectsScript))
+	enumDevicesTempl       = template.Must(template.New("enumDevices").Parse(enumDevicesScript))
+	enumChannelsTempl      = template.Must(template.New("enumChannels").Parse(enumChannelsScript))
+	enumFavoritesTempl     = template.Must(template.New("enumFavorites").Parse(enumFavoritesScript))
+	enumFavChannelsTempl   = template.Must(template.New("enumFavoriteChannels").Parse(enumFavoriteChannelsScript))
+	readDevStatusTempl     = template.Must(template.New("readDeviceStatus").Parse(readDeviceStatusScript))
+	readCCUInfoTempl       = template.Must(template.New("readCCUInfo").Parse(readCCUInfoScript))
+	enumProgramsTempl      = template.Must(template.New("enumPrograms").Parse(enumProgramsScript))
+	execProgramTempl       = template.Must(template.New("execProgram").Parse(execProgramScript))
+	readExecTimeTempl      = template.Must(template.New("readExecTime").Parse(readExecTimeScript))
+	execTimesTempl         = template.Must(template.New("execTimes").Parse(execTimesScript))
+	readPrgSourceTempl     = template.Must(template.New("readProgramSource").Parse(readProgramSourceScript))
+	enumSysVarsTempl       = template.Must(template.New("enumSysVars").Parse(enumSysVarsScript))
+	readValuesTempl        = template.Must(template.New("readValues").Parse(readValuesScript))
+	readChannelValuesTempl = template.Must(template.New("readChannelValues").Parse(readChannelValuesScript))
+	writeValueTempl        = template.Must(template.New("writeValue").Parse(writeValueScript))
+	saveSysVarTempl        = template.Must(template.New("saveSysVar").Parse(saveSysVarScript))
+	deleteSysVarTempl      = template.Must(template.New("deleteSysVar").Parse(deleteSysVarScript))
 )
 
+// sysVarTypes maps the type of a system variable to the ReGaHss object type,
+// value type and value sub type.
+var sysVarTypes = map[string][3]string{
+	"BOOL":   {"OT_VARDP", "ivtBinary", "istBool"},
+	"ALARM":  {"OT_ALARMDP", "ivtBinary", "istAlarm"},
+	"ENUM":   {"OT_VARDP", "ivtInteger", "istEnum"},
+	"FLOAT":  {"OT_VARDP", "ivtFloat", "istGeneric"},
+	"STRING": {"OT_VARDP", "ivtString", "istChar8859"},
+}
+
 // SysVarDef contains meta data about a ReGaHss system variable.
 type SysVarDef struct {
 	ISEID       string
@@ -321,6 +529,12 @@ type ChannelDef struct {
 	Functions   []string // ISEID's
 }
 
+// FavoriteDef describes a favorite list.
+type FavoriteDef struct {
+	ISEID       string
+	DisplayName string
+}
+
 // ProgramDef describes a program in the ReGaHss.
 type ProgramDef struct {
 	ISEID       string
@@ -335,30 +549,44 @@ type Client struct {
 	// IP address or network name of the CCU
 	Addr string
 
+	// Port of the ReGaHss script endpoint (default: 8181)
+	Port int
+
+	// Path of the ReGaHss script endpoint (default: /tclrega.exe)
+	Path string
+
 	// Limits the size of a valid response
 	RespLimit int64
+
+	// Limits the size of a single response line (e.g. the value of a STRING
+	// system variable)
+	LineLimit int
+
+	// Number of decimal places for writing FLOAT values. If 0 (default), the
+	// shortest representation is used, which reads back to the exact value.
+	FloatPrecision int
 }
 
 // Execute remotely executes a HM script on the CCU.
 func (sc *Client) Execute(script string) ([]string, error) {
-	scriptLog.Trace("Executing HM script: ", script)
+	if scriptLog.TraceEnabled() {
+		scriptLog.Trace("Executing HM script: ", redact.String(script))
+	}
 
 	// encode request body with ISO8859-1
-	var reqBuf bytes.Buffer
-	reqWriter := charmap.ISO8859_1.NewEncoder().Writer(&reqBuf)
-	reqWriter.Write([]byte(script))
+	reqBody := enc.ToISO8859_1([]byte(script))
 
 	// http post
-	addr := "http://" + sc.Addr + ":8181/tclrega.exe"
-	httpResp, err := http.Post(addr, "", bytes.NewReader(reqBuf.Bytes()))
+	addr := sc.url()
+	httpResp, err := http.Post(addr, "", bytes.NewReader(reqBody))
 	if err != nil {
-		return nil, fmt.Errorf("HTTP request failed on %s: %v", addr, err)
+		return nil, fmt.Errorf("HTTP request failed on %s: %v", redact.String(addr), err)
 	}
 	defer httpResp.Body.Close()
 
 	// check status
 	if httpResp.StatusCode < 200 || httpResp.StatusCode >= 299 {
-		return nil, fmt.Errorf("HTTP request failed on %s with code: %s", addr, httpResp.Status)
+		return nil, fmt.Errorf("HTTP request failed on %s with code: %s", redact.String(addr), httpResp.Status)
 	}
 
 	// limit response size
@@ -369,22 +597,70 @@ func (sc *Client) Execute(script string) ([]string, error) {
 	limitReader := io.LimitReader(httpResp.Body, limit)
 
 	// decode response body with ISO8859-1
-	decReader := charmap.ISO8859_1.NewDecoder().Reader(limitReader)
+	respBody, err := ioutil.ReadAll(limitReader)
+	if err != nil {
+		return nil, fmt.Errorf("Reading of response failed from %s: %v", redact.String(addr), err)
+	}
+	respUTF8, err := enc.FromISO8859_1(respBody)
+	if err != nil {
+		return nil, fmt.Errorf("Decoding of response failed from %s: %v", redact.String(addr), err)
+	}
+	decReader := strings.NewReader(respUTF8)
 
 	// read response and split lines
-	scn := bufio.NewScanner(decReader)
+	lineLimit := sc.LineLimit
+	if lineLimit == 0 {
+		lineLimit = scriptLineLimit
+	}
+	resp, err := readResponse(decReader, lineLimit)
+	if err != nil {
+		return nil, fmt.Errorf("Parsing of response failed from %s: %w", redact.String(addr), err)
+	}
+	if scriptLog.TraceEnabled() {
+		scriptLog.Trace("HM script response: ", redact.String(strings.Join(resp, "\\n")))
+	}
+	return resp, nil
+}
+
+// url returns the URL of the ReGaHss script endpoint.
+func (sc *Client) url() string {
+	port := sc.Port
+	if port == 0 {
+		port = scriptPort
+	}
+	path := sc.Path
+	if path == "" {
+		path = scriptPath
+	}
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+	return "http://" + sc.Addr + ":" + strconv.Itoa(port) + path
+}
+
+// readResponse reads the lines of a ReGaHss response. The trailing XML
+// document with the script variables is removed. ErrTruncatedResponse is
+// returned, if the XML document is missing. lineLimit is the max. size of a
+// line.
+func readResponse(r io.Reader, lineLimit int) ([]string, error) {
+	scn := bufio.NewScanner(r)
+	scn.Buffer(nil, lineLimit)
 	var resp []string
+	complete := false
 	for scn.Scan() {
 		l := scn.Text()
+		if strings.Contains(l, "<xml><exec>") {
+			complete = true
+		}
 		if !strings.HasPrefix(l, "<xml><exec>") {
 			resp = append(resp, l)
 		}
 	}
 	if scn.Err() != nil {
-		return nil, fmt.Errorf("Parsing of response failed from %s: %v", addr, scn.Err())
+		return nil, scn.Err()
 	}
-	if scriptLog.TraceEnabled() {
-		scriptLog.Trace("HM script response: ", strings.Join(resp, "\\n"))
+	if !complete {
+		return nil, ErrTruncatedResponse
 	}
 	return resp, nil
 }
@@ -594,7 +870,7 @@ func (sc *Client) ReadValues(objs []ValObjDef) ([]Value, error) {
 	// execute script
 	resp, err := sc.ExecuteTempl(readValuesTempl, ids)
 	if err != nil {
-		return nil, fmt.Errorf("Reading object values failed: %v", err)
+		return nil, fmt.Errorf("Reading object values failed: %w", err)
 	}
 
 	// parse result
@@ -603,7 +879,7 @@ func (sc *Client) ReadValues(objs []ValObjDef) ([]Value, error) {
 	for idx := range objs {
 		// unexpected end of response?
 		if line >= len(resp) || (resp[line] == "OK" && line+2 >= len(resp)) {
-			return nil, errors.New("Reading object values failed: Unexpected end of response")
+			return nil, fmt.Errorf("Reading object values failed: Unexpected end of response: %w", ErrTruncatedResponse)
 		}
 
 		// HM script error?
@@ -626,60 +902,161 @@ func (sc *Client) ReadValues(objs []ValObjDef) ([]Value, error) {
 		}
 
 		// parse value
-		strval, err := url.PathUnescape(resp[line+2])
+		value, uncertain, err := parseValue(objs[idx].Type, resp[line+2])
 		if err != nil {
-			return nil, fmt.Errorf("Reading value of %s failed: Invalid percent encoding: %s", objs[idx].ISEID, strval)
-		}
-		switch objs[idx].Type {
-		case "BOOL":
-			fallthrough
-		case "ALARM":
-			fallthrough
-		case "ACTION":
-			if strval == "" {
-				result[idx].Value = false
-				result[idx].Uncertain = true
-			} else {
-				value, err := strconv.ParseBool(strval)
-				if err != nil {
-					return nil, fmt.Errorf("Reading value of %s failed: Invalid BOOL/ALARM/ACTION value: %s", objs[idx].ISEID, strval)
-				}
-				result[idx].Value = value
-			}
+			return nil, fmt.Errorf("Reading value of %s failed: %v", objs[idx].ISEID, err)
+		}
+		result[idx].Value = value
+		result[idx].Uncertain = result[idx].Uncertain || uncertain
+		line += 3
+	}
+	return result, nil
+}
 
-		case "INTEGER":
-			fallthrough
-		case "ENUM":
-			if strval == "" {
-				result[idx].Value = 0
-				result[idx].Uncertain = true
-			} else {
-				tmp, err := strconv.ParseInt(strval, 10, 32)
-				if err != nil {
-					return nil, fmt.Errorf("Reading value of %s failed: Invalid INTEGER/ENUM value: %s", objs[idx].ISEID, strval)
-				}
-				result[idx].Value = int(tmp)
-			}
+// parseValue decodes a percent encoded value from a HM script response. An
+// empty value is replaced by the zero value of the type and flagged as
+// uncertain.
+func parseValue(typ, encval string) (value interface{}, uncertain bool, err error) {
+	strval, err := url.PathUnescape(encval)
+	if err != nil {
+		return nil, false, fmt.Errorf("Invalid percent encoding: %s", encval)
+	}
+	switch typ {
+	case "BOOL":
+		fallthrough
+	case "ALARM":
+		fallthrough
+	case "ACTION":
+		if strval == "" {
+			return false, true, nil
+		}
+		value, err := strconv.ParseBool(strval)
+		if err != nil {
+			return nil, false, fmt.Errorf("Invalid BOOL/ALARM/ACTION value: %s", strval)
+		}
+		return value, false, nil
 
-		case "FLOAT":
-			if strval == "" {
-				result[idx].Value = 0.0
-				result[idx].Uncertain = true
-			} else {
-				value, err := strconv.ParseFloat(strval, 64)
-				if err != nil {
-					return nil, fmt.Errorf("Reading value of %s failed: Invalid FLOAT value: %s", objs[idx].ISEID, strval)
-				}
-				result[idx].Value = value
-			}
+	case "INTEGER":
+		fallthrough
+	case "ENUM":
+		if strval == "" {
+			return 0, true, nil
+		}
+		tmp, err := strconv.ParseInt(strval, 10, 32)
+		if err != nil {
+			return nil, false, fmt.Errorf("Invalid INTEGER/ENUM value: %s", strval)
+		}
+		return int(tmp), false, nil
+
+	case "FLOAT":
+		if strval == "" {
+			return 0.0, true, nil
+		}
+		value, err := strconv.ParseFloat(strval, 64)
+		if err != nil {
+			return nil, false, fmt.Errorf("Invalid FLOAT value: %s", strval)
+		}
+		return value, false, nil
+
+	case "STRING":
+		return strval, false, nil
 
-		case "STRING":
-			result[idx].Value = strval
+	default:
+		return nil, false, fmt.Errorf("Unsupported type: %s", typ)
+	}
+}
+
+// ReadChannelValues reads the values of the specified data points (e.g.
+// STATE, LEVEL) of a channel. The channel is looked up by its address, so
+// the ISE IDs of the data points need not to be known. The data type of each
+// data point is retrieved from the CCU. If a data point is not found, Value.Err
+// is set.
+func (sc *Client) ReadChannelValues(channelAddress string, valueKeys []string) (map[string]Value, error) {
+	scriptLog.Debugf("Reading values %v of channel %s", valueKeys, channelAddress)
+
+	// execute script
+	resp, err := sc.ExecuteTempl(readChannelValuesTempl, map[string]interface{}{
+		"Address": strconv.Quote(channelAddress),
+		"Keys":    strings.Join(valueKeys, "\t"),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Reading values of channel %s failed: %w", channelAddress, err)
+	}
+	if len(resp) == 0 {
+		return nil, fmt.Errorf("Reading values of channel %s failed: Empty response: %w", channelAddress, ErrTruncatedResponse)
+	}
+	if resp[0] != "OK" {
+		return nil, fmt.Errorf("Reading values of channel %s failed: HM script signals error: %s", channelAddress, resp[0])
+	}
 
-		default:
-			return nil, fmt.Errorf("Reading value of %s failed: Unsupported type: %s", objs[idx].ISEID, objs[idx].Type)
+	// parse result
+	result := make(map[string]Value, len(valueKeys))
+	line := 1
+	for _, key := range valueKeys {
+		// unexpected end of response?
+		if line >= len(resp) || (resp[line] == "OK" && line+3 >= len(resp)) {
+			return nil, fmt.Errorf("Reading values of channel %s failed: Unexpected end of response: %w", channelAddress, ErrTruncatedResponse)
+		}
+
+		// HM script error?
+		if resp[line] != "OK" {
+			result[key] = Value{Err: errors.New(resp[line])}
+			line++
+			continue
+		}
+
+		// parse timestamp
+		var v Value
+		sec, err := strconv.ParseInt(resp[line+2], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("Reading value %s of channel %s failed: Invalid timestamp: %s", key, channelAddress, resp[line+2])
+		}
+		v.Timestamp = time.Unix(sec, 0)
+		v.Uncertain = sec == 0
+
+		// parse value
+		value, uncertain, err := parseValue(resp[line+1], resp[line+3])
+		if err != nil {
+			v.Err = fmt.Errorf("Reading value %s of channel %s failed: %v", key, channelAddress, err)
+		} else {
+			v.Value = value
+			v.Uncertain = v.Uncertain || uncertain
+		}
+		result[key] = v
+		line += 4
+	}
+	return result, nil
+}
+
+// ReadValuesValidated reads values of multiple ReGaDOM objects like
+// ReadValues, but checks beforehand the declared types against the system
+// variable definitions retrieved from the CCU (see SystemVariables). If the
+// types do not match, the object is not read and Value.Err is set. Objects,
+// which are not found in defs, are not checked.
+func (sc *Client) ReadValuesValidated(objs []ValObjDef, defs SysVarDefs) ([]Value, error) {
+	types := make(map[string]string, len(defs))
+	for _, d := range defs {
+		types[d.ISEID] = d.Type
+	}
+	result := make([]Value, len(objs))
+	var valid []ValObjDef
+	var idxs []int
+	for idx, obj := range objs {
+		if t, ok := types[obj.ISEID]; ok && t != obj.Type {
+			result[idx].Err = fmt.Errorf("Type mismatch for object %s: declared %s, CCU %s", obj.ISEID, obj.Type, t)
+			continue
+		}
+		valid = append(valid, obj)
+		idxs = append(idxs, idx)
+	}
+	if len(valid) > 0 {
+		vs, err := sc.ReadValues(valid)
+		if err != nil {
+			return nil, err
+		}
+		for i, v := range vs {
+			result[idxs[i]] = v
 		}
-		line += 3
 	}
 	return result, nil
 }
@@ -689,8 +1066,30 @@ func (sc *Client) WriteValue(obj ValObjDef, value interface{}) error {
 	scriptLog.Debugf("Writing value %v to object %s", value, obj.ISEID)
 
 	// convert value
-	var strval string
-	switch obj.Type {
+	strval, err := sc.formatValue(obj.Type, value)
+	if err != nil {
+		return fmt.Errorf("Writing of object %s failed: %v", obj.ISEID, err)
+	}
+
+	// execute script
+	resp, err := sc.ExecuteTempl(writeValueTempl, map[string]interface{}{"ISEID": obj.ISEID, "Value": strval})
+	if err != nil {
+		return fmt.Errorf("Writing of object %s failed: %v", obj.ISEID, err)
+	}
+	if len(resp) != 1 {
+		return fmt.Errorf("Writing of object %s failed: Expected one response line", obj.ISEID)
+	}
+	if resp[0] != "OK" {
+		return fmt.Errorf("Writing of object %s failed: HM script signals error: %s", obj.ISEID, resp[0])
+	}
+	return nil
+}
+
+// formatValue converts a value to a HM script literal of the specified type.
+// ReGaHss silently ignores true/false for ALARM and ACTION objects, therefore
+// 1/0 is used for them.
+func (sc *Client) formatValue(typ string, value interface{}) (string, error) {
+	switch typ {
 	case "BOOL":
 		fallthrough
 	case "ALARM":
@@ -698,50 +1097,47 @@ func (sc *Client) WriteValue(obj ValObjDef, value interface{}) error {
 	case "ACTION":
 		b, ok := value.(bool)
 		if !ok {
-			return fmt.Errorf("Writing of object %s failed: Invalid type for BOOL/ALARM/ACTION: %#v", obj.ISEID, value)
+			return "", fmt.Errorf("Invalid type for BOOL/ALARM/ACTION: %#v", value)
+		}
+		if typ == "BOOL" {
+			return fmt.Sprint(b), nil
 		}
-		strval = fmt.Sprint(b)
+		if b {
+			return "1", nil
+		}
+		return "0", nil
 
 	case "INTEGER":
 		fallthrough
 	case "ENUM":
 		i, ok := value.(int)
 		if !ok {
-			return fmt.Errorf("Writing of object %s failed: Invalid type for INTEGER/ENUM: %#v", obj.ISEID, value)
+			return "", fmt.Errorf("Invalid type for INTEGER/ENUM: %#v", value)
 		}
-		strval = fmt.Sprint(i)
+		return fmt.Sprint(i), nil
 
 	case "FLOAT":
 		f, ok := value.(float64)
 		if !ok {
-			return fmt.Errorf("Writing of object %s failed: Invalid type for FLOAT: %#v", obj.ISEID, value)
+			return "", fmt.Errorf("Invalid type for FLOAT: %#v", value)
+		}
+		// no exponent, HM script does not support it
+		prec := -1
+		if sc.FloatPrecision > 0 {
+			prec = sc.FloatPrecision
 		}
-		// 6 decimal places are supported
-		strval = fmt.Sprintf("%f", f)
+		return strconv.FormatFloat(f, 'f', prec, 64), nil
 
 	case "STRING":
 		s, ok := value.(string)
 		if !ok {
-			return fmt.Errorf("Writing of object %s failed: Invalid type for STRING: %#v", obj.ISEID, value)
+			return "", fmt.Errorf("Invalid type for STRING: %#v", value)
 		}
-		strval = strconv.Quote(s)
+		return strconv.Quote(s), nil
 
 	default:
-		return fmt.Errorf("Writing of object %s failed: Unsupported type: %s", obj.ISEID, obj.Type)
-	}
-
-	// execute script
-	resp, err := sc.ExecuteTempl(writeValueTempl, map[string]interface{}{"ISEID": obj.ISEID, "Value": strval})
-	if err != nil {
-		return fmt.Errorf("Writing of object %s failed: %v", obj.ISEID, err)
+		return "", fmt.Errorf("Unsupported type: %s", typ)
 	}
-	if len(resp) != 1 {
-		return fmt.Errorf("Writing of object %s failed: Expected one response line", obj.ISEID)
-	}
-	if resp[0] != "OK" {
-		return fmt.Errorf("Writing of object %s failed: HM script signals error: %s", obj.ISEID, resp[0])
-	}
-	return nil
 }
 
 // ReadSysVars reads the values of system variables.
@@ -758,26 +1154,145 @@ func (sc *Client) WriteSysVar(sysVar *SysVarDef, value interface{}) error {
 	return sc.WriteValue(ValObjDef{sysVar.ISEID, sysVar.Type}, value)
 }
 
+// Favorites retrieves the favorite lists from the CCU.
+func (sc *Client) Favorites() ([]FavoriteDef, error) {
+	scriptLog.Debug("Retrieving favorites")
+	resp, err := sc.ExecuteTempl(enumFavoritesTempl, nil)
+	if err != nil {
+		return nil, err
+	}
+	if len(resp) < 1 {
+		return nil, errors.New("Retrieving favorites: Expected at least one response line")
+	}
+	if resp[0] != "OK" {
+		return nil, fmt.Errorf("Retrieving favorites: HM script signals error: %s", resp[0])
+	}
+	var fs []FavoriteDef
+	for _, l := range resp[1:] {
+		f := strings.Split(l, "\t")
+		if len(f) != 2 {
+			return nil, fmt.Errorf("Retrieving favorites: Invalid response line: %s", l)
+		}
+		fs = append(fs, FavoriteDef{ISEID: f[0], DisplayName: f[1]})
+	}
+	return fs, nil
+}
+
+// FavoriteChannels retrieves the addresses of the channels in a favorite list.
+// Other entries (e.g. programs, system variables) are skipped.
+func (sc *Client) FavoriteChannels(iseID string) ([]string, error) {
+	scriptLog.Debugf("Retrieving channels of favorite: %s", iseID)
+	resp, err := sc.ExecuteTempl(enumFavChannelsTempl, iseID)
+	if err != nil {
+		return nil, err
+	}
+	if len(resp) < 1 {
+		return nil, fmt.Errorf("Retrieving channels of favorite %s: Expected at least one response line", iseID)
+	}
+	if resp[0] != "OK" {
+		return nil, fmt.Errorf("Retrieving channels of favorite %s: HM script signals error: %s", iseID, resp[0])
+	}
+	return resp[1:], nil
+}
+
+// DeviceStatus reads the reachability and the battery state of a device from
+// the datapoints UNREACH and LOWBAT/LOW_BAT of the maintenance channel. If a
+// datapoint does not exist, the device is considered reachable or the battery
+// as good.
+func (sc *Client) DeviceStatus(deviceAddress string) (reachable bool, lowBattery bool, err error) {
+	scriptLog.Debugf("Reading status of device: %s", deviceAddress)
+	resp, err := sc.ExecuteTempl(readDevStatusTempl, strconv.Quote(deviceAddress))
+	if err != nil {
+		return false, false, err
+	}
+	if len(resp) < 1 {
+		return false, false, fmt.Errorf("Reading status of device %s: Expected at least one response line", deviceAddress)
+	}
+	if resp[0] != "OK" {
+		return false, false, fmt.Errorf("Reading status of device %s: HM script signals error: %s", deviceAddress, resp[0])
+	}
+	if len(resp) != 3 {
+		return false, false, fmt.Errorf("Reading status of device %s: Expected 3 response lines", deviceAddress)
+	}
+	unreach, err := strconv.ParseBool(resp[1])
+	if err != nil {
+		return false, false, fmt.Errorf("Reading status of device %s: Invalid UNREACH value: %s", deviceAddress, resp[1])
+	}
+	lowBattery, err = strconv.ParseBool(resp[2])
+	if err != nil {
+		return false, false, fmt.Errorf("Reading status of device %s: Invalid LOWBAT value: %s", deviceAddress, resp[2])
+	}
+	return !unreach, lowBattery, nil
+}
+
+// CCUInfo identifies a CCU.
+type CCUInfo struct {
+	// Build label of the ReGaHss
+	ReGaVersion string
+}
+
+// ReadCCUInfo reads the ReGaHss version of the CCU. The serial number and the
+// firmware version of the CCU are not included, because the ReGaHss can only
+// read them with system.Exec, which blocks the ReGaHss.
+func (sc *Client) ReadCCUInfo() (*CCUInfo, error) {
+	scriptLog.Debug("Reading CCU info")
+	resp, err := sc.ExecuteTempl(readCCUInfoTempl, nil)
+	if err != nil {
+		return nil, err
+	}
+	if len(resp) < 1 {
+		return nil, errors.New("Reading CCU info: Expected at least one response line")
+	}
+	if resp[0] != "OK" {
+		return nil, fmt.Errorf("Reading CCU info: HM script signals error: %s", resp[0])
+	}
+	if len(resp) != 2 {
+		return nil, errors.New("Reading CCU info: Expected 2 response lines")
+	}
+	return &CCUInfo{ReGaVersion: resp[1]}, nil
+}
+
 // Programs retrieves all programs from the CCU.
 func (sc *Client) Programs() ([]*ProgramDef, error) {
 	scriptLog.Debug("Retrieving programs")
-	resp, err := sc.ExecuteTempl(enumProgramsTempl, nil)
+	resp, err := sc.ExecuteTempl(enumProgramsTempl, false)
 	if err != nil {
 		return nil, err
 	}
+	ps, _, err := responseToPrograms(resp, false)
+	return ps, err
+}
+
+// ProgramsWithExecTime retrieves all programs together with the last execution
+// times from the CCU. The execution times have the same order as the programs.
+func (sc *Client) ProgramsWithExecTime() ([]*ProgramDef, []time.Time, error) {
+	scriptLog.Debug("Retrieving programs with last execution times")
+	resp, err := sc.ExecuteTempl(enumProgramsTempl, true)
+	if err != nil {
+		return nil, nil, err
+	}
+	return responseToPrograms(resp, true)
+}
+
+func responseToPrograms(resp []string, withExecTime bool) ([]*ProgramDef, []time.Time, error) {
 	if len(resp) < 1 {
-		return nil, errors.New("Retrieving programs: Expected at least one response line")
+		return nil, nil, errors.New("Retrieving programs: Expected at least one response line")
 	}
 	if resp[0] != "OK" {
-		return nil, fmt.Errorf("Retrieving programs: HM script signals error: %s", resp[0])
+		return nil, nil, fmt.Errorf("Retrieving programs: HM script signals error: %s", resp[0])
+	}
+	numFields := 5
+	if withExecTime {
+		numFields = 6
 	}
 	var ps []*ProgramDef
+	var ts []time.Time
 	for _, l := range resp[1:] {
 		fs := strings.Split(l, "\t")
-		if len(fs) != 5 {
-			return nil, fmt.Errorf("Retrieving programs: Invalid response line: %s", l)
+		if len(fs) != numFields {
+			return nil, nil, fmt.Errorf("Retrieving programs: Invalid response line: %s", l)
 		}
-		// fields: ID, Name, PrgInfo, Active, Visible
+		// fields: ID, Name, PrgInfo, Active, Visible, [LastExecuteTime]
 		ps = append(ps, &ProgramDef{
 			ISEID:       fs[0],
 			DisplayName: fs[1],
@@ -785,8 +1300,15 @@ func (sc *Client) Programs() ([]*ProgramDef, error) {
 			Active:      fs[3] == "true",
 			Visible:     fs[4] == "true",
 		})
+		if withExecTime {
+			t, err := time.ParseInLocation("2006-01-02 15:04:05", fs[5], time.Local)
+			if err != nil {
+				return nil, nil, fmt.Errorf("Retrieving programs: Invalid timestamp: %s", fs[5])
+			}
+			ts = append(ts, t)
+		}
 	}
-	return ps, nil
+	return ps, ts, nil
 }
 
 // ExecProgram executes a ReGaHssProgram.
@@ -805,6 +1327,99 @@ func (sc *Client) ExecProgram(p *ProgramDef) error {
 	return nil
 }
 
+// ExecProgramAndWait executes a ReGaHssProgram and waits until the last
+// execution time of the program reaches the time of the CCU before the
+// execution. The last execution time is polled with the specified interval (1
+// second, if not set). The new last execution time is returned. The resolution
+// of the execution time is one second. If the program was already executed in
+// the current second, the execution is delayed until the next second, so that
+// the previous execution is not taken for the new one.
+func (sc *Client) ExecProgramAndWait(ctx context.Context, p *ProgramDef, poll time.Duration) (time.Time, error) {
+	if poll == 0 {
+		poll = time.Second
+	}
+	ticker := time.NewTicker(poll)
+	defer ticker.Stop()
+	wait := func() error {
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("Waiting for execution of program %s failed: %w", p.DisplayName, ctx.Err())
+		case <-ticker.C:
+			return nil
+		}
+	}
+	// wait for a second without execution
+	for {
+		last, now, err := sc.execTimes(p, false)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if last.Before(now) {
+			break
+		}
+		if err := wait(); err != nil {
+			return time.Time{}, err
+		}
+	}
+	// execute and take the time of the CCU as baseline
+	_, base, err := sc.execTimes(p, true)
+	if err != nil {
+		return time.Time{}, err
+	}
+	for {
+		ts, err := sc.ReadExecTime(p)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if !ts.Before(base) {
+			return ts, nil
+		}
+		if err := wait(); err != nil {
+			return time.Time{}, err
+		}
+	}
+}
+
+// execTimes reads the last execution time of a ReGaHssProgram and the current
+// time of the CCU. If exec is true, the program is executed afterwards.
+func (sc *Client) execTimes(p *ProgramDef, exec bool) (last, now time.Time, err error) {
+	if exec {
+		scriptLog.Debug("Executing program: ", p.DisplayName)
+	} else {
+		scriptLog.Debugf("Reading execution times: %v", p.DisplayName)
+	}
+	data := struct {
+		ISEID string
+		Exec  bool
+	}{p.ISEID, exec}
+	resp, err := sc.ExecuteTempl(execTimesTempl, data)
+	if err != nil {
+		return
+	}
+	if len(resp) < 1 {
+		err = errors.New("Reading execution times: Expected at least one response line")
+		return
+	}
+	if resp[0] != "OK" {
+		err = fmt.Errorf("Reading execution times: HM script signals error: %s", resp[0])
+		return
+	}
+	if len(resp) != 3 {
+		err = errors.New("Reading execution times: Expected 3 response lines")
+		return
+	}
+	last, err = time.ParseInLocation("2006-01-02 15:04:05", resp[1], time.Local)
+	if err != nil {
+		err = fmt.Errorf("Reading execution times: Invalid timestamp: %s", resp[1])
+		return
+	}
+	now, err = time.ParseInLocation("2006-01-02 15:04:05", resp[2], time.Local)
+	if err != nil {
+		err = fmt.Errorf("Reading execution times: Invalid timestamp: %s", resp[2])
+	}
+	return
+}
+
 // ReadExecTime reads the last execution time of a ReGaHssProgram.
 func (sc *Client) ReadExecTime(p *ProgramDef) (time.Time, error) {
 	scriptLog.Debugf("Reading last executing time: %v", p.DisplayName)
@@ -826,6 +1441,176 @@ func (sc *Client) ReadExecTime(p *ProgramDef) (time.Time, error) {
 	return ts, nil
 }
 
+// ProgramSourceSeparator separates the HM scripts of the script actions in
+// the result of ReadProgramSource. It is a HM script comment line, so that the
+// result stays a valid HM script.
+const ProgramSourceSeparator = "\n! ---\n"
+
+// ReadProgramSource reads the HM scripts of the script actions of a
+// ReGaHssProgram (including the else-if and else branches). The scripts are
+// joined with ProgramSourceSeparator. If the program has no script actions, an
+// empty string is returned.
+func (sc *Client) ReadProgramSource(p *ProgramDef) (string, error) {
+	scriptLog.Debugf("Reading source of program: %v", p.DisplayName)
+	resp, err := sc.ExecuteTempl(readPrgSourceTempl, p.ISEID)
+	if err != nil {
+		return "", err
+	}
+	if len(resp) < 1 {
+		return "", errors.New("Reading program source: Expected at least one response line")
+	}
+	if resp[0] != "OK" {
+		return "", fmt.Errorf("Reading program source: HM script signals error: %s", resp[0])
+	}
+	srcs := make([]string, len(resp)-1)
+	for i, enc := range resp[1:] {
+		src, err := url.PathUnescape(enc)
+		if err != nil {
+			return "", fmt.Errorf("Reading program source: Invalid percent encoding: %s", enc)
+		}
+		srcs[i] = src
+	}
+	return strings.Join(srcs, ProgramSourceSeparator), nil
+}
+
+// saveSysVarData returns the template data for saveSysVarScript.
+func saveSysVarData(sv *SysVarDef, iseID string) (map[string]string, error) {
+	t, ok := sysVarTypes[sv.Type]
+	if !ok {
+		return nil, fmt.Errorf("Unsupported type of system variable %s: %s", sv.Name, sv.Type)
+	}
+	d := map[string]string{
+		"ISEID":        iseID,
+		"ObjType":      t[0],
+		"ValueType":    t[1],
+		"ValueSubType": t[2],
+		"Name":         strconv.Quote(sv.Name),
+		"Description":  strconv.Quote(sv.Description),
+		"Unit":         strconv.Quote(sv.Unit),
+	}
+	if sv.Minimum != nil {
+		d["Minimum"] = strconv.FormatFloat(*sv.Minimum, 'f', -1, 64)
+	}
+	if sv.Maximum != nil {
+		d["Maximum"] = strconv.FormatFloat(*sv.Maximum, 'f', -1, 64)
+	}
+	if sv.ValueName0 != nil {
+		d["ValueName0"] = strconv.Quote(*sv.ValueName0)
+	}
+	if sv.ValueName1 != nil {
+		d["ValueName1"] = strconv.Quote(*sv.ValueName1)
+	}
+	if sv.ValueList != nil {
+		d["ValueList"] = strconv.Quote(strings.Join(*sv.ValueList, ";"))
+	}
+	return d, nil
+}
+
+// saveSysVar creates (iseID is empty) or updates a system variable. The ISE ID
+// is returned.
+func (sc *Client) saveSysVar(sv *SysVarDef, iseID string) (string, error) {
+	data, err := saveSysVarData(sv, iseID)
+	if err != nil {
+		return "", err
+	}
+	resp, err := sc.ExecuteTempl(saveSysVarTempl, data)
+	if err != nil {
+		return "", fmt.Errorf("Saving of system variable %s failed: %w", sv.Name, err)
+	}
+	if len(resp) < 1 {
+		return "", fmt.Errorf("Saving of system variable %s failed: Expected at least one response line", sv.Name)
+	}
+	if resp[0] != "OK" {
+		return "", fmt.Errorf("Saving of system variable %s failed: HM script signals error: %s", sv.Name, resp[0])
+	}
+	if len(resp) != 2 {
+		return "", fmt.Errorf("Saving of system variable %s failed: Expected ISE ID", sv.Name)
+	}
+	return resp[1], nil
+}
+
+// CreateSysVar creates a system variable. Name, Description, Unit, Type and
+// the fields for the specific data type are used. The ISE ID of the new system
+// variable is returned.
+func (sc *Client) CreateSysVar(sv *SysVarDef) (string, error) {
+	scriptLog.Debug("Creating system variable: ", sv.Name)
+	return sc.saveSysVar(sv, "")
+}
+
+// UpdateSysVar updates Description, Unit and the fields for the specific data
+// type of the system variable with the ISE ID sv.ISEID. Name and Type can not
+// be modified.
+func (sc *Client) UpdateSysVar(sv *SysVarDef) error {
+	scriptLog.Debug("Updating system variable: ", sv.Name)
+	_, err := sc.saveSysVar(sv, sv.ISEID)
+	return err
+}
+
+// DeleteSysVar deletes the system variable with the ISE ID sv.ISEID.
+func (sc *Client) DeleteSysVar(sv *SysVarDef) error {
+	scriptLog.Debug("Deleting system variable: ", sv.Name)
+	resp, err := sc.ExecuteTempl(deleteSysVarTempl, sv.ISEID)
+	if err != nil {
+		return fmt.Errorf("Deleting of system variable %s failed: %w", sv.Name, err)
+	}
+	if len(resp) != 1 {
+		return fmt.Errorf("Deleting of system variable %s failed: Expected one response line", sv.Name)
+	}
+	if resp[0] != "OK" {
+		return fmt.Errorf("Deleting of system variable %s failed: HM script signals error: %s", sv.Name, resp[0])
+	}
+	return nil
+}
+
+// EnsureSysVar creates the system variable, if no system variable with the
+// name exists. Otherwise the existing system variable is updated, if
+// Description, Unit or the fields for the specific data type differ. The ISE
+// ID of the system variable is returned. created is true, if the system
+// variable was created. An error is returned, if the existing system variable
+// has a different type.
+func (sc *Client) EnsureSysVar(def *SysVarDef) (iseID string, created bool, err error) {
+	svs, err := sc.SystemVariables()
+	if err != nil {
+		return "", false, err
+	}
+	existing := svs.Find(def.Name)
+	if existing == nil {
+		iseID, err := sc.CreateSysVar(def)
+		if err != nil {
+			return "", false, err
+		}
+		return iseID, true, nil
+	}
+	if existing.Type != def.Type {
+		return "", false, fmt.Errorf("System variable %s has type %s, expected: %s", def.Name, existing.Type, def.Type)
+	}
+	// ISE ID and operations are not managed, unspecified fields are kept
+	want := *def
+	want.ISEID = existing.ISEID
+	want.Operations = existing.Operations
+	if want.Minimum == nil {
+		want.Minimum = existing.Minimum
+	}
+	if want.Maximum == nil {
+		want.Maximum = existing.Maximum
+	}
+	if want.ValueName0 == nil {
+		want.ValueName0 = existing.ValueName0
+	}
+	if want.ValueName1 == nil {
+		want.ValueName1 = existing.ValueName1
+	}
+	if want.ValueList == nil {
+		want.ValueList = existing.ValueList
+	}
+	if !existing.Equal(&want) {
+		if err := sc.UpdateSysVar(&want); err != nil {
+			return "", false, err
+		}
+	}
+	return existing.ISEID, false, nil
+}
+
 // optFloat64Equal returns true, if both a and b are nil, or *a==*b.
 func optFloat64Equal(a *float64, b *float64) bool {
 	if (a != nil) != (b != nil) {
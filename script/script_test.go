@@ -1,7 +1,19 @@
 package script
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/mdzio/go-lib/testutil"
 )
@@ -67,6 +79,21 @@ func TestScriptClient_Functions(t *testing.T) {
 	}
 }
 
+func TestScriptClient_Favorites(t *testing.T) {
+	cln := &Client{Addr: testutil.Config(t, ccuAddress)}
+
+	fs, err := cln.Favorites()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range fs {
+		_, err := cln.FavoriteChannels(f.ISEID)
+		if err != nil {
+			t.Error(err)
+		}
+	}
+}
+
 func TestScriptClient_DevicesAndChannels(t *testing.T) {
 	cln := &Client{Addr: testutil.Config(t, ccuAddress)}
 
@@ -85,6 +112,15 @@ func TestScriptClient_DevicesAndChannels(t *testing.T) {
 	if len(cs) == 0 {
 		t.Fatal("expected at least 1 channel")
 	}
+
+	_, _, err = cln.DeviceStatus(ds[0].Address)
+	if err != nil {
+		t.Error(err)
+	}
+	_, _, err = cln.DeviceStatus("UNKNOWN-DEVICE")
+	if err == nil {
+		t.Error("expected error")
+	}
 }
 
 func TestScriptClient_Programs(t *testing.T) {
@@ -97,6 +133,31 @@ func TestScriptClient_Programs(t *testing.T) {
 	for _, p := range ps {
 		t.Logf("%v", p)
 	}
+
+	ps2, ts, err := cln.ProgramsWithExecTime()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ps2) != len(ps) || len(ts) != len(ps) {
+		t.Error(len(ps2), len(ts), len(ps))
+	}
+}
+
+func TestResponseToPrograms(t *testing.T) {
+	resp := []string{"OK", "1234\tProg\tInfo\ttrue\tfalse\t2021-02-03 04:05:06"}
+	ps, ts, err := responseToPrograms(resp, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ps) != 1 || ps[0].ISEID != "1234" || !ps[0].Active || ps[0].Visible {
+		t.Error(ps)
+	}
+	if len(ts) != 1 || !ts[0].Equal(time.Date(2021, 2, 3, 4, 5, 6, 0, time.Local)) {
+		t.Error(ts)
+	}
+	if _, _, err := responseToPrograms(resp, false); err == nil {
+		t.Error("expected error")
+	}
 }
 
 func TestScriptClient_ReadWriteSysVarTypes(t *testing.T) {
@@ -183,3 +244,358 @@ func TestScriptClient_ReadDeviceValue(t *testing.T) {
 		t.Fatal("invalid timestamp")
 	}
 }
+
+func TestReadResponse(t *testing.T) {
+	resp, err := readResponse(strings.NewReader("OK\nabc\n<xml><exec>/tclrega.exe</exec><sessionId></sessionId></xml>"), scriptLineLimit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(resp, []string{"OK", "abc"}) {
+		t.Error(resp)
+	}
+
+	// output without line feed
+	resp, err = readResponse(strings.NewReader("abc<xml><exec>/tclrega.exe</exec></xml>"), scriptLineLimit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resp) != 1 {
+		t.Error(resp)
+	}
+
+	for _, r := range []string{"", "OK\nab"} {
+		_, err = readResponse(strings.NewReader(r), scriptLineLimit)
+		if !errors.Is(err, ErrTruncatedResponse) {
+			t.Errorf("%q: %v", r, err)
+		}
+	}
+}
+
+func TestReadResponseLineLimit(t *testing.T) {
+	long := strings.Repeat("a", 100*1024)
+	r := long + "\n<xml><exec>/tclrega.exe</exec></xml>"
+	if _, err := readResponse(strings.NewReader(r), scriptLineLimit); err == nil {
+		t.Error("expected error")
+	}
+	resp, err := readResponse(strings.NewReader(r), 200*1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resp) != 1 || resp[0] != long {
+		t.Error(len(resp))
+	}
+}
+
+func TestSaveSysVarScript(t *testing.T) {
+	min, max := 0.0, 100.5
+	sv := &SysVarDef{Name: "Name \"1\"", Description: "Desc", Unit: "%", Type: "FLOAT", Minimum: &min, Maximum: &max}
+	data, err := saveSysVarData(sv, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var sb strings.Builder
+	if err := saveSysVarTempl.Execute(&sb, data); err != nil {
+		t.Fatal(err)
+	}
+	s := sb.String()
+	for _, want := range []string{
+		"sv = dom.CreateObject(OT_VARDP);",
+		"sv.Name(\"Name \\\"1\\\"\");",
+		"sv.ValueType(ivtFloat);",
+		"sv.ValueMin(0);",
+		"sv.ValueMax(100.5);",
+	} {
+		if !strings.Contains(s, want) {
+			t.Errorf("missing %q in script: %s", want, s)
+		}
+	}
+	if strings.Contains(s, "ValueList") {
+		t.Error(s)
+	}
+
+	sv.Type = "INTEGER"
+	if _, err := saveSysVarData(sv, ""); err == nil {
+		t.Error("expected error")
+	}
+}
+
+func TestScriptClient_EnsureSysVar(t *testing.T) {
+	cln := &Client{Addr: testutil.Config(t, ccuAddress)}
+	vl := []string{"a", "b"}
+	def := &SysVarDef{Name: "go-hmccu test", Description: "Test", Type: "ENUM", ValueList: &vl}
+	id, created, err := cln.EnsureSysVar(def)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !created {
+		t.Error("expected creation")
+	}
+	defer cln.DeleteSysVar(&SysVarDef{ISEID: id, Name: def.Name})
+
+	def.Description = "Test 2"
+	id2, created, err := cln.EnsureSysVar(def)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if created || id2 != id {
+		t.Error(id2, created)
+	}
+	svs, err := cln.SystemVariables()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sv := svs.Find(def.Name)
+	if sv == nil || sv.Description != "Test 2" {
+		t.Error(sv)
+	}
+}
+
+func TestClientURL(t *testing.T) {
+	cln := &Client{Addr: "ccu"}
+	if u := cln.url(); u != "http://ccu:8181/tclrega.exe" {
+		t.Error(u)
+	}
+	cln = &Client{Addr: "proxy", Port: 8080, Path: "ccu/tclrega.exe"}
+	if u := cln.url(); u != "http://proxy:8080/ccu/tclrega.exe" {
+		t.Error(u)
+	}
+}
+
+// newTestScriptServer starts an HTTP server, which answers HM script requests
+// with the specified response lines.
+func newTestScriptServer(t *testing.T, lines ...string) (*Client, func()) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for _, l := range lines {
+			fmt.Fprintln(w, l)
+		}
+		fmt.Fprint(w, "<xml><exec>/tclrega.exe</exec></xml>")
+	}))
+	u, err := url.Parse(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	port, err := strconv.Atoi(u.Port())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &Client{Addr: u.Hostname(), Port: port}, srv.Close
+}
+
+func TestReadValuesValidated(t *testing.T) {
+	cln, closeSrv := newTestScriptServer(t, "OK", "1612325106", "1.5")
+	defer closeSrv()
+
+	objs := []ValObjDef{{"1", "FLOAT"}, {"2", "INTEGER"}}
+	defs := SysVarDefs{{ISEID: "1", Type: "FLOAT"}, {ISEID: "2", Type: "FLOAT"}}
+	vs, err := cln.ReadValuesValidated(objs, defs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if vs[0].Err != nil || vs[0].Value != 1.5 {
+		t.Error(vs[0])
+	}
+	if vs[1].Err == nil || vs[1].Err.Error() != "Type mismatch for object 2: declared INTEGER, CCU FLOAT" {
+		t.Error(vs[1].Err)
+	}
+}
+
+func TestReadChannelValues(t *testing.T) {
+	cln, closeSrv := newTestScriptServer(t,
+		"OK",
+		"OK", "FLOAT", "1612325106", "21.5",
+		"Not found",
+		"OK", "BOOL", "0", "",
+	)
+	defer closeSrv()
+
+	vs, err := cln.ReadChannelValues("ABC0000001:1", []string{"LEVEL", "UNKNOWN", "STATE"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v := vs["LEVEL"]; v.Err != nil || v.Value != 21.5 || v.Uncertain || v.Timestamp.Unix() != 1612325106 {
+		t.Error(v)
+	}
+	if v := vs["UNKNOWN"]; v.Err == nil || v.Err.Error() != "Not found" {
+		t.Error(v)
+	}
+	if v := vs["STATE"]; v.Err != nil || v.Value != false || !v.Uncertain {
+		t.Error(v)
+	}
+}
+
+func TestFormatFloat(t *testing.T) {
+	cases := []struct {
+		prec int
+		in   float64
+		want string
+	}{
+		{0, 1.5, "1.5"},
+		{0, 123456789012.125, "123456789012.125"},
+		{0, 1e21, "1000000000000000000000"},
+		{0, -0.1, "-0.1"},
+		{0, 3, "3"},
+		{2, 1.005, "1.00"},
+		{2, 1.5, "1.50"},
+	}
+	for _, c := range cases {
+		cln := &Client{FloatPrecision: c.prec}
+		s, err := cln.formatValue("FLOAT", c.in)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if s != c.want {
+			t.Errorf("precision %d, value %v: got %s, want %s", c.prec, c.in, s, c.want)
+		}
+	}
+}
+
+func TestFormatBool(t *testing.T) {
+	cases := []struct {
+		typ   string
+		value interface{}
+		want  string
+		err   bool
+	}{
+		{"BOOL", true, "true", false},
+		{"BOOL", false, "false", false},
+		{"ALARM", true, "1", false},
+		{"ALARM", false, "0", false},
+		{"ACTION", true, "1", false},
+		{"ACTION", false, "0", false},
+		{"BOOL", 1, "", true},
+		{"ALARM", "true", "", true},
+	}
+	cln := &Client{}
+	for _, c := range cases {
+		s, err := cln.formatValue(c.typ, c.value)
+		if (err != nil) != c.err {
+			t.Errorf("%s %#v: unexpected error: %v", c.typ, c.value, err)
+			continue
+		}
+		if s != c.want {
+			t.Errorf("%s %#v: got %s, want %s", c.typ, c.value, s, c.want)
+		}
+	}
+}
+
+func TestReadCCUInfo(t *testing.T) {
+	cln, closeSrv := newTestScriptServer(t, "OK", "R1.00.0388.0235")
+	defer closeSrv()
+
+	info, err := cln.ReadCCUInfo()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(info, &CCUInfo{ReGaVersion: "R1.00.0388.0235"}) {
+		t.Error(info)
+	}
+}
+
+// testCCU simulates the execution of a program on a CCU, whose clock differs
+// from the clock of the host.
+type testCCU struct {
+	mtx    sync.Mutex
+	offset time.Duration // offset of the CCU clock
+	last   time.Time     // last execution time
+	delay  int           // reads of the execution time until completion, 0: never
+	reads  int           // reads of the execution time
+	execs  int           // number of executions
+	runs   bool          // execution in progress
+}
+
+func (c *testCCU) now() time.Time {
+	return time.Now().Add(c.offset).Truncate(time.Second)
+}
+
+func newTestProgramServer(t *testing.T, ccu *testCCU) (*Client, func()) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		script, _ := ioutil.ReadAll(r.Body)
+		ccu.mtx.Lock()
+		defer ccu.mtx.Unlock()
+		const layout = "2006-01-02 15:04:05"
+		fmt.Fprintln(w, "OK")
+		switch {
+		case strings.Contains(string(script), "system.Date"):
+			fmt.Fprintln(w, ccu.last.Format(layout))
+			fmt.Fprintln(w, ccu.now().Format(layout))
+			if strings.Contains(string(script), "ProgramExecute") {
+				ccu.execs++
+				ccu.runs = ccu.delay != 0
+				ccu.reads = 0
+			}
+		case strings.Contains(string(script), "ProgramLastExecuteTime"):
+			ccu.reads++
+			if ccu.runs && ccu.reads >= ccu.delay {
+				ccu.runs = false
+				ccu.last = ccu.now()
+			}
+			fmt.Fprintln(w, ccu.last.Format(layout))
+		}
+		fmt.Fprint(w, "<xml><exec>/tclrega.exe</exec></xml>")
+	}))
+	u, err := url.Parse(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	port, err := strconv.Atoi(u.Port())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &Client{Addr: u.Hostname(), Port: port}, srv.Close
+}
+
+func TestExecProgramAndWait(t *testing.T) {
+	p := &ProgramDef{ISEID: "1234", DisplayName: "Prg"}
+	exec := func(ccu *testCCU, timeout time.Duration) (time.Time, error) {
+		cln, closeSrv := newTestProgramServer(t, ccu)
+		defer closeSrv()
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+		return cln.ExecProgramAndWait(ctx, p, 10*time.Millisecond)
+	}
+
+	// CCU clock lags behind, execution completes after some polls
+	ccu := &testCCU{offset: -time.Hour, delay: 3}
+	ccu.last = ccu.now().Add(-time.Minute)
+	ts, err := exec(ccu, 3*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ccu.execs != 1 || ccu.reads != 3 || !ts.Equal(ccu.last) {
+		t.Error(ccu.execs, ccu.reads, ts, ccu.last)
+	}
+
+	// CCU clock is ahead, previous execution in the current second of the CCU
+	ccu = &testCCU{offset: time.Hour, delay: 1}
+	prev := ccu.now()
+	ccu.last = prev
+	ts, err = exec(ccu, 3*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ccu.execs != 1 || !ts.After(prev) {
+		t.Error(ccu.execs, ts, prev)
+	}
+
+	// program is not executed
+	ccu = &testCCU{offset: -time.Hour}
+	ccu.last = ccu.now().Add(-time.Minute)
+	_, err = exec(ccu, 100*time.Millisecond)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Error(err)
+	}
+}
+
+func TestReadProgramSource(t *testing.T) {
+	cln, closeSrv := newTestScriptServer(t, "OK", "var x = 1;%0AWriteLine(\"100%25\");", "dom.GetObject(123).State(true);")
+	defer closeSrv()
+
+	src, err := cln.ReadProgramSource(&ProgramDef{ISEID: "1234", DisplayName: "Prg"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "var x = 1;\nWriteLine(\"100%\");" + ProgramSourceSeparator + "dom.GetObject(123).State(true);"
+	if src != want {
+		t.Error(src)
+	}
+}